@@ -3,6 +3,7 @@ package cmd
 import (
 	"fmt"
 	"log"
+	"path/filepath"
 	"strings"
 
 	"github.com/alex4386/zikzi/internal/config"
@@ -56,6 +57,12 @@ func runConfig(cmd *cobra.Command, args []string) {
 	} else {
 		fmt.Printf("  JWT Secret:     %s\n", maskSecret(cfg.Auth.JWTSecret))
 	}
+	fmt.Printf("  Lockout:        %t\n", cfg.Auth.Lockout.Enabled)
+	if cfg.Auth.Lockout.Enabled {
+		fmt.Printf("  Max Failures:   %d per user, %d per IP within %s\n",
+			cfg.Auth.Lockout.MaxUserFailures, cfg.Auth.Lockout.MaxIPFailures, cfg.Auth.Lockout.Window)
+		fmt.Printf("  Lockout Time:   %s, up to %s\n", cfg.Auth.Lockout.Duration, cfg.Auth.Lockout.MaxDuration)
+	}
 
 	fmt.Println("\n[OIDC]")
 	fmt.Printf("  Enabled:        %t\n", cfg.Auth.OIDC.Enabled)
@@ -73,6 +80,120 @@ func runConfig(cmd *cobra.Command, args []string) {
 	fmt.Println("\n[Storage]")
 	fmt.Printf("  Path:           %s\n", cfg.Storage.Path)
 	fmt.Printf("  Ghostscript:    %s\n", cfg.Storage.GhostscriptBin)
+	fmt.Printf("  Backend:        %s\n", cfg.Storage.Backend)
+	fmt.Printf("  Encryption:     %t\n", cfg.Storage.Encryption.Enabled)
+	if cfg.Storage.Encryption.Enabled && cfg.Storage.Encryption.KeyFile != "" {
+		fmt.Printf("  Key File:       %s\n", cfg.Storage.Encryption.KeyFile)
+	}
+	if cfg.Storage.Encryption.Enabled {
+		fmt.Printf("  Previous Keys:  %d\n", len(cfg.Storage.Encryption.PreviousKeys)+len(cfg.Storage.Encryption.PreviousKeyFiles))
+	}
+	if cfg.Storage.Backend == "s3" {
+		fmt.Printf("  S3 Endpoint:    %s\n", cfg.Storage.S3.Endpoint)
+		fmt.Printf("  S3 Region:      %s\n", cfg.Storage.S3.Region)
+		fmt.Printf("  S3 Bucket:      %s\n", cfg.Storage.S3.Bucket)
+		fmt.Printf("  S3 Access Key:  %s\n", cfg.Storage.S3.AccessKey)
+		if configShowSecrets {
+			fmt.Printf("  S3 Secret Key:  %s\n", cfg.Storage.S3.SecretKey)
+		} else {
+			fmt.Printf("  S3 Secret Key:  %s\n", maskSecret(cfg.Storage.S3.SecretKey))
+		}
+	}
+
+	fmt.Println("\n[PDF Signing]")
+	fmt.Printf("  Enabled:        %t\n", cfg.Signing.Enabled)
+	if cfg.Signing.Enabled {
+		fmt.Printf("  Key File:       %s\n", cfg.Signing.KeyFile)
+		if cfg.Signing.CertFile != "" {
+			fmt.Printf("  Cert File:      %s\n", cfg.Signing.CertFile)
+		}
+		if configShowSecrets {
+			fmt.Printf("  Password:       %s\n", cfg.Signing.Password)
+		} else {
+			fmt.Printf("  Password:       %s\n", maskSecret(cfg.Signing.Password))
+		}
+		if cfg.Signing.TSAURL != "" {
+			fmt.Printf("  TSA URL:        %s\n", cfg.Signing.TSAURL)
+		}
+	}
+
+	fmt.Println("\n[Quotas]")
+	fmt.Printf("  Max Bytes:      %d\n", cfg.Quota.MaxBytes)
+	fmt.Printf("  Jobs/Day:       %d\n", cfg.Quota.MaxJobsPerDay)
+	fmt.Printf("  Pages/Month:    %d\n", cfg.Quota.MaxPagesPerMonth)
+
+	fmt.Println("\n[Trash]")
+	fmt.Printf("  Period:         %s\n", cfg.Trash.Period)
+
+	fmt.Println("\n[Share Links]")
+	fmt.Printf("  Enabled:        %t\n", cfg.Shares.Enabled)
+	if cfg.Shares.Enabled {
+		fmt.Printf("  Default Expiry: %s\n", cfg.Shares.DefaultExpiry)
+		fmt.Printf("  Max Expiry:     %s\n", cfg.Shares.MaxExpiry)
+	}
+
+	fmt.Println("\n[Webhooks]")
+	fmt.Printf("  Enabled:        %t\n", cfg.Webhooks.Enabled)
+	if cfg.Webhooks.Enabled {
+		fmt.Printf("  Timeout:        %s\n", cfg.Webhooks.Timeout)
+		fmt.Printf("  Max Attempts:   %d\n", cfg.Webhooks.MaxAttempts)
+		fmt.Printf("  Retry Backoff:  %s\n", cfg.Webhooks.RetryBackoff)
+		fmt.Printf("  Private Target: %t\n", cfg.Webhooks.AllowPrivateTarget)
+		fmt.Printf("  Log Retention:  %s\n", cfg.Webhooks.LogRetention)
+	}
+
+	fmt.Println("\n[Email Delivery]")
+	fmt.Printf("  Enabled:        %t\n", cfg.Mail.Enabled)
+	if cfg.Mail.Enabled {
+		fmt.Printf("  SMTP Server:    %s:%d (%s)\n", cfg.Mail.Host, cfg.Mail.Port, cfg.Mail.Security)
+		fmt.Printf("  From:           %s\n", cfg.Mail.From)
+		fmt.Printf("  Attach Up To:   %d bytes\n", cfg.Mail.MaxAttachmentSize)
+		fmt.Printf("  Link Expiry:    %s\n", cfg.Mail.LinkExpiry)
+		if cfg.Mail.BaseURL != "" {
+			fmt.Printf("  Base URL:       %s\n", cfg.Mail.BaseURL)
+		}
+	}
+
+	fmt.Println("\n[Forwarding]")
+	fmt.Printf("  Printers:       %d\n", len(cfg.Forwarding.Printers))
+	for _, p := range cfg.Forwarding.Printers {
+		fmt.Printf("    - %s: %s %s\n", p.Name, p.Protocol, p.Address)
+	}
+	for _, queue := range []string{"raw", "ipp", "web"} {
+		if printers := cfg.Forwarding.Queues[queue]; len(printers) > 0 {
+			fmt.Printf("  Queue %-9s %s\n", queue+":", strings.Join(printers, ", "))
+		}
+	}
+
+	fmt.Println("\n[Hot Folders]")
+	fmt.Printf("  Rules:          %d\n", len(cfg.HotFolder.Rules))
+	for _, rule := range cfg.HotFolder.Rules {
+		fmt.Printf("    - %s: %s\n", rule.Name, filepath.Join(rule.Directory, rule.Path))
+	}
+	if len(cfg.HotFolder.Rules) > 0 {
+		fmt.Printf("  Retry Interval: %s\n", cfg.HotFolder.RetryInterval)
+		fmt.Printf("  Max Attempts:   %d\n", cfg.HotFolder.MaxAttempts)
+	}
+
+	fmt.Println("\n[IP Registrations]")
+	fmt.Printf("  Check Interval: %s\n", cfg.IPRegistrations.CheckInterval)
+	fmt.Printf("  Warn Before:    %s\n", cfg.IPRegistrations.WarnBefore)
+	fmt.Printf("  Renew Period:   %s\n", cfg.IPRegistrations.RenewPeriod)
+	fmt.Printf("  Claim Code TTL: %s\n", cfg.IPRegistrations.ClaimCodeTTL)
+
+	fmt.Println("\n[Storage Check]")
+	fmt.Printf("  Enabled:        %t\n", cfg.Fsck.Enabled)
+	if cfg.Fsck.Enabled {
+		fmt.Printf("  Interval:       %s\n", cfg.Fsck.Interval)
+		fmt.Printf("  Repair:         %t\n", cfg.Fsck.Repair)
+	}
+
+	fmt.Println("\n[Retention]")
+	fmt.Printf("  Enabled:        %t\n", cfg.Retention.Enabled)
+	if cfg.Retention.Enabled {
+		fmt.Printf("  Interval:       %s\n", cfg.Retention.Interval)
+		fmt.Printf("  Overrides:      %d queues, %d users\n", len(cfg.Retention.Queues), len(cfg.Retention.Users))
+	}
 }
 
 func maskSecret(s string) string {
@@ -6,8 +6,10 @@ import (
 	"strings"
 	"time"
 
+	"github.com/alex4386/zikzi/internal/config"
 	"github.com/alex4386/zikzi/internal/models"
 	"github.com/spf13/cobra"
+	"gorm.io/gorm"
 )
 
 var ipCmd = &cobra.Command{
@@ -24,11 +26,21 @@ var ipListCmd = &cobra.Command{
 }
 
 var ipAddCmd = &cobra.Command{
-	Use:   "add <ip-address> <username>",
-	Short: "Register an IP address",
-	Long:  `Register an IP address for a user to enable automatic print job attribution.`,
-	Args:  cobra.ExactArgs(2),
-	Run:   runIPAdd,
+	Use:   "add <ip-address|cidr> <username>",
+	Short: "Register an IP address or range",
+	Long: `Register an IP address or CIDR range (e.g. 192.168.10.0/24 or 2001:db8:1:2::/64)
+for a user to enable automatic print job attribution.
+Ranges may overlap, in which case the most specific one wins.`,
+	Args: cobra.ExactArgs(2),
+	Run:  runIPAdd,
+}
+
+var ipLookupCmd = &cobra.Command{
+	Use:   "lookup <ip-address>",
+	Short: "Show the registration matching an IP address",
+	Long:  `Show which registration print jobs from an IP address are attributed to.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   runIPLookup,
 }
 
 var ipDeleteCmd = &cobra.Command{
@@ -47,26 +59,54 @@ var ipToggleCmd = &cobra.Command{
 	Run:   runIPToggle,
 }
 
+var ipRenewCmd = &cobra.Command{
+	Use:   "renew <ip-address>",
+	Short: "Renew an expiring IP registration",
+	Long: `Move the expiry of an IP registration to the configured renew period
+(ip_registrations.renew_period) from now, reactivating it if it had expired.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runIPRenew,
+}
+
+var ipClaimCmd = &cobra.Command{
+	Use:   "claim <username>",
+	Short: "Create a claim code for pairing a printing machine",
+	Long: `Create a short-lived claim code, replacing the user's earlier unused codes.
+Printing a page containing the code from any machine registers that machine's IP
+to the user, and the job is discarded.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runIPClaim,
+}
+
 // Flags
 var (
 	ipAddDescription string
 	ipAddExpireDays  int
 	ipListUser       string
 	ipListActive     string
+	ipRenewDays      int
+	ipClaimDesc      string
 )
 
 func init() {
 	rootCmd.AddCommand(ipCmd)
 	ipCmd.AddCommand(ipListCmd)
 	ipCmd.AddCommand(ipAddCmd)
+	ipCmd.AddCommand(ipLookupCmd)
 	ipCmd.AddCommand(ipDeleteCmd)
 	ipCmd.AddCommand(ipToggleCmd)
+	ipCmd.AddCommand(ipRenewCmd)
+	ipCmd.AddCommand(ipClaimCmd)
 
 	ipAddCmd.Flags().StringVarP(&ipAddDescription, "description", "d", "", "Description for this IP registration")
 	ipAddCmd.Flags().IntVarP(&ipAddExpireDays, "expires", "e", 0, "Expire after N days (0 = never)")
 
 	ipListCmd.Flags().StringVarP(&ipListUser, "user", "u", "", "Filter by username")
 	ipListCmd.Flags().StringVarP(&ipListActive, "active", "a", "", "Filter by active status (true/false)")
+
+	ipClaimCmd.Flags().StringVarP(&ipClaimDesc, "description", "d", "", "Description for the registration created by the code")
+
+	ipRenewCmd.Flags().IntVarP(&ipRenewDays, "days", "d", 0, "Renew for N days instead of the renew period (also sets an expiry on registrations without one)")
 }
 
 func runIPList(cmd *cobra.Command, args []string) {
@@ -104,8 +144,8 @@ func runIPList(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	fmt.Printf("%-12s %-18s %-15s %-25s %-8s %-20s\n", "ID", "IP Address", "User", "Description", "Active", "Expires")
-	fmt.Println(strings.Repeat("-", 105))
+	fmt.Printf("%-12s %-24s %-15s %-25s %-8s %-20s\n", "ID", "IP Address", "User", "Description", "Active", "Expires")
+	fmt.Println(strings.Repeat("-", 111))
 	for _, r := range registrations {
 		username := "-"
 		if r.User != nil {
@@ -130,7 +170,7 @@ func runIPList(cmd *cobra.Command, args []string) {
 				expiresStr = r.ExpiresAt.Format("2006-01-02 15:04")
 			}
 		}
-		fmt.Printf("%-12s %-18s %-15s %-25s %-8s %-20s\n", r.ID, r.IPAddress, username, desc, activeStr, expiresStr)
+		fmt.Printf("%-12s %-24s %-15s %-25s %-8s %-20s\n", r.ID, r.IPAddress, username, desc, activeStr, expiresStr)
 	}
 	fmt.Printf("\nTotal: %d registrations\n", len(registrations))
 }
@@ -150,17 +190,25 @@ func runIPAdd(cmd *cobra.Command, args []string) {
 		log.Fatalf("User '%s' not found", username)
 	}
 
+	registration := models.IPRegistration{
+		UserID:      user.ID,
+		Description: ipAddDescription,
+		IsActive:    true,
+	}
+	if err := registration.SetAddress(ipAddress); err != nil {
+		log.Fatalf("%v", err)
+	}
+	ipAddress = registration.IPAddress
+
 	// Check if IP already registered
 	var existing models.IPRegistration
 	if err := db.Where("ip_address = ?", ipAddress).First(&existing).Error; err == nil {
 		log.Fatalf("IP address '%s' is already registered", ipAddress)
 	}
 
-	registration := models.IPRegistration{
-		UserID:      user.ID,
-		IPAddress:   ipAddress,
-		Description: ipAddDescription,
-		IsActive:    true,
+	overlapping, err := registration.Overlapping(db)
+	if err != nil {
+		log.Fatalf("Failed to check overlapping registrations: %v", err)
 	}
 
 	if ipAddExpireDays > 0 {
@@ -173,6 +221,33 @@ func runIPAdd(cmd *cobra.Command, args []string) {
 	}
 
 	fmt.Printf("IP address '%s' registered for user '%s'\n", ipAddress, username)
+	for _, other := range overlapping {
+		owner := "-"
+		if other.User != nil {
+			owner = other.User.Username
+		}
+		fmt.Printf("  Overlaps '%s' (%s), the more specific registration wins\n", other.IPAddress, owner)
+	}
+}
+
+func runIPLookup(cmd *cobra.Command, args []string) {
+	db, err := getDB()
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+
+	registration, err := models.FindIPRegistration(db, args[0])
+	if err != nil {
+		fmt.Printf("No active registration covers '%s'\n", args[0])
+		return
+	}
+
+	username := "-"
+	var user models.User
+	if err := db.First(&user, "id = ?", registration.UserID).Error; err == nil {
+		username = user.Username
+	}
+	fmt.Printf("'%s' matches '%s' (%s) of user '%s'\n", args[0], registration.IPAddress, registration.ID, username)
 }
 
 func runIPDelete(cmd *cobra.Command, args []string) {
@@ -183,8 +258,8 @@ func runIPDelete(cmd *cobra.Command, args []string) {
 		log.Fatalf("Database error: %v", err)
 	}
 
-	var registration models.IPRegistration
-	if err := db.Where("ip_address = ?", ipAddress).First(&registration).Error; err != nil {
+	registration, err := findRegistrationByAddress(db, ipAddress)
+	if err != nil {
 		log.Fatalf("IP registration '%s' not found", ipAddress)
 	}
 
@@ -194,7 +269,7 @@ func runIPDelete(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	if err := db.Delete(&registration).Error; err != nil {
+	if err := db.Delete(registration).Error; err != nil {
 		log.Fatalf("Failed to delete IP registration: %v", err)
 	}
 
@@ -209,13 +284,13 @@ func runIPToggle(cmd *cobra.Command, args []string) {
 		log.Fatalf("Database error: %v", err)
 	}
 
-	var registration models.IPRegistration
-	if err := db.Where("ip_address = ?", ipAddress).First(&registration).Error; err != nil {
+	registration, err := findRegistrationByAddress(db, ipAddress)
+	if err != nil {
 		log.Fatalf("IP registration '%s' not found", ipAddress)
 	}
 
 	registration.IsActive = !registration.IsActive
-	if err := db.Save(&registration).Error; err != nil {
+	if err := db.Save(registration).Error; err != nil {
 		log.Fatalf("Failed to update IP registration: %v", err)
 	}
 
@@ -225,3 +300,75 @@ func runIPToggle(cmd *cobra.Command, args []string) {
 		fmt.Printf("IP registration '%s' is now inactive\n", ipAddress)
 	}
 }
+
+func runIPRenew(cmd *cobra.Command, args []string) {
+	ipAddress := args[0]
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	db, err := getDB()
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+
+	registration, err := findRegistrationByAddress(db, ipAddress)
+	if err != nil {
+		log.Fatalf("IP registration '%s' not found", ipAddress)
+	}
+	if registration.ExpiresAt == nil && ipRenewDays <= 0 {
+		log.Fatalf("IP registration '%s' does not expire", ipAddress)
+	}
+
+	until := time.Now().Add(cfg.IPRegistrations.RenewPeriod)
+	if ipRenewDays > 0 {
+		until = time.Now().AddDate(0, 0, ipRenewDays)
+	}
+	registration.Renew(until)
+	if err := db.Save(registration).Error; err != nil {
+		log.Fatalf("Failed to renew IP registration: %v", err)
+	}
+
+	fmt.Printf("IP registration '%s' renewed until %s\n", ipAddress, until.Format("2006-01-02 15:04"))
+}
+
+func runIPClaim(cmd *cobra.Command, args []string) {
+	username := args[0]
+
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	db, err := getDB()
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		log.Fatalf("User '%s' not found", username)
+	}
+
+	claim, err := models.CreateClaimCode(db, user.ID, ipClaimDesc, cfg.IPRegistrations.ClaimCodeTTL)
+	if err != nil {
+		log.Fatalf("Failed to create claim code: %v", err)
+	}
+
+	fmt.Printf("Claim code for user '%s': %s\n", username, claim.Code)
+	fmt.Printf("Print a page containing the code from the machine to register before %s\n", claim.ExpiresAt.Format("2006-01-02 15:04"))
+}
+
+// findRegistrationByAddress finds the registration of an exact IP address or range
+func findRegistrationByAddress(db *gorm.DB, address string) (*models.IPRegistration, error) {
+	var key models.IPRegistration
+	if err := key.SetAddress(address); err == nil {
+		address = key.IPAddress
+	}
+
+	var registration models.IPRegistration
+	if err := db.Where("ip_address = ?", address).First(&registration).Error; err != nil {
+		return nil, err
+	}
+	return &registration, nil
+}
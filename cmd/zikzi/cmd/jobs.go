@@ -1,12 +1,19 @@
 package cmd
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"log"
+	"os"
 	"strings"
 	"time"
 
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/export"
+	"github.com/alex4386/zikzi/internal/maintenance"
 	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
 	"github.com/spf13/cobra"
 )
 
@@ -34,7 +41,7 @@ var jobsShowCmd = &cobra.Command{
 var jobsDeleteCmd = &cobra.Command{
 	Use:   "delete <job-id>",
 	Short: "Delete a print job",
-	Long:  `Delete a print job and its associated files.`,
+	Long:  `Move a print job to the trash. Its files are purged once trash.period has passed.`,
 	Args:  cobra.ExactArgs(1),
 	Run:   runJobsDelete,
 }
@@ -68,6 +75,20 @@ var jobsAssignCmd = &cobra.Command{
 	Run:   runJobsAssign,
 }
 
+var jobsVerifyCmd = &cobra.Command{
+	Use:   "verify",
+	Short: "Verify stored job files",
+	Long:  `Re-hash every stored job file and report checksum mismatches and missing files.`,
+	Run:   runJobsVerify,
+}
+
+var jobsExportCmd = &cobra.Command{
+	Use:   "export",
+	Short: "Export jobs as a ZIP archive",
+	Long:  `Write the PDFs of the selected jobs, optionally with their originals and a manifest, into a ZIP archive.`,
+	Run:   runJobsExport,
+}
+
 // Flags
 var (
 	jobsListStatus   string
@@ -75,6 +96,16 @@ var (
 	jobsListLimit    int
 	jobsCleanupDays  int
 	jobsCleanupForce bool
+
+	jobsExportOutput    string
+	jobsExportIDs       []string
+	jobsExportUser      string
+	jobsExportFrom      string
+	jobsExportTo        string
+	jobsExportStatus    string
+	jobsExportTag       string
+	jobsExportOriginals bool
+	jobsExportManifest  bool
 )
 
 func init() {
@@ -86,6 +117,8 @@ func init() {
 	jobsCmd.AddCommand(jobsCleanupCmd)
 	jobsCmd.AddCommand(jobsOrphanedCmd)
 	jobsCmd.AddCommand(jobsAssignCmd)
+	jobsCmd.AddCommand(jobsVerifyCmd)
+	jobsCmd.AddCommand(jobsExportCmd)
 
 	jobsListCmd.Flags().StringVarP(&jobsListStatus, "status", "s", "", "Filter by status (received, processing, completed, failed)")
 	jobsListCmd.Flags().StringVarP(&jobsListUser, "user", "u", "", "Filter by username")
@@ -93,6 +126,16 @@ func init() {
 
 	jobsCleanupCmd.Flags().IntVarP(&jobsCleanupDays, "days", "d", 30, "Delete jobs older than this many days")
 	jobsCleanupCmd.Flags().BoolVarP(&jobsCleanupForce, "force", "f", false, "Skip confirmation")
+
+	jobsExportCmd.Flags().StringVarP(&jobsExportOutput, "output", "o", "", "Output file (default: zikzi-export-<timestamp>.zip)")
+	jobsExportCmd.Flags().StringSliceVar(&jobsExportIDs, "id", nil, "Job IDs to export (repeatable or comma-separated)")
+	jobsExportCmd.Flags().StringVarP(&jobsExportUser, "user", "u", "", "Filter by username")
+	jobsExportCmd.Flags().StringVar(&jobsExportFrom, "from", "", "Only jobs created on or after this date (YYYY-MM-DD)")
+	jobsExportCmd.Flags().StringVar(&jobsExportTo, "to", "", "Only jobs created on or before this date (YYYY-MM-DD)")
+	jobsExportCmd.Flags().StringVarP(&jobsExportStatus, "status", "s", "", "Filter by status (received, processing, completed, failed)")
+	jobsExportCmd.Flags().StringVar(&jobsExportTag, "tag", "", "Filter by tag ID")
+	jobsExportCmd.Flags().BoolVar(&jobsExportOriginals, "originals", false, "Include the original files")
+	jobsExportCmd.Flags().BoolVar(&jobsExportManifest, "manifest", false, "Include a manifest.json describing every job")
 }
 
 func runJobsList(cmd *cobra.Command, args []string) {
@@ -185,6 +228,10 @@ func runJobsShow(cmd *cobra.Command, args []string) {
 	fmt.Printf("  Original:    %s\n", job.OriginalFile)
 	fmt.Printf("  PDF:         %s\n", job.PDFFile)
 	fmt.Printf("  Thumbnail:   %s\n", job.ThumbnailFile)
+	fmt.Printf("\nSHA-256:\n")
+	fmt.Printf("  Original:    %s\n", job.OriginalSHA256)
+	fmt.Printf("  PDF:         %s\n", job.PDFSHA256)
+	fmt.Printf("  Thumbnail:   %s\n", job.ThumbnailSHA256)
 }
 
 func runJobsDelete(cmd *cobra.Command, args []string) {
@@ -206,11 +253,25 @@ func runJobsDelete(cmd *cobra.Command, args []string) {
 		return
 	}
 
-	if err := db.Delete(&job).Error; err != nil {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+	store, err := getJobStore()
+	if err != nil {
+		log.Fatalf("Storage error: %v", err)
+	}
+
+	trash := maintenance.NewTrash(cfg.Trash, db, store)
+	if err := trash.Delete(context.Background(), &job); err != nil {
 		log.Fatalf("Failed to delete job: %v", err)
 	}
 
-	fmt.Printf("Job %s deleted successfully\n", job.ID)
+	if trash.Enabled() {
+		fmt.Printf("Job %s moved to trash (purged after %s)\n", job.ID, cfg.Trash.Period)
+	} else {
+		fmt.Printf("Job %s deleted successfully\n", job.ID)
+	}
 }
 
 func runJobsStats(cmd *cobra.Command, args []string) {
@@ -382,3 +443,140 @@ func runJobsAssign(cmd *cobra.Command, args []string) {
 
 	fmt.Printf("Job %s assigned to user '%s'\n", jobID, username)
 }
+
+func runJobsVerify(cmd *cobra.Command, args []string) {
+	db, err := getDB()
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+
+	store, err := getJobStore()
+	if err != nil {
+		log.Fatalf("Storage error: %v", err)
+	}
+
+	var jobs []models.PrintJob
+	if err := db.Order("created_at ASC").Find(&jobs).Error; err != nil {
+		log.Fatalf("Failed to list jobs: %v", err)
+	}
+
+	var checked, missing, mismatched, unhashed int
+	for _, job := range jobs {
+		files := []struct {
+			kind     string
+			path     string
+			checksum string
+		}{
+			{"original", job.OriginalFile, job.OriginalSHA256},
+			{"pdf", job.PDFFile, job.PDFSHA256},
+			{"thumbnail", job.ThumbnailFile, job.ThumbnailSHA256},
+		}
+
+		for _, f := range files {
+			if f.path == "" {
+				continue
+			}
+			checked++
+
+			sum, err := store.Hash(context.Background(), &job, f.path)
+			if errors.Is(err, storage.ErrNotFound) {
+				missing++
+				fmt.Printf("MISSING   %-12s %-10s %s\n", job.ID, f.kind, f.path)
+				continue
+			}
+			if err != nil {
+				missing++
+				fmt.Printf("ERROR     %-12s %-10s %s: %v\n", job.ID, f.kind, f.path, err)
+				continue
+			}
+
+			if f.checksum == "" {
+				unhashed++
+				continue
+			}
+			if sum != f.checksum {
+				mismatched++
+				fmt.Printf("MISMATCH  %-12s %-10s %s\n", job.ID, f.kind, f.path)
+			}
+		}
+	}
+
+	fmt.Printf("\nChecked %d files in %d jobs: %d mismatched, %d missing, %d without checksum\n",
+		checked, len(jobs), mismatched, missing, unhashed)
+
+	if mismatched > 0 || missing > 0 {
+		os.Exit(1)
+	}
+}
+
+func runJobsExport(cmd *cobra.Command, args []string) {
+	db, err := getDB()
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+
+	store, err := getJobStore()
+	if err != nil {
+		log.Fatalf("Storage error: %v", err)
+	}
+
+	filter := export.Filter{
+		JobIDs: jobsExportIDs,
+		Status: jobsExportStatus,
+		TagID:  jobsExportTag,
+	}
+	if jobsExportUser != "" {
+		var user models.User
+		if err := db.Where("username = ?", jobsExportUser).First(&user).Error; err != nil {
+			log.Fatalf("User '%s' not found", jobsExportUser)
+		}
+		filter.UserID = user.ID
+	}
+	if jobsExportFrom != "" {
+		from, err := time.ParseInLocation("2006-01-02", jobsExportFrom, time.Local)
+		if err != nil {
+			log.Fatalf("Invalid --from date: %v", err)
+		}
+		filter.From = from
+	}
+	if jobsExportTo != "" {
+		to, err := time.ParseInLocation("2006-01-02", jobsExportTo, time.Local)
+		if err != nil {
+			log.Fatalf("Invalid --to date: %v", err)
+		}
+		filter.To = to.AddDate(0, 0, 1)
+	}
+
+	var jobs []models.PrintJob
+	if err := filter.Query(db).Find(&jobs).Error; err != nil {
+		log.Fatalf("Failed to list jobs: %v", err)
+	}
+	if len(jobs) == 0 {
+		fmt.Println("No jobs found")
+		return
+	}
+
+	output := jobsExportOutput
+	if output == "" {
+		output = fmt.Sprintf("zikzi-export-%s.zip", time.Now().Format("20060102-150405"))
+	}
+	f, err := os.Create(output)
+	if err != nil {
+		log.Fatalf("Failed to create %s: %v", output, err)
+	}
+
+	opts := export.Options{Originals: jobsExportOriginals, Manifest: jobsExportManifest}
+	result, err := export.Write(context.Background(), f, store, jobs, opts)
+	if cerr := f.Close(); err == nil {
+		err = cerr
+	}
+	if err != nil {
+		os.Remove(output)
+		log.Fatalf("Export failed: %v", err)
+	}
+
+	fmt.Printf("Exported %d jobs to %s (%d PDFs, %d originals)\n", result.Jobs, output, result.PDFs, result.Originals)
+	if result.Skipped > 0 {
+		fmt.Printf("Warning: %d files could not be read and were skipped\n", result.Skipped)
+	}
+}
@@ -0,0 +1,47 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/mail"
+	"github.com/spf13/cobra"
+)
+
+var mailCmd = &cobra.Command{
+	Use:   "mail",
+	Short: "Manage email delivery",
+	Long:  `Commands for checking the SMTP settings used to email finished PDFs.`,
+}
+
+var mailTestCmd = &cobra.Command{
+	Use:   "test <address>",
+	Short: "Send a test email",
+	Long:  `Send a test message through the configured SMTP server. Works even when mail.enabled is false.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   runMailTest,
+}
+
+func init() {
+	rootCmd.AddCommand(mailCmd)
+	mailCmd.AddCommand(mailTestCmd)
+}
+
+func runMailTest(cmd *cobra.Command, args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	mailer, err := mail.NewMailer(cfg.Mail, cfg.Shares, nil, nil)
+	if err != nil {
+		log.Fatalf("Invalid mail configuration: %v", err)
+	}
+
+	if err := mailer.SendTest(context.Background(), args[0]); err != nil {
+		log.Fatalf("Failed to send test email: %v", err)
+	}
+	fmt.Printf("Test email sent to %s via %s:%d\n", args[0], cfg.Mail.Host, cfg.Mail.Port)
+}
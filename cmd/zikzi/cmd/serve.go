@@ -6,11 +6,22 @@ import (
 	"os/signal"
 	"syscall"
 
+	"github.com/alex4386/zikzi/internal/auth"
 	"github.com/alex4386/zikzi/internal/config"
 	"github.com/alex4386/zikzi/internal/database"
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/forward"
+	"github.com/alex4386/zikzi/internal/hotfolder"
 	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/mail"
+	"github.com/alex4386/zikzi/internal/maintenance"
+	"github.com/alex4386/zikzi/internal/models"
 	"github.com/alex4386/zikzi/internal/printer"
+	"github.com/alex4386/zikzi/internal/quota"
+	"github.com/alex4386/zikzi/internal/signing"
+	"github.com/alex4386/zikzi/internal/storage"
 	"github.com/alex4386/zikzi/internal/web"
+	"github.com/alex4386/zikzi/internal/webhook"
 	"github.com/spf13/cobra"
 )
 
@@ -60,12 +71,67 @@ func runServe(cmd *cobra.Command, args []string) {
 		logger.Fatal("Failed to migrate database: %v", err)
 	}
 
+	// IPP tokens are only stored hashed, with Digest hashes bound to the realm
+	realm := cfg.IPP.Auth.Realm
+	if converted, err := database.HashIPPTokens(db, realm); err != nil {
+		logger.Fatal("Failed to hash IPP tokens: %v", err)
+	} else if converted > 0 {
+		logger.Info("Converted %d plaintext IPP tokens to hashes", converted)
+	}
+	var staleTokens int64
+	db.Model(&models.IPPToken{}).Where("is_active = ? AND digest_realm <> ?", true, realm).Count(&staleTokens)
+	if staleTokens > 0 {
+		logger.Warn("%d IPP tokens were hashed for another realm than %q: Digest auth fails for them until "+
+			"they are used once with Basic auth (over HTTPS) or recreated", staleTokens, realm)
+	}
+	var md5OnlyTokens int64
+	db.Model(&models.IPPToken{}).Where("is_active = ? AND digest_realm = ?", true, realm).
+		Where("digest_ha1_sha256 IS NULL OR digest_ha1_sha256 = ''").Count(&md5OnlyTokens)
+	if md5OnlyTokens > 0 {
+		logger.Info("%d IPP tokens only have an MD5 Digest hash: SHA-256 Digest auth works for them once "+
+			"they are used with Basic auth (over HTTPS) or recreated", md5OnlyTokens)
+	}
+
+	// Load the PDF signing key (nil when signing is disabled)
+	signer, err := signing.NewSigner(cfg.Signing)
+	if err != nil {
+		logger.Fatal("Failed to load signing key: %v", err)
+	}
+	if signer != nil {
+		logger.Info("PDF signing enabled (certificate: %s)", signer.Certificate().Subject.String())
+	}
+
+	backend, err := storage.New(cfg.Storage)
+	if err != nil {
+		logger.Fatal("Failed to initialize storage: %v", err)
+	}
+	keyring, err := storage.NewKeyring(cfg.Storage.Encryption)
+	if err != nil {
+		logger.Fatal("Failed to load encryption key: %v", err)
+	}
+	if keyring != nil {
+		logger.Info("Storage encryption enabled (master key %s, %d previous keys)", keyring.ID(), keyring.PreviousKeys())
+	}
+	store := storage.NewJobStore(backend, keyring)
+	go func() {
+		if updated, err := maintenance.BackfillFileSizes(context.Background(), db, store); err != nil {
+			logger.Warn("Failed to record job file sizes: %v", err)
+		} else if updated > 0 {
+			logger.Info("Recorded file sizes of %d jobs for storage quotas", updated)
+		}
+	}()
+
+	bus := events.NewBus()
+	processor := printer.NewProcessor(cfg.Storage, store, signer, bus, db)
+	quotas := quota.NewChecker(cfg.Quota, db)
+	limiter := auth.NewLimiter(cfg.Auth.Lockout)
+
 	// Create context for graceful shutdown
 	ctx, cancel := context.WithCancel(context.Background())
 	defer cancel()
 
 	// Start PostScript printer server on port 9100
-	printerServer := printer.NewServer(cfg.Printer, cfg.Storage, db)
+	printerServer := printer.NewServer(cfg.Printer, store, processor, quotas, bus, db)
 	go func() {
 		if err := printerServer.Start(ctx); err != nil {
 			logger.Error("Printer server error: %v", err)
@@ -74,7 +140,7 @@ func runServe(cmd *cobra.Command, args []string) {
 
 	// Start IPP server if enabled
 	if cfg.IPP.Enabled {
-		ippServer := printer.NewIPPServer(cfg.IPP, cfg.Printer, cfg.Storage, db)
+		ippServer := printer.NewIPPServer(cfg.IPP, cfg.Printer, store, processor, quotas, bus, db, limiter)
 		go func() {
 			if err := ippServer.Start(ctx); err != nil {
 				logger.Error("IPP server error: %v", err)
@@ -82,8 +148,80 @@ func runServe(cmd *cobra.Command, args []string) {
 		}()
 	}
 
+	// Deliver job events to webhooks
+	webhooks := webhook.NewDispatcher(cfg.Webhooks, db, store)
+	if cfg.Webhooks.Enabled {
+		go webhooks.Run(ctx, bus)
+	}
+
+	// Print completed jobs on downstream printers
+	forwarder, err := forward.NewForwarder(cfg.Forwarding, db, store)
+	if err != nil {
+		logger.Fatal("Failed to configure forwarding: %v", err)
+	}
+	if forwarder.Enabled() {
+		go forwarder.Run(ctx, bus)
+		logger.Info("Forwarding enabled (%d downstream printers)", len(forwarder.Printers()))
+	}
+
+	// Copy completed PDFs into hot folders
+	exporter, err := hotfolder.NewExporter(cfg.HotFolder, db, store)
+	if err != nil {
+		logger.Fatal("Failed to configure hot folders: %v", err)
+	}
+	if exporter.Enabled() {
+		go exporter.Run(ctx, bus)
+		logger.Info("Hot folder export enabled (%d rules)", len(cfg.HotFolder.Rules))
+	}
+
+	// Email finished PDFs to users who opted in
+	var mailer *mail.Mailer
+	if cfg.Mail.Enabled {
+		mailer, err = mail.NewMailer(cfg.Mail, cfg.Shares, db, store)
+		if err != nil {
+			logger.Fatal("Failed to configure email delivery: %v", err)
+		}
+		go mailer.Run(ctx, bus)
+		logger.Info("Email delivery enabled (SMTP server: %s:%d)", cfg.Mail.Host, cfg.Mail.Port)
+	}
+
+	// Start background maintenance tasks
+	scheduler := maintenance.NewScheduler()
+	trash := maintenance.NewTrash(cfg.Trash, db, store)
+	if trash.Enabled() {
+		scheduler.Add("trash purge", cfg.Trash.PurgeInterval, func(ctx context.Context) error {
+			_, err := trash.PurgeExpired(ctx)
+			return err
+		})
+	}
+	if cfg.Retention.Enabled {
+		retention := maintenance.NewRetention(cfg.Retention, db, store)
+		scheduler.Add("retention", cfg.Retention.Interval, func(ctx context.Context) error {
+			_, err := retention.Apply(ctx)
+			return err
+		})
+	}
+	if cfg.Fsck.Enabled {
+		fsck := maintenance.NewFsck(cfg.Fsck, trash, db, store)
+		scheduler.Add("storage check", cfg.Fsck.Interval, func(ctx context.Context) error {
+			report, err := fsck.Run(ctx, cfg.Fsck.Repair)
+			if err != nil {
+				return err
+			}
+			report.Log()
+			return nil
+		})
+	}
+	var expiryWebhooks *webhook.Dispatcher
+	if cfg.Webhooks.Enabled {
+		expiryWebhooks = webhooks
+	}
+	ipExpiry := maintenance.NewIPExpiry(cfg.IPRegistrations, db, mailer, expiryWebhooks)
+	scheduler.Add("ip expiry", cfg.IPRegistrations.CheckInterval, ipExpiry.Run)
+	scheduler.Start(ctx)
+
 	// Start HTTP server (REST API + WebUI)
-	webServer := web.NewServer(cfg, db)
+	webServer := web.NewServer(cfg, db, store, signer, processor, quotas, bus, webhooks, forwarder, exporter, limiter)
 	go func() {
 		if err := webServer.Start(ctx); err != nil {
 			logger.Error("Web server error: %v", err)
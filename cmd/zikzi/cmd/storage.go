@@ -0,0 +1,185 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"log"
+	"os"
+	"strings"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/maintenance"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"github.com/spf13/cobra"
+	"gorm.io/gorm"
+)
+
+var storageCmd = &cobra.Command{
+	Use:   "storage",
+	Short: "Manage job file storage",
+	Long:  `Commands for managing where and how Zikzi stores job files.`,
+}
+
+var storageRotateKeyCmd = &cobra.Command{
+	Use:   "rotate-key",
+	Short: "Rotate the encryption master key",
+	Long: `Re-wrap the data keys of all encrypted jobs with the current master key.
+File contents are not re-encrypted. To rotate without downtime, make the new key
+storage.encryption.key_file, move the old one to previous_key_files and restart Zikzi.
+Then run this command, and remove the old key once no jobs use it anymore.`,
+	Run: runStorageRotateKey,
+}
+
+var storageGenerateKeyCmd = &cobra.Command{
+	Use:   "generate-key <file>",
+	Short: "Generate an encryption master key",
+	Long:  `Write a new random base64-encoded master key to a file that doesn't exist yet.`,
+	Args:  cobra.ExactArgs(1),
+	Run:   runStorageGenerateKey,
+}
+
+var storageFsckCmd = &cobra.Command{
+	Use:   "fsck",
+	Short: "Check storage consistency",
+	Long: `Find stored files no job refers to, jobs whose files are missing, and jobs left
+in the trash past trash.period. With --repair, delete orphaned files, clear references
+to missing files and purge expired jobs.`,
+	Run: runStorageFsck,
+}
+
+// Flags
+var (
+	storageRepair bool
+)
+
+func init() {
+	rootCmd.AddCommand(storageCmd)
+	storageCmd.AddCommand(storageRotateKeyCmd)
+	storageCmd.AddCommand(storageGenerateKeyCmd)
+	storageCmd.AddCommand(storageFsckCmd)
+
+	storageFsckCmd.Flags().BoolVar(&storageRepair, "repair", false, "Fix the problems found")
+}
+
+func runStorageRotateKey(cmd *cobra.Command, args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	keyring, err := storage.NewKeyring(cfg.Storage.Encryption)
+	if err != nil {
+		log.Fatalf("Failed to load master keys: %v", err)
+	}
+	if keyring == nil {
+		log.Fatalf("Storage encryption is not enabled")
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+
+	var rotated, failed int
+	var jobs []models.PrintJob
+	// Include deleted jobs, their files may still be stored
+	result := db.Unscoped().Where("data_key <> '' AND (key_id IS NULL OR key_id <> ?)", keyring.ID()).
+		FindInBatches(&jobs, 100, func(tx *gorm.DB, batch int) error {
+			for _, job := range jobs {
+				wrapped, err := keyring.Rewrap(job.KeyID, job.DataKey)
+				if err != nil {
+					failed++
+					fmt.Printf("Job %s: %v\n", job.ID, err)
+					continue
+				}
+
+				// Skip jobs whose data key changed in the meantime
+				if err := db.Unscoped().Model(&job).Where("data_key = ?", job.DataKey).
+					UpdateColumns(map[string]interface{}{
+						"data_key": wrapped,
+						"key_id":   keyring.ID(),
+					}).Error; err != nil {
+					return err
+				}
+				rotated++
+			}
+			return nil
+		})
+	if result.Error != nil {
+		log.Fatalf("Failed to rotate keys: %v", result.Error)
+	}
+
+	fmt.Printf("Re-wrapped %d data keys with master key %s (%d failed)\n", rotated, keyring.ID(), failed)
+	if failed > 0 {
+		fmt.Println("Add the master keys of the failed jobs to storage.encryption.previous_key_files and run this again.")
+		os.Exit(1)
+	}
+	if keyring.PreviousKeys() > 0 {
+		fmt.Println("No job uses a previous master key anymore. You can remove them from storage.encryption.")
+	}
+}
+
+func runStorageGenerateKey(cmd *cobra.Command, args []string) {
+	path := args[0]
+	if _, err := os.Stat(path); err == nil {
+		log.Fatalf("%s already exists", path)
+	}
+
+	key, err := storage.GenerateMasterKey()
+	if err != nil {
+		log.Fatalf("Failed to generate key: %v", err)
+	}
+	if err := os.WriteFile(path, []byte(key+"\n"), 0600); err != nil {
+		log.Fatalf("Failed to write key file: %v", err)
+	}
+
+	keyring, err := storage.NewKeyring(config.EncryptionConfig{Enabled: true, KeyFile: path})
+	if err != nil {
+		log.Fatalf("Failed to load new key: %v", err)
+	}
+	fmt.Printf("Generated master key %s in %s\n", keyring.ID(), path)
+}
+
+func runStorageFsck(cmd *cobra.Command, args []string) {
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	db, err := getDB()
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+
+	store, err := getJobStore()
+	if err != nil {
+		log.Fatalf("Storage error: %v", err)
+	}
+
+	trash := maintenance.NewTrash(cfg.Trash, db, store)
+	report, err := maintenance.NewFsck(cfg.Fsck, trash, db, store).Run(context.Background(), storageRepair)
+	if err != nil {
+		log.Fatalf("Storage check failed: %v", err)
+	}
+
+	for _, issue := range report.Issues {
+		status := ""
+		if issue.Repaired {
+			status = " [repaired]"
+		} else if issue.Error != "" {
+			status = " [repair failed: " + issue.Error + "]"
+		}
+		fmt.Printf("%-14s %-12s %s: %s%s\n", strings.ToUpper(issue.Type), issue.JobID, issue.Key, issue.Detail, status)
+	}
+
+	fmt.Printf("\nChecked %d files and %d jobs: %d issues, %d unrepaired\n",
+		report.FilesListed, report.JobsChecked, len(report.Issues), report.Unrepaired())
+
+	if report.Unrepaired() > 0 {
+		if !storageRepair {
+			fmt.Println("Run with --repair to fix them.")
+		}
+		os.Exit(1)
+	}
+}
@@ -6,6 +6,7 @@ import (
 	"strings"
 	"time"
 
+	"github.com/alex4386/zikzi/internal/config"
 	"github.com/alex4386/zikzi/internal/models"
 	"github.com/spf13/cobra"
 )
@@ -32,11 +33,33 @@ The token will be displayed only once - save it securely.
 
 The token can be used for:
 - HTTP Basic authentication: username + token as password
-- HTTP Digest authentication: username + token as password (if DigestHA1 computed)`,
+- HTTP Digest authentication: username + token as password (for the configured realm)`,
 	Args: cobra.ExactArgs(1),
 	Run:  runTokensCreate,
 }
 
+var tokensShowCmd = &cobra.Command{
+	Use:   "show <token-id>",
+	Short: "Show token details and usage",
+	Long: `Show details of an IPP token and its recent usage history:
+every successful authentication with the IP, IPP operation and created job.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runTokensShow,
+}
+
+var tokensSetScopeCmd = &cobra.Command{
+	Use:   "set-scope <token-id>",
+	Short: "Restrict an IPP token",
+	Long: `Replace the restrictions of an IPP token. Restrictions that aren't given are lifted.
+
+Examples:
+  zikzi tokens set-scope abc123 --queues print,lab --cidrs 192.168.1.0/24
+  zikzi tokens set-scope abc123 --submit-only
+  zikzi tokens set-scope abc123   # Remove all restrictions`,
+	Args: cobra.ExactArgs(1),
+	Run:  runTokensSetScope,
+}
+
 var tokensRevokeCmd = &cobra.Command{
 	Use:   "revoke <token-id>",
 	Short: "Revoke an IPP token",
@@ -57,18 +80,32 @@ var tokensDeleteCmd = &cobra.Command{
 var (
 	tokenName       string
 	tokenExpireDays int
+	tokenUsageLimit int
+	tokenQueues     []string
+	tokenCIDRs      []string
+	tokenSubmitOnly bool
 )
 
 func init() {
 	rootCmd.AddCommand(tokensCmd)
 	tokensCmd.AddCommand(tokensListCmd)
 	tokensCmd.AddCommand(tokensCreateCmd)
+	tokensCmd.AddCommand(tokensShowCmd)
+	tokensCmd.AddCommand(tokensSetScopeCmd)
 	tokensCmd.AddCommand(tokensRevokeCmd)
 	tokensCmd.AddCommand(tokensDeleteCmd)
 
 	tokensCreateCmd.Flags().StringVarP(&tokenName, "name", "n", "", "Token name/description (required)")
 	tokensCreateCmd.Flags().IntVarP(&tokenExpireDays, "expires", "e", 0, "Days until expiration (0 = never)")
 	tokensCreateCmd.MarkFlagRequired("name")
+
+	for _, c := range []*cobra.Command{tokensCreateCmd, tokensSetScopeCmd} {
+		c.Flags().StringSliceVar(&tokenQueues, "queues", nil, "IPP printer names the token may print to (default: all)")
+		c.Flags().StringSliceVar(&tokenCIDRs, "cidrs", nil, "Networks the token may be used from (default: anywhere)")
+		c.Flags().BoolVar(&tokenSubmitOnly, "submit-only", false, "Only allow submitting jobs, not listing or cancelling them")
+	}
+
+	tokensShowCmd.Flags().IntVarP(&tokenUsageLimit, "limit", "l", 20, "Number of usage entries to show")
 }
 
 func runTokensList(cmd *cobra.Command, args []string) {
@@ -149,13 +186,24 @@ func runTokensCreate(cmd *cobra.Command, args []string) {
 		log.Fatalf("Failed to generate token: %v", err)
 	}
 
-	// Create token record (store plaintext for Digest auth compatibility)
+	// Load config to get IPP auth realm
+	cfg, err := config.Load()
+	if err != nil {
+		log.Fatalf("Failed to load config: %v", err)
+	}
+
+	// Create token record, storing only its hashes
 	token := models.IPPToken{
 		UserID:   user.ID,
 		Name:     tokenName,
-		Token:    tokenValue,
 		IsActive: true,
 	}
+	if err := token.SetSecret(user.Username, cfg.IPP.Auth.Realm, tokenValue); err != nil {
+		log.Fatalf("Failed to hash token: %v", err)
+	}
+	if err := token.SetScope(tokenQueues, tokenCIDRs, tokenSubmitOnly); err != nil {
+		log.Fatalf("Invalid restriction: %v", err)
+	}
 
 	// Set expiration if specified
 	if tokenExpireDays > 0 {
@@ -187,6 +235,104 @@ func runTokensCreate(cmd *cobra.Command, args []string) {
 	}
 }
 
+func runTokensShow(cmd *cobra.Command, args []string) {
+	tokenID := args[0]
+
+	db, err := getDB()
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+
+	var token models.IPPToken
+	if err := db.Preload("User").Where("id = ?", tokenID).First(&token).Error; err != nil {
+		log.Fatalf("Token '%s' not found", tokenID)
+	}
+
+	status := "Active"
+	if !token.IsActive {
+		status = "Revoked"
+	} else if token.IsExpired() {
+		status = "Expired"
+	}
+
+	fmt.Printf("Token ID:     %s\n", token.ID)
+	fmt.Printf("Name:         %s\n", token.Name)
+	fmt.Printf("User:         %s\n", token.User.Username)
+	fmt.Printf("Status:       %s\n", status)
+	fmt.Printf("Digest Realm: %s\n", token.DigestRealm)
+	fmt.Printf("Created:      %s\n", token.CreatedAt.Format("2006-01-02 15:04:05"))
+	if token.ExpiresAt != nil {
+		fmt.Printf("Expires:      %s\n", token.ExpiresAt.Format("2006-01-02 15:04:05"))
+	}
+	if token.LastUsedAt != nil {
+		fmt.Printf("Last Used:    %s from %s\n", token.LastUsedAt.Format("2006-01-02 15:04:05"), token.LastUsedIP)
+	}
+	printTokenScope(&token)
+
+	var total int64
+	var usage []models.IPPTokenUsage
+	db.Model(&models.IPPTokenUsage{}).Where("token_id = ?", token.ID).Count(&total)
+	if err := db.Where("token_id = ?", token.ID).Order("id DESC").Limit(tokenUsageLimit).Find(&usage).Error; err != nil {
+		log.Fatalf("Failed to load token usage: %v", err)
+	}
+
+	fmt.Printf("\nUsage (%d of %d):\n", len(usage), total)
+	if len(usage) == 0 {
+		fmt.Println("  Never used")
+		return
+	}
+	fmt.Printf("  %-19s %-8s %-24s %-39s %s\n", "Time", "Method", "Operation", "IP", "Job")
+	for _, u := range usage {
+		job := u.JobID
+		if job == "" {
+			job = "-"
+		}
+		fmt.Printf("  %-19s %-8s %-24s %-39s %s\n",
+			u.CreatedAt.Format("2006-01-02 15:04:05"), u.Method, truncateString(u.Operation, 24), u.IP, job)
+	}
+}
+
+func runTokensSetScope(cmd *cobra.Command, args []string) {
+	tokenID := args[0]
+
+	db, err := getDB()
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+
+	var token models.IPPToken
+	if err := db.Where("id = ?", tokenID).First(&token).Error; err != nil {
+		log.Fatalf("Token '%s' not found", tokenID)
+	}
+
+	if err := token.SetScope(tokenQueues, tokenCIDRs, tokenSubmitOnly); err != nil {
+		log.Fatalf("Invalid restriction: %v", err)
+	}
+	if err := db.Model(&token).Select("queues", "source_cidrs", "submit_only").Updates(&token).Error; err != nil {
+		log.Fatalf("Failed to update token: %v", err)
+	}
+
+	fmt.Printf("Updated restrictions of token '%s' (%s)\n", token.ID, token.Name)
+	printTokenScope(&token)
+}
+
+// printTokenScope prints the restrictions of a token
+func printTokenScope(token *models.IPPToken) {
+	queues, cidrs, operations := "All", "Anywhere", "Submit, list and cancel"
+	if list := token.QueueList(); len(list) > 0 {
+		queues = strings.Join(list, ", ")
+	}
+	if list := token.SourceCIDRList(); len(list) > 0 {
+		cidrs = strings.Join(list, ", ")
+	}
+	if token.SubmitOnly {
+		operations = "Submit only"
+	}
+	fmt.Printf("Queues:       %s\n", queues)
+	fmt.Printf("Sources:      %s\n", cidrs)
+	fmt.Printf("Operations:   %s\n", operations)
+}
+
 func runTokensRevoke(cmd *cobra.Command, args []string) {
 	tokenID := args[0]
 
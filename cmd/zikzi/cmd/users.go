@@ -11,6 +11,7 @@ import (
 	"github.com/alex4386/zikzi/internal/config"
 	"github.com/alex4386/zikzi/internal/database"
 	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
 	"github.com/alex4386/zikzi/internal/utils"
 	"github.com/spf13/cobra"
 	"golang.org/x/term"
@@ -61,8 +62,24 @@ var usersSetPasswordCmd = &cobra.Command{
 	Run:   runUsersSetPassword,
 }
 
+var usersSetQuotaCmd = &cobra.Command{
+	Use:   "set-quota <username>",
+	Short: "Set quotas for a user",
+	Long: `Override the configured default quotas for a user.
+Use 0 to fall back to the default and -1 for unlimited.`,
+	Args: cobra.ExactArgs(1),
+	Run:  runUsersSetQuota,
+}
+
 var setPasswordValue string
 
+// Flags for users set-quota command
+var (
+	quotaBytes         int64
+	quotaJobsPerDay    int
+	quotaPagesPerMonth int
+)
+
 // Flags for users add command
 var (
 	addUsername    string
@@ -79,6 +96,7 @@ func init() {
 	usersCmd.AddCommand(usersDeleteCmd)
 	usersCmd.AddCommand(usersSetAdminCmd)
 	usersCmd.AddCommand(usersSetPasswordCmd)
+	usersCmd.AddCommand(usersSetQuotaCmd)
 
 	usersAddCmd.Flags().StringVarP(&addUsername, "username", "u", "", "Username (required)")
 	usersSetPasswordCmd.Flags().StringVarP(&setPasswordValue, "password", "p", "", "New password (will prompt if not provided)")
@@ -86,6 +104,9 @@ func init() {
 	usersAddCmd.Flags().StringVarP(&addDisplayName, "display-name", "d", "", "Display name")
 	usersAddCmd.Flags().StringVarP(&addPassword, "password", "p", "", "Password (will prompt if not provided)")
 	usersAddCmd.Flags().BoolVar(&addIsAdmin, "admin", false, "Create as admin user")
+	usersSetQuotaCmd.Flags().Int64Var(&quotaBytes, "bytes", 0, "Maximum bytes stored")
+	usersSetQuotaCmd.Flags().IntVar(&quotaJobsPerDay, "jobs-per-day", 0, "Maximum jobs per day")
+	usersSetQuotaCmd.Flags().IntVar(&quotaPagesPerMonth, "pages-per-month", 0, "Maximum pages per month")
 }
 
 func getDB() (*gorm.DB, error) {
@@ -103,9 +124,31 @@ func getDB() (*gorm.DB, error) {
 		return nil, fmt.Errorf("failed to migrate database: %w", err)
 	}
 
+	if _, err := database.HashIPPTokens(db, cfg.IPP.Auth.Realm); err != nil {
+		return nil, fmt.Errorf("failed to hash IPP tokens: %w", err)
+	}
+
 	return db, nil
 }
 
+func getJobStore() (*storage.JobStore, error) {
+	cfg, err := config.Load()
+	if err != nil {
+		return nil, fmt.Errorf("failed to load config: %w", err)
+	}
+
+	backend, err := storage.New(cfg.Storage)
+	if err != nil {
+		return nil, err
+	}
+	keyring, err := storage.NewKeyring(cfg.Storage.Encryption)
+	if err != nil {
+		return nil, err
+	}
+
+	return storage.NewJobStore(backend, keyring), nil
+}
+
 func promptPassword() (string, error) {
 	fmt.Print("Password: ")
 	password, err := term.ReadPassword(int(syscall.Stdin))
@@ -181,9 +224,6 @@ func runUsersAdd(cmd *cobra.Command, args []string) {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Compute DigestHA1 for HTTP Digest authentication
-	digestHA1 := utils.ComputeDigestHA1(username, cfg.IPP.Auth.Realm, password)
-
 	// Use display name from flag or default to username
 	displayName := addDisplayName
 	if displayName == "" {
@@ -196,9 +236,10 @@ func runUsersAdd(cmd *cobra.Command, args []string) {
 		Email:        addEmail,
 		DisplayName:  displayName,
 		PasswordHash: passwordHash,
-		DigestHA1:    digestHA1,
 		IsAdmin:      addIsAdmin,
 	}
+	// Pre-compute the Digest hashes for HTTP Digest authentication
+	user.SetDigest(username, cfg.IPP.Auth.Realm, password)
 
 	if err := db.Create(&user).Error; err != nil {
 		log.Fatalf("Failed to create user: %v", err)
@@ -269,6 +310,41 @@ func runUsersDelete(cmd *cobra.Command, args []string) {
 	fmt.Printf("User '%s' deleted successfully\n", username)
 }
 
+func runUsersSetQuota(cmd *cobra.Command, args []string) {
+	username := args[0]
+
+	db, err := getDB()
+	if err != nil {
+		log.Fatalf("Database error: %v", err)
+	}
+
+	var user models.User
+	if err := db.Where("username = ?", username).First(&user).Error; err != nil {
+		log.Fatalf("User '%s' not found", username)
+	}
+
+	flags := cmd.Flags()
+	if !flags.Changed("bytes") && !flags.Changed("jobs-per-day") && !flags.Changed("pages-per-month") {
+		log.Fatalf("Specify at least one of --bytes, --jobs-per-day or --pages-per-month")
+	}
+	if flags.Changed("bytes") {
+		user.QuotaBytes = quotaBytes
+	}
+	if flags.Changed("jobs-per-day") {
+		user.QuotaJobsPerDay = quotaJobsPerDay
+	}
+	if flags.Changed("pages-per-month") {
+		user.QuotaPagesPerMonth = quotaPagesPerMonth
+	}
+
+	if err := db.Save(&user).Error; err != nil {
+		log.Fatalf("Failed to update user: %v", err)
+	}
+
+	fmt.Printf("Quotas for '%s': bytes=%d, jobs/day=%d, pages/month=%d\n",
+		username, user.QuotaBytes, user.QuotaJobsPerDay, user.QuotaPagesPerMonth)
+}
+
 func runUsersSetAdmin(cmd *cobra.Command, args []string) {
 	username := args[0]
 	adminStr := strings.ToLower(args[1])
@@ -343,11 +419,9 @@ func runUsersSetPassword(cmd *cobra.Command, args []string) {
 		log.Fatalf("Failed to load config: %v", err)
 	}
 
-	// Compute DigestHA1 for HTTP Digest authentication
-	digestHA1 := utils.ComputeDigestHA1(username, cfg.IPP.Auth.Realm, password)
-
 	user.PasswordHash = passwordHash
-	user.DigestHA1 = digestHA1
+	// Pre-compute the Digest hashes for HTTP Digest authentication
+	user.SetDigest(username, cfg.IPP.Auth.Realm, password)
 	if err := db.Save(&user).Error; err != nil {
 		log.Fatalf("Failed to update password: %v", err)
 	}
@@ -19,6 +19,7 @@ require (
 	gorm.io/driver/postgres v1.5.4
 	gorm.io/driver/sqlite v1.5.4
 	gorm.io/gorm v1.25.5
+	software.sslmate.com/src/go-pkcs12 v0.5.0
 )
 
 require (
@@ -0,0 +1,269 @@
+package auth
+
+import (
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/logger"
+)
+
+// Lockout subjects
+const (
+	LockoutUser  = "user"
+	LockoutIP    = "ip"
+	LockoutShare = "share" // Password of a share link
+)
+
+// Lockout describes the failed logins of an account, share link or IP address
+type Lockout struct {
+	Kind        string     `json:"kind" example:"user"` // user, share or ip
+	Value       string     `json:"value" example:"alice"`
+	Failures    int        `json:"failures" example:"3"` // Failures since the last lockout
+	Lockouts    int        `json:"lockouts" example:"1"` // Lockouts in a row, each doubling the next one
+	LastFailure time.Time  `json:"last_failure"`
+	LockedUntil *time.Time `json:"locked_until,omitempty"` // Set while locked out
+}
+
+type lockoutEntry struct {
+	kind        string
+	value       string
+	failures    int
+	lockouts    int
+	lastFailure time.Time
+	lockedUntil time.Time
+}
+
+// Limiter counts failed logins per account and per IP address and locks them
+// out for exponentially growing periods. Logins are checked before passwords
+// are hashed, so guessing can't be used to exhaust the CPU either. A nil
+// *Limiter only logs failures.
+type Limiter struct {
+	config  config.LockoutConfig
+	mu      sync.Mutex
+	entries map[string]*lockoutEntry // Keyed by kind and value, e.g. "user:alice"
+}
+
+// NewLimiter creates a limiter, or returns nil when lockouts are disabled
+func NewLimiter(cfg config.LockoutConfig) *Limiter {
+	if !cfg.Enabled {
+		return nil
+	}
+	l := &Limiter{config: cfg, entries: make(map[string]*lockoutEntry)}
+	go l.cleanup()
+	return l
+}
+
+// maxLockoutEntries bounds the memory used by failures. Usernames are chosen
+// by whoever logs in, so the entries would otherwise grow without limit.
+const maxLockoutEntries = 10000
+
+func lockoutKey(kind, value string) string {
+	return kind + ":" + value
+}
+
+// Check returns until when logins to username from ip are locked out, if they are
+func (l *Limiter) Check(username, ip string) (time.Time, bool) {
+	return l.check(LockoutUser, username, ip)
+}
+
+// CheckShare returns until when password attempts on a share link from ip
+// are locked out, if they are
+func (l *Limiter) CheckShare(shareID, ip string) (time.Time, bool) {
+	return l.check(LockoutShare, shareID, ip)
+}
+
+func (l *Limiter) check(kind, value, ip string) (time.Time, bool) {
+	if l == nil {
+		return time.Time{}, false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	var until time.Time
+	for _, key := range []string{lockoutKey(kind, value), lockoutKey(LockoutIP, ip)} {
+		if e, ok := l.entries[key]; ok && e.lockedUntil.After(now) && e.lockedUntil.After(until) {
+			until = e.lockedUntil
+		}
+	}
+	return until, !until.IsZero()
+}
+
+// Fail logs a failed login and counts it against the account and the IP
+// address, locking them out once they reach their limit. source names the
+// login method, reason why it failed.
+func (l *Limiter) Fail(source, username, ip, reason string) {
+	l.failSubject(source, LockoutUser, username, ip, reason)
+}
+
+// FailShare logs a wrong share link password and counts it against the link
+// and the IP address, with the same limits as logins
+func (l *Limiter) FailShare(shareID, ip, reason string) {
+	l.failSubject("share", LockoutShare, shareID, ip, reason)
+}
+
+func (l *Limiter) failSubject(source, kind, value, ip, reason string) {
+	logger.Warn("Login failed: source=%s %s=%q ip=%s reason=%s", source, kind, value, ip, reason)
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	if value != "" {
+		l.fail(kind, value, l.config.MaxUserFailures, now)
+	}
+	l.fail(LockoutIP, ip, l.config.MaxIPFailures, now)
+}
+
+func (l *Limiter) fail(kind, value string, limit int, now time.Time) {
+	key := lockoutKey(kind, value)
+	e, ok := l.entries[key]
+	if !ok {
+		if len(l.entries) >= maxLockoutEntries {
+			l.evict(now)
+		}
+		e = &lockoutEntry{kind: kind, value: value}
+		l.entries[key] = e
+	}
+
+	// Failures and lockouts are forgiven after a quiet window
+	if now.Sub(e.lastFailure) > l.config.Window {
+		e.failures = 0
+	}
+	if now.Sub(e.lockedUntil) > l.config.Window {
+		e.lockouts = 0
+	}
+
+	e.failures++
+	e.lastFailure = now
+	if limit <= 0 || e.failures < limit {
+		return
+	}
+
+	e.lockouts++
+	duration := l.config.Duration << (e.lockouts - 1)
+	if duration <= 0 || duration > l.config.MaxDuration {
+		duration = l.config.MaxDuration
+	}
+	e.lockedUntil = now.Add(duration)
+	e.failures = 0
+	logger.Warn("Login locked out: %s=%q lockouts=%d duration=%s until=%s",
+		kind, value, e.lockouts, duration, e.lockedUntil.Format(time.RFC3339))
+}
+
+// evict drops the entry with the oldest failure to make room for a new one,
+// keeping current lockouts unless every entry is locked out
+func (l *Limiter) evict(now time.Time) {
+	var oldest, oldestLocked *lockoutEntry
+	for _, e := range l.entries {
+		if e.lockedUntil.After(now) {
+			if oldestLocked == nil || e.lastFailure.Before(oldestLocked.lastFailure) {
+				oldestLocked = e
+			}
+		} else if oldest == nil || e.lastFailure.Before(oldest.lastFailure) {
+			oldest = e
+		}
+	}
+	if oldest == nil {
+		oldest = oldestLocked
+	}
+	if oldest != nil {
+		delete(l.entries, lockoutKey(oldest.kind, oldest.value))
+	}
+}
+
+// Succeed clears the failures of an account after a successful login. The
+// IP address keeps its failures, so logging into one account doesn't hide
+// guesses at others.
+func (l *Limiter) Succeed(username string) {
+	l.succeed(LockoutUser, username)
+}
+
+// SucceedShare clears the failures of a share link after the right password
+func (l *Limiter) SucceedShare(shareID string) {
+	l.succeed(LockoutShare, shareID)
+}
+
+func (l *Limiter) succeed(kind, value string) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := lockoutKey(kind, value)
+	if e, ok := l.entries[key]; ok && !e.lockedUntil.After(time.Now()) {
+		delete(l.entries, key)
+	}
+}
+
+// List returns the accounts, share links and IP addresses with recent failures, most recent first
+func (l *Limiter) List() []Lockout {
+	lockouts := []Lockout{}
+	if l == nil {
+		return lockouts
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	now := time.Now()
+	for _, e := range l.entries {
+		lockout := Lockout{
+			Kind:        e.kind,
+			Value:       e.value,
+			Failures:    e.failures,
+			Lockouts:    e.lockouts,
+			LastFailure: e.lastFailure,
+		}
+		if e.lockedUntil.After(now) {
+			until := e.lockedUntil
+			lockout.LockedUntil = &until
+		}
+		lockouts = append(lockouts, lockout)
+	}
+
+	sort.Slice(lockouts, func(i, j int) bool {
+		return lockouts[i].LastFailure.After(lockouts[j].LastFailure)
+	})
+	return lockouts
+}
+
+// Clear forgets the failures and lockouts of an account, share link or IP address,
+// reporting whether there were any
+func (l *Limiter) Clear(kind, value string) bool {
+	if l == nil {
+		return false
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	key := lockoutKey(kind, value)
+	if _, ok := l.entries[key]; !ok {
+		return false
+	}
+	delete(l.entries, key)
+	logger.Info("Login lockout cleared: %s=%q", kind, value)
+	return true
+}
+
+// cleanup periodically drops entries that would be forgiven anyway
+func (l *Limiter) cleanup() {
+	ticker := time.NewTicker(time.Minute)
+	for range ticker.C {
+		l.mu.Lock()
+		now := time.Now()
+		for key, e := range l.entries {
+			if now.Sub(e.lastFailure) > l.config.Window && now.Sub(e.lockedUntil) > l.config.Window {
+				delete(l.entries, key)
+			}
+		}
+		l.mu.Unlock()
+	}
+}
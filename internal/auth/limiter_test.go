@@ -0,0 +1,40 @@
+package auth
+
+import (
+	"fmt"
+	"testing"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/logger"
+)
+
+func TestLimiterBoundsEntries(t *testing.T) {
+	logger.SetLevel("error")
+	l := &Limiter{
+		config: config.LockoutConfig{
+			Enabled:         true,
+			MaxUserFailures: 2,
+			Window:          time.Hour,
+			Duration:        time.Minute,
+			MaxDuration:     time.Hour,
+		},
+		entries: make(map[string]*lockoutEntry),
+	}
+
+	l.Fail("web", "alice", "10.0.0.1", "bad_password")
+	l.Fail("web", "alice", "10.0.0.1", "bad_password")
+	for i := range 2 * maxLockoutEntries {
+		l.Fail("web", fmt.Sprintf("guess-%d", i), "10.0.0.2", "unknown_user")
+	}
+
+	if got := len(l.entries); got > maxLockoutEntries {
+		t.Errorf("limiter holds %d entries, want at most %d", got, maxLockoutEntries)
+	}
+	if _, locked := l.Check("alice", "10.0.0.3"); !locked {
+		t.Error("lockout of alice was evicted by failures for other usernames")
+	}
+	if _, ok := l.entries[lockoutKey(LockoutUser, fmt.Sprintf("guess-%d", 2*maxLockoutEntries-1))]; !ok {
+		t.Error("most recent failure was not recorded")
+	}
+}
@@ -2,18 +2,30 @@ package config
 
 import (
 	"strings"
+	"time"
 
 	"github.com/spf13/viper"
 )
 
 type Config struct {
-	LogLevel string         `mapstructure:"log_level"` // debug, info, warn, error
-	Web      WebConfig      `mapstructure:"web"`
-	Printer  PrinterConfig  `mapstructure:"printer"`
-	IPP      IPPConfig      `mapstructure:"ipp"`
-	Database DatabaseConfig `mapstructure:"database"`
-	Auth     AuthConfig     `mapstructure:"auth"`
-	Storage  StorageConfig  `mapstructure:"storage"`
+	LogLevel   string           `mapstructure:"log_level"` // debug, info, warn, error
+	Web        WebConfig        `mapstructure:"web"`
+	Printer    PrinterConfig    `mapstructure:"printer"`
+	IPP        IPPConfig        `mapstructure:"ipp"`
+	Database   DatabaseConfig   `mapstructure:"database"`
+	Auth       AuthConfig       `mapstructure:"auth"`
+	Storage    StorageConfig    `mapstructure:"storage"`
+	Signing    SigningConfig    `mapstructure:"signing"`
+	Retention  RetentionConfig  `mapstructure:"retention"`
+	Quota      QuotaConfig      `mapstructure:"quota"`
+	Fsck       FsckConfig       `mapstructure:"fsck"`
+	Trash      TrashConfig      `mapstructure:"trash"`
+	Shares     SharesConfig     `mapstructure:"shares"`
+	Webhooks   WebhooksConfig   `mapstructure:"webhooks"`
+	Mail       MailConfig       `mapstructure:"mail"`
+	Forwarding ForwardingConfig `mapstructure:"forwarding"`
+	HotFolder  HotFolderConfig  `mapstructure:"hotfolder"`
+	IPRegistrations IPRegistrationsConfig `mapstructure:"ip_registrations"`
 }
 
 type WebConfig struct {
@@ -21,6 +33,7 @@ type WebConfig struct {
 	Host           string   `mapstructure:"host"`
 	TrustProxy     bool     `mapstructure:"trust_proxy"`      // Trust X-Forwarded-For headers
 	TrustedProxies []string `mapstructure:"trusted_proxies"`  // List of trusted proxy IPs/CIDRs
+	MaxUploadSize  int64    `mapstructure:"max_upload_size"`  // Largest document accepted by web uploads, in bytes
 }
 
 type PrinterConfig struct {
@@ -43,9 +56,10 @@ type IPPConfig struct {
 }
 
 type IPPAuthConfig struct {
-	AllowIP    bool   `mapstructure:"allow_ip"`    // Allow IP-based authentication (default: true)
-	AllowLogin bool   `mapstructure:"allow_login"` // Allow Basic/Digest authentication (default: true)
-	Realm      string `mapstructure:"realm"`       // HTTP Digest auth realm
+	AllowIP          bool     `mapstructure:"allow_ip"`          // Allow IP-based authentication (default: true)
+	AllowLogin       bool     `mapstructure:"allow_login"`       // Allow Basic/Digest authentication (default: true)
+	Realm            string   `mapstructure:"realm"`             // HTTP Digest auth realm
+	DigestAlgorithms []string `mapstructure:"digest_algorithms"` // Digest algorithms offered, most preferred first: SHA-256, SHA-512-256, MD5
 }
 
 type DatabaseConfig struct {
@@ -57,6 +71,17 @@ type AuthConfig struct {
 	JWTSecret    string     `mapstructure:"jwt_secret"`
 	OIDC         OIDCConfig `mapstructure:"oidc"`
 	AllowLocal   bool       `mapstructure:"allow_local"`
+	Lockout      LockoutConfig `mapstructure:"lockout"`
+}
+
+// LockoutConfig limits failed logins to the web UI and IPP Basic/Digest auth
+type LockoutConfig struct {
+	Enabled         bool          `mapstructure:"enabled"`
+	MaxUserFailures int           `mapstructure:"max_user_failures"` // Failed logins to an account before it is locked out
+	MaxIPFailures   int           `mapstructure:"max_ip_failures"`   // Failed logins from an IP address before it is locked out
+	Window          time.Duration `mapstructure:"window"`            // Failures further apart than this start counting from zero again
+	Duration        time.Duration `mapstructure:"duration"`          // First lockout, doubled for each further lockout in a row
+	MaxDuration     time.Duration `mapstructure:"max_duration"`      // Longest lockout
 }
 
 type OIDCConfig struct {
@@ -77,8 +102,145 @@ type ACLConfig struct {
 }
 
 type StorageConfig struct {
-	Path           string `mapstructure:"path"`
-	GhostscriptBin string `mapstructure:"ghostscript_bin"`
+	Path           string           `mapstructure:"path"`
+	GhostscriptBin string           `mapstructure:"ghostscript_bin"`
+	Backend        string           `mapstructure:"backend"` // local, s3
+	S3             S3Config         `mapstructure:"s3"`
+	Encryption     EncryptionConfig `mapstructure:"encryption"`
+}
+
+type EncryptionConfig struct {
+	Enabled          bool     `mapstructure:"enabled"`
+	Key              string   `mapstructure:"key"`                // Base64-encoded 256-bit master key
+	KeyFile          string   `mapstructure:"key_file"`           // File containing the base64-encoded master key (takes precedence over key)
+	PreviousKeys     []string `mapstructure:"previous_keys"`      // Earlier master keys, only used to read jobs not re-wrapped yet
+	PreviousKeyFiles []string `mapstructure:"previous_key_files"` // Files containing earlier master keys
+}
+
+type S3Config struct {
+	Endpoint         string `mapstructure:"endpoint"` // e.g. "http://minio:9000" (defaults to AWS)
+	Region           string `mapstructure:"region"`
+	Bucket           string `mapstructure:"bucket"`
+	Prefix           string `mapstructure:"prefix"` // Optional key prefix inside the bucket
+	AccessKey        string `mapstructure:"access_key"`
+	SecretKey        string `mapstructure:"secret_key"`
+	PathStyle        bool   `mapstructure:"path_style"`        // Use path-style URLs (required by most MinIO setups)
+	PresignDownloads bool   `mapstructure:"presign_downloads"` // Redirect downloads to presigned URLs instead of proxying
+}
+
+type SigningConfig struct {
+	Enabled  bool   `mapstructure:"enabled"`
+	KeyFile  string `mapstructure:"key_file"`  // PKCS#12 (.p12/.pfx) or PEM file with the private key
+	CertFile string `mapstructure:"cert_file"` // Optional PEM certificate chain (if not bundled in key_file)
+	Password string `mapstructure:"password"`  // PKCS#12 password
+	TSAURL   string `mapstructure:"tsa_url"`   // Optional RFC 3161 timestamp authority URL
+	Reason   string `mapstructure:"reason"`    // Signature reason shown in PDF viewers
+	Location string `mapstructure:"location"`  // Signature location shown in PDF viewers
+}
+
+type RetentionConfig struct {
+	Enabled  bool                       `mapstructure:"enabled"`
+	Interval time.Duration              `mapstructure:"interval"` // How often the retention task runs
+	Default  RetentionPolicy            `mapstructure:"default"`
+	Queues   map[string]RetentionPolicy `mapstructure:"queues"` // Per-queue overrides (raw, ipp, web)
+	Users    map[string]RetentionPolicy `mapstructure:"users"`  // Per-user overrides, keyed by username
+}
+
+// RetentionPolicy values of 0 inherit from the less specific policy, -1 disables the rule
+type RetentionPolicy struct {
+	OriginalDays int `mapstructure:"original_days"` // Delete original files (keeping the PDF) after N days
+	JobDays      int `mapstructure:"job_days"`      // Delete jobs with all their files after N days
+	KeepLast     int `mapstructure:"keep_last"`     // Keep only the newest N jobs per user
+	FailedHours  int `mapstructure:"failed_hours"`  // Delete failed jobs after N hours
+}
+
+type FsckConfig struct {
+	Enabled     bool          `mapstructure:"enabled"`      // Run the consistency check periodically inside serve
+	Interval    time.Duration `mapstructure:"interval"`     // How often the check runs
+	Repair      bool          `mapstructure:"repair"`       // Fix problems found by the periodic check instead of only reporting them
+	OrphanGrace time.Duration `mapstructure:"orphan_grace"` // Minimum age of an unreferenced file before it counts as orphaned
+}
+
+type TrashConfig struct {
+	Period        time.Duration `mapstructure:"period"`         // How long deleted jobs stay in the trash (0 deletes immediately)
+	PurgeInterval time.Duration `mapstructure:"purge_interval"` // How often expired jobs are purged
+}
+
+type SharesConfig struct {
+	Enabled       bool          `mapstructure:"enabled"`        // Allow users to create public download links for their PDFs
+	DefaultExpiry time.Duration `mapstructure:"default_expiry"` // Expiry used when a link is created without one
+	MaxExpiry     time.Duration `mapstructure:"max_expiry"`     // Longest expiry a link may have (0 = no limit)
+}
+
+type WebhooksConfig struct {
+	Enabled            bool          `mapstructure:"enabled"`              // Deliver webhooks (subscriptions can be managed either way)
+	Timeout            time.Duration `mapstructure:"timeout"`              // Timeout of a single delivery attempt
+	MaxAttempts        int           `mapstructure:"max_attempts"`         // Attempts before a delivery is marked failed
+	RetryBackoff       time.Duration `mapstructure:"retry_backoff"`        // Delay before the first retry, doubled for each further retry
+	AllowPrivateTarget bool          `mapstructure:"allow_private_target"` // Let user webhooks call loopback and private addresses
+	LogRetention       time.Duration `mapstructure:"log_retention"`        // How long delivery logs are kept
+}
+
+type MailConfig struct {
+	Enabled           bool          `mapstructure:"enabled"`             // Email finished PDFs to users who opted in
+	Host              string        `mapstructure:"host"`                // SMTP server
+	Port              int           `mapstructure:"port"`
+	Username          string        `mapstructure:"username"`            // Optional SMTP authentication
+	Password          string        `mapstructure:"password"`
+	Security          string        `mapstructure:"security"`            // starttls, tls or none
+	From              string        `mapstructure:"from"`                // Sender, e.g. "Zikzi <zikzi@example.com>"
+	Timeout           time.Duration `mapstructure:"timeout"`
+	MaxAttachmentSize int64         `mapstructure:"max_attachment_size"` // Larger PDFs are sent as a share link, in bytes
+	LinkExpiry        time.Duration `mapstructure:"link_expiry"`         // Expiry of share links sent instead of attachments
+	BaseURL           string        `mapstructure:"base_url"`            // Public URL of this server, used to build share links
+	Subject           string        `mapstructure:"subject"`             // Go template, executed with the print job
+	Body              string        `mapstructure:"body"`                // Go template, executed with the print job
+}
+
+type ForwardingConfig struct {
+	Printers []DownstreamPrinterConfig `mapstructure:"printers"`
+	Queues   map[string][]string       `mapstructure:"queues"`  // Intake queue (raw, ipp, web) to printers every job from it is forwarded to
+	Timeout  time.Duration             `mapstructure:"timeout"` // Timeout of sending a job to a printer
+}
+
+type DownstreamPrinterConfig struct {
+	Name     string `mapstructure:"name"`
+	Protocol string `mapstructure:"protocol"` // ipp, raw or lpr
+	Address  string `mapstructure:"address"`  // ipp://host:631/ipp/print, host:9100 or host:515
+	Queue    string `mapstructure:"queue"`    // LPR queue name
+	Document string `mapstructure:"document"` // pdf (converted PDF) or original (document as received)
+	Username string `mapstructure:"username"` // Optional IPP basic authentication
+	Password string `mapstructure:"password"`
+}
+
+type HotFolderConfig struct {
+	Rules         []HotFolderRule `mapstructure:"rules"`
+	RetryInterval time.Duration   `mapstructure:"retry_interval"` // How often failed exports are retried
+	MaxAttempts   int             `mapstructure:"max_attempts"`   // Attempts before an export is given up (0 = retry forever)
+}
+
+type HotFolderRule struct {
+	Name      string   `mapstructure:"name"`
+	Directory string   `mapstructure:"directory"` // Root of the exported tree
+	Path      string   `mapstructure:"path"`      // Path template below the directory, e.g. "{user}/{yyyy}/{mm}/{title}_{id}.pdf"
+	Mode      string   `mapstructure:"mode"`      // copy or link (hard link, copies when the file can't be linked)
+	Sidecar   bool     `mapstructure:"sidecar"`   // Write JSON metadata next to each PDF
+	Queues    []string `mapstructure:"queues"`    // Only export jobs from these intake queues (empty = all)
+	Users     []string `mapstructure:"users"`     // Only export jobs of these usernames (empty = all)
+}
+
+type IPRegistrationsConfig struct {
+	CheckInterval time.Duration `mapstructure:"check_interval"` // How often expired registrations are deactivated and warnings sent
+	WarnBefore    time.Duration `mapstructure:"warn_before"`    // Warn owners this long before a registration expires (0 = never)
+	RenewPeriod   time.Duration `mapstructure:"renew_period"`   // How far from now a renewal moves the expiry
+	ClaimCodeTTL  time.Duration `mapstructure:"claim_code_ttl"` // How long a claim code for pairing a printing machine is valid
+}
+
+// QuotaConfig holds the default per-user quotas. 0 means unlimited.
+type QuotaConfig struct {
+	MaxBytes         int64 `mapstructure:"max_bytes"`           // Total size of stored originals
+	MaxJobsPerDay    int   `mapstructure:"max_jobs_per_day"`    // Jobs submitted since midnight
+	MaxPagesPerMonth int   `mapstructure:"max_pages_per_month"` // Pages printed since the 1st of the month
 }
 
 func Load() (*Config, error) {
@@ -95,6 +257,7 @@ func Load() (*Config, error) {
 	// Defaults
 	viper.SetDefault("web.port", 8080)
 	viper.SetDefault("web.host", "0.0.0.0")
+	viper.SetDefault("web.max_upload_size", 50<<20)
 	viper.SetDefault("printer.port", 9100)
 	viper.SetDefault("printer.host", "0.0.0.0")
 	viper.SetDefault("printer.allow_unregistered_ips", false)
@@ -105,13 +268,88 @@ func Load() (*Config, error) {
 	viper.SetDefault("ipp.auth.allow_ip", true)
 	viper.SetDefault("ipp.auth.allow_login", true)
 	viper.SetDefault("ipp.auth.realm", "zikzi")
+	viper.SetDefault("ipp.auth.digest_algorithms", []string{"SHA-256", "SHA-512-256", "MD5"})
 	viper.SetDefault("database.driver", "sqlite")
 	viper.SetDefault("database.dsn", "zikzi.db")
 	viper.SetDefault("auth.allow_local", true)
 	viper.SetDefault("auth.oidc.auto_create_users", true)
+	viper.SetDefault("auth.lockout.enabled", true)
+	viper.SetDefault("auth.lockout.max_user_failures", 5)
+	viper.SetDefault("auth.lockout.max_ip_failures", 20)
+	viper.SetDefault("auth.lockout.window", "15m")
+	viper.SetDefault("auth.lockout.duration", "1m")
+	viper.SetDefault("auth.lockout.max_duration", "1h")
 	viper.SetDefault("log_level", "info")
 	viper.SetDefault("storage.path", "./data")
 	viper.SetDefault("storage.ghostscript_bin", "gs")
+	viper.SetDefault("storage.backend", "local")
+	viper.SetDefault("storage.s3.endpoint", "")
+	viper.SetDefault("storage.s3.region", "us-east-1")
+	viper.SetDefault("storage.s3.bucket", "")
+	viper.SetDefault("storage.s3.prefix", "")
+	viper.SetDefault("storage.s3.access_key", "")
+	viper.SetDefault("storage.s3.secret_key", "")
+	viper.SetDefault("storage.s3.path_style", false)
+	viper.SetDefault("storage.s3.presign_downloads", false)
+	viper.SetDefault("storage.encryption.enabled", false)
+	viper.SetDefault("storage.encryption.key", "")
+	viper.SetDefault("storage.encryption.key_file", "")
+	viper.SetDefault("signing.enabled", false)
+	viper.SetDefault("signing.reason", "Printed via Zikzi")
+	viper.SetDefault("retention.enabled", false)
+	viper.SetDefault("retention.interval", "1h")
+	viper.SetDefault("retention.default.original_days", 0)
+	viper.SetDefault("retention.default.job_days", 0)
+	viper.SetDefault("retention.default.keep_last", 0)
+	viper.SetDefault("retention.default.failed_hours", 0)
+	viper.SetDefault("fsck.enabled", false)
+	viper.SetDefault("fsck.interval", "24h")
+	viper.SetDefault("fsck.repair", false)
+	viper.SetDefault("fsck.orphan_grace", "1h")
+	viper.SetDefault("trash.period", "720h")
+	viper.SetDefault("trash.purge_interval", "1h")
+	viper.SetDefault("shares.enabled", true)
+	viper.SetDefault("shares.default_expiry", "168h")
+	viper.SetDefault("shares.max_expiry", "720h")
+	viper.SetDefault("webhooks.enabled", true)
+	viper.SetDefault("webhooks.timeout", "10s")
+	viper.SetDefault("webhooks.max_attempts", 6)
+	viper.SetDefault("webhooks.retry_backoff", "30s")
+	viper.SetDefault("webhooks.allow_private_target", false)
+	viper.SetDefault("webhooks.log_retention", "720h")
+	viper.SetDefault("mail.enabled", false)
+	viper.SetDefault("mail.host", "")
+	viper.SetDefault("mail.port", 587)
+	viper.SetDefault("mail.username", "")
+	viper.SetDefault("mail.password", "")
+	viper.SetDefault("mail.security", "starttls")
+	viper.SetDefault("mail.from", "")
+	viper.SetDefault("mail.timeout", "30s")
+	viper.SetDefault("mail.max_attachment_size", 10<<20)
+	viper.SetDefault("mail.link_expiry", "168h")
+	viper.SetDefault("mail.base_url", "")
+	viper.SetDefault("mail.subject", "Printed: {{.DocumentName}}")
+	viper.SetDefault("mail.body", `Your print job "{{.DocumentName}}" has finished.
+
+Pages: {{.PageCount}}
+Application: {{.AppName}}
+Printed at: {{.CreatedAt.Format "2006-01-02 15:04"}}
+{{if .Link}}
+The PDF is too large to attach. Download it before {{.LinkExpiresAt.Format "2006-01-02 15:04"}}:
+{{.Link}}
+{{else}}
+The PDF is attached.
+{{end}}`)
+	viper.SetDefault("forwarding.timeout", "60s")
+	viper.SetDefault("hotfolder.retry_interval", "5m")
+	viper.SetDefault("hotfolder.max_attempts", 10)
+	viper.SetDefault("ip_registrations.check_interval", "1h")
+	viper.SetDefault("ip_registrations.warn_before", "72h")
+	viper.SetDefault("ip_registrations.renew_period", "720h")
+	viper.SetDefault("ip_registrations.claim_code_ttl", "10m")
+	viper.SetDefault("quota.max_bytes", 0)
+	viper.SetDefault("quota.max_jobs_per_day", 0)
+	viper.SetDefault("quota.max_pages_per_month", 0)
 
 	if err := viper.ReadInConfig(); err != nil {
 		if _, ok := err.(viper.ConfigFileNotFoundError); !ok {
@@ -35,9 +35,69 @@ func Migrate(db *gorm.DB) error {
 		&models.User{},
 		&models.PrintJob{},
 		&models.IPRegistration{},
+		&models.IPClaimCode{},
 		&models.IPPToken{},
+		&models.IPPTokenUsage{},
+		&models.Folder{},
+		&models.Tag{},
+		&models.TagRule{},
+		&models.JobShare{},
+		&models.ShareAccess{},
+		&models.Webhook{},
+		&models.WebhookDelivery{},
+		&models.JobForward{},
+		&models.HotFolderExport{},
 	); err != nil {
 		return err
 	}
+	return indexIPRegistrations(db)
+}
+
+// indexIPRegistrations canonicalizes IP registrations created before range
+// support and fills in the range they cover
+func indexIPRegistrations(db *gorm.DB) error {
+	var registrations []models.IPRegistration
+	if err := db.Unscoped().Where("network_start IS NULL OR network_start = ''").Find(&registrations).Error; err != nil {
+		return err
+	}
+
+	for _, registration := range registrations {
+		if err := registration.SetAddress(registration.IPAddress); err != nil {
+			// Never matched a connection, leave it for the admin to clean up
+			continue
+		}
+		err := db.Unscoped().Model(&registration).
+			Select("ip_address", "network_start", "network_end", "prefix_len").Updates(&registration).Error
+		if err != nil {
+			// The canonical form is already registered, keep the original spelling
+			err = db.Unscoped().Model(&registration).
+				Select("network_start", "network_end", "prefix_len").Updates(&registration).Error
+		}
+		if err != nil {
+			return fmt.Errorf("failed to index IP registration %s: %w", registration.ID, err)
+		}
+	}
 	return nil
 }
+
+// HashIPPTokens converts IPP tokens still stored in plaintext to a bcrypt hash
+// and Digest hashes for the given realm, returning how many were converted
+func HashIPPTokens(db *gorm.DB, realm string) (int, error) {
+	var tokens []models.IPPToken
+	if err := db.Unscoped().Preload("User", func(tx *gorm.DB) *gorm.DB { return tx.Unscoped() }).
+		Where("token <> ''").Find(&tokens).Error; err != nil {
+		return 0, err
+	}
+
+	for i := range tokens {
+		token := &tokens[i]
+		if err := token.SetSecret(token.User.Username, realm, token.LegacyToken); err != nil {
+			return i, err
+		}
+		columns := append([]string{"token", "token_hash", "digest_realm"}, models.DigestHashColumns...)
+		if err := db.Unscoped().Model(token).Select(columns).Updates(token).Error; err != nil {
+			return i, fmt.Errorf("failed to convert token %s: %w", token.ID, err)
+		}
+	}
+	return len(tokens), nil
+}
@@ -0,0 +1,157 @@
+// Package events provides an in-process bus for job lifecycle events
+package events
+
+import (
+	"sync"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+)
+
+// Event types
+const (
+	JobCreated    = "job.created"
+	JobProcessing = "job.processing"
+	JobCompleted  = "job.completed"
+	JobFailed     = "job.failed"
+	JobDeleted    = "job.deleted"
+)
+
+// historySize is how many recent events are kept for reconnecting subscribers
+const historySize = 256
+
+// Event describes a change to a print job
+type Event struct {
+	ID     uint64           `json:"id"`
+	Type   string           `json:"type"`
+	Time   time.Time        `json:"time"`
+	JobID  string           `json:"job_id"`
+	UserID string           `json:"user_id,omitempty"` // Owner of the job, empty for orphaned jobs
+	Job    *models.PrintJob `json:"job,omitempty"`     // Snapshot of the job when the event was published
+}
+
+// Bus fans events out to subscribers. A nil *Bus discards everything,
+// so components can be used without one.
+type Bus struct {
+	mu      sync.Mutex
+	nextID  uint64
+	history []Event
+	subs    map[*Subscription]struct{}
+	closed  bool
+}
+
+// Subscription receives events from a Bus until it is closed
+type Subscription struct {
+	C   <-chan Event
+	ch  chan Event
+	bus *Bus
+}
+
+func NewBus() *Bus {
+	return &Bus{subs: make(map[*Subscription]struct{})}
+}
+
+// Publish delivers an event to all subscribers. Subscribers that fall behind miss events
+// rather than blocking the publisher.
+func (b *Bus) Publish(eventType string, job *models.PrintJob) {
+	if b == nil || job == nil {
+		return
+	}
+
+	snapshot := *job
+	snapshot.User = nil
+	event := Event{
+		Type:   eventType,
+		Time:   time.Now(),
+		JobID:  job.ID,
+		UserID: job.UserID,
+		Job:    &snapshot,
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+
+	b.nextID++
+	event.ID = b.nextID
+	b.history = append(b.history, event)
+	if len(b.history) > historySize {
+		b.history = b.history[len(b.history)-historySize:]
+	}
+
+	for sub := range b.subs {
+		select {
+		case sub.ch <- event:
+		default:
+			logger.Debug("Event bus: dropped %s for a slow subscriber", event.Type)
+		}
+	}
+}
+
+// Subscribe returns a subscription that receives events published from now on,
+// preceded by any recent events with an ID greater than lastID (0 for none)
+func (b *Bus) Subscribe(lastID uint64) *Subscription {
+	ch := make(chan Event, 64)
+	sub := &Subscription{C: ch, ch: ch, bus: b}
+	if b == nil {
+		close(ch)
+		return sub
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		close(ch)
+		return sub
+	}
+
+	if lastID > 0 {
+		for _, event := range b.history {
+			if event.ID <= lastID {
+				continue
+			}
+			select {
+			case ch <- event:
+			default:
+			}
+		}
+	}
+
+	b.subs[sub] = struct{}{}
+	return sub
+}
+
+// Close stops the subscription and closes its channel
+func (s *Subscription) Close() {
+	if s.bus == nil {
+		return
+	}
+
+	s.bus.mu.Lock()
+	defer s.bus.mu.Unlock()
+	if _, ok := s.bus.subs[s]; ok {
+		delete(s.bus.subs, s)
+		close(s.ch)
+	}
+}
+
+// Close ends all subscriptions and discards further events
+func (b *Bus) Close() {
+	if b == nil {
+		return
+	}
+
+	b.mu.Lock()
+	defer b.mu.Unlock()
+	if b.closed {
+		return
+	}
+	b.closed = true
+	for sub := range b.subs {
+		delete(b.subs, sub)
+		close(sub.ch)
+	}
+}
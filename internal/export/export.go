@@ -0,0 +1,215 @@
+// Package export writes print jobs into ZIP archives
+package export
+
+import (
+	"archive/zip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// maxNameLength limits the length of archive file names, excluding the extension
+const maxNameLength = 120
+
+// Filter selects the jobs to export. Zero values match everything.
+type Filter struct {
+	JobIDs []string
+	UserID string
+	From   time.Time // Created at or after
+	To     time.Time // Created before
+	Status string
+	TagID  string
+}
+
+// Query returns the jobs matching the filter, oldest first, with their tags loaded
+func (f Filter) Query(db *gorm.DB) *gorm.DB {
+	q := db.Model(&models.PrintJob{}).Preload("Tags").Order("created_at ASC")
+	if len(f.JobIDs) > 0 {
+		q = q.Where("id IN ?", f.JobIDs)
+	}
+	if f.UserID != "" {
+		q = q.Where("user_id = ?", f.UserID)
+	}
+	if !f.From.IsZero() {
+		q = q.Where("created_at >= ?", f.From)
+	}
+	if !f.To.IsZero() {
+		q = q.Where("created_at < ?", f.To)
+	}
+	if f.Status != "" {
+		q = q.Where("status = ?", f.Status)
+	}
+	if f.TagID != "" {
+		q = q.Where("id IN (?)", db.Table("print_job_tags").Select("print_job_id").Where("tag_id = ?", f.TagID))
+	}
+	return q
+}
+
+// Options controls what goes into the archive besides the PDFs
+type Options struct {
+	Originals bool // Add the original files under originals/
+	Manifest  bool // Add manifest.json describing every job
+}
+
+// Result summarizes a written archive
+type Result struct {
+	Jobs      int
+	PDFs      int
+	Originals int
+	Skipped   int // Files that could not be read
+}
+
+// ManifestEntry describes one job in manifest.json
+type ManifestEntry struct {
+	ID             string    `json:"id"`
+	DocumentName   string    `json:"document_name"`
+	UserID         string    `json:"user_id"`
+	Status         string    `json:"status"`
+	Queue          string    `json:"queue"`
+	PageCount      int       `json:"page_count"`
+	CreatedAt      time.Time `json:"created_at"`
+	Tags           []string  `json:"tags,omitempty"`
+	Note           string    `json:"note,omitempty"`
+	PDF            string    `json:"pdf,omitempty"` // Path inside the archive
+	PDFSHA256      string    `json:"pdf_sha256,omitempty"`
+	Original       string    `json:"original,omitempty"`
+	OriginalSHA256 string    `json:"original_sha256,omitempty"`
+	Errors         []string  `json:"errors,omitempty"`
+}
+
+// Write streams a ZIP archive of the given jobs to w. Files that cannot be read
+// are skipped and recorded in the manifest; only write errors abort the export.
+func Write(ctx context.Context, w io.Writer, store *storage.JobStore, jobs []models.PrintJob, opts Options) (Result, error) {
+	zw := zip.NewWriter(w)
+	result := Result{Jobs: len(jobs)}
+	names := make(map[string]bool)
+	manifest := make([]ManifestEntry, 0, len(jobs))
+
+	for i := range jobs {
+		job := &jobs[i]
+		base := uniqueName(names, job)
+
+		entry := ManifestEntry{
+			ID:           job.ID,
+			DocumentName: job.DocumentName,
+			UserID:       job.UserID,
+			Status:       job.Status,
+			Queue:        job.Queue,
+			PageCount:    job.PageCount,
+			CreatedAt:    job.CreatedAt,
+			Note:         job.Note,
+		}
+		for _, tag := range job.Tags {
+			entry.Tags = append(entry.Tags, tag.Name)
+		}
+
+		if job.PDFFile != "" {
+			name := base + ".pdf"
+			err := addFile(ctx, zw, store, job, job.PDFFile, name)
+			switch {
+			case err == nil:
+				result.PDFs++
+				entry.PDF = name
+				entry.PDFSHA256 = job.PDFSHA256
+			case errors.As(err, new(*readError)):
+				result.Skipped++
+				entry.Errors = append(entry.Errors, err.Error())
+			default:
+				return result, err
+			}
+		}
+
+		if opts.Originals && job.OriginalFile != "" {
+			name := "originals/" + base + path.Ext(job.OriginalFile)
+			err := addFile(ctx, zw, store, job, job.OriginalFile, name)
+			switch {
+			case err == nil:
+				result.Originals++
+				entry.Original = name
+				entry.OriginalSHA256 = job.OriginalSHA256
+			case errors.As(err, new(*readError)):
+				result.Skipped++
+				entry.Errors = append(entry.Errors, err.Error())
+			default:
+				return result, err
+			}
+		}
+
+		manifest = append(manifest, entry)
+	}
+
+	if opts.Manifest {
+		fw, err := zw.CreateHeader(&zip.FileHeader{Name: "manifest.json", Method: zip.Deflate, Modified: time.Now()})
+		if err != nil {
+			return result, err
+		}
+		enc := json.NewEncoder(fw)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(manifest); err != nil {
+			return result, err
+		}
+	}
+
+	return result, zw.Close()
+}
+
+// readError is returned for stored files that could not be opened
+type readError struct {
+	key string
+	err error
+}
+
+func (e *readError) Error() string {
+	return fmt.Sprintf("failed to read %s: %v", path.Base(e.key), e.err)
+}
+
+// addFile copies a stored job file into the archive
+func addFile(ctx context.Context, zw *zip.Writer, store *storage.JobStore, job *models.PrintJob, key, name string) error {
+	reader, err := store.Open(ctx, job, key)
+	if err != nil {
+		return &readError{key: key, err: err}
+	}
+	defer reader.Close()
+
+	// PDFs and PostScript barely compress, so store them as-is
+	fw, err := zw.CreateHeader(&zip.FileHeader{Name: name, Method: zip.Store, Modified: job.CreatedAt})
+	if err != nil {
+		return err
+	}
+
+	// A read failure halfway through leaves a truncated entry that can't be undone
+	_, err = io.Copy(fw, reader)
+	return err
+}
+
+// uniqueName derives an archive file name from the job's document name,
+// adding " (2)", " (3)", ... when it is already taken
+func uniqueName(used map[string]bool, job *models.PrintJob) string {
+	name := strings.Trim(utils.SanitizeFilename(job.DocumentName), " .")
+	if ext := path.Ext(name); utils.IsFileExtension(ext) {
+		name = strings.TrimRight(strings.TrimSuffix(name, ext), " .")
+	}
+	if runes := []rune(name); len(runes) > maxNameLength {
+		name = string(runes[:maxNameLength])
+	}
+	if name == "" {
+		name = job.ID
+	}
+
+	candidate := name
+	for n := 2; used[strings.ToLower(candidate)]; n++ {
+		candidate = fmt.Sprintf("%s (%d)", name, n)
+	}
+	used[strings.ToLower(candidate)] = true
+	return candidate
+}
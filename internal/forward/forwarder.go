@@ -0,0 +1,271 @@
+// Package forward prints archived jobs on downstream printers
+package forward
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"gorm.io/gorm"
+)
+
+// Downstream printer protocols
+const (
+	ProtocolIPP = "ipp"
+	ProtocolRaw = "raw"
+	ProtocolLPR = "lpr"
+)
+
+// ErrUnknownPrinter is returned for printer names that aren't configured
+var ErrUnknownPrinter = errors.New("unknown downstream printer")
+
+// document is a job file ready to be sent
+type document struct {
+	io.ReadCloser
+	Size   int64
+	Format string // MIME type
+	Name   string // Job name shown by the printer
+	User   string // Requesting user name
+}
+
+// Forwarder sends completed jobs to the downstream printers configured for
+// their queue or requested for the job
+type Forwarder struct {
+	config   config.ForwardingConfig
+	db       *gorm.DB
+	store    *storage.JobStore
+	printers map[string]config.DownstreamPrinterConfig
+}
+
+func NewForwarder(cfg config.ForwardingConfig, db *gorm.DB, store *storage.JobStore) (*Forwarder, error) {
+	printers := make(map[string]config.DownstreamPrinterConfig, len(cfg.Printers))
+	for _, p := range cfg.Printers {
+		if p.Name == "" {
+			return nil, errors.New("forwarding printer without a name")
+		}
+		if _, ok := printers[p.Name]; ok {
+			return nil, fmt.Errorf("forwarding printer %q is defined twice", p.Name)
+		}
+		switch p.Protocol {
+		case ProtocolIPP, ProtocolRaw, ProtocolLPR:
+		default:
+			return nil, fmt.Errorf("forwarding printer %q: protocol must be ipp, raw or lpr", p.Name)
+		}
+		if p.Address == "" {
+			return nil, fmt.Errorf("forwarding printer %q: address is required", p.Name)
+		}
+		switch p.Document {
+		case "":
+			p.Document = models.ForwardDocumentPDF
+		case models.ForwardDocumentPDF, models.ForwardDocumentOriginal:
+		default:
+			return nil, fmt.Errorf("forwarding printer %q: document must be pdf or original", p.Name)
+		}
+		printers[p.Name] = p
+	}
+
+	for queue, names := range cfg.Queues {
+		switch queue {
+		case models.JobQueueRaw, models.JobQueueIPP, models.JobQueueWeb:
+		default:
+			return nil, fmt.Errorf("forwarding queue %q: must be raw, ipp or web", queue)
+		}
+		for _, name := range names {
+			if _, ok := printers[name]; !ok {
+				return nil, fmt.Errorf("forwarding queue %q: %w %q", queue, ErrUnknownPrinter, name)
+			}
+		}
+	}
+
+	return &Forwarder{config: cfg, db: db, store: store, printers: printers}, nil
+}
+
+// Enabled reports whether any downstream printer is configured
+func (f *Forwarder) Enabled() bool {
+	return f != nil && len(f.printers) > 0
+}
+
+// Printers returns the configured downstream printers in configuration order
+func (f *Forwarder) Printers() []config.DownstreamPrinterConfig {
+	if f == nil {
+		return nil
+	}
+	list := make([]config.DownstreamPrinterConfig, 0, len(f.config.Printers))
+	for _, p := range f.config.Printers {
+		list = append(list, f.printers[p.Name])
+	}
+	return list
+}
+
+// ParsePrinters splits a comma-separated list of printer names, checking each one exists
+func (f *Forwarder) ParsePrinters(list string) ([]string, error) {
+	var names []string
+	for _, name := range strings.Split(list, ",") {
+		if name = strings.TrimSpace(name); name == "" {
+			continue
+		}
+		if f == nil {
+			return nil, fmt.Errorf("%w %q", ErrUnknownPrinter, name)
+		}
+		if _, ok := f.printers[name]; !ok {
+			return nil, fmt.Errorf("%w %q", ErrUnknownPrinter, name)
+		}
+		names = append(names, name)
+	}
+	return names, nil
+}
+
+// Run forwards completed jobs published on the bus until ctx is cancelled
+func (f *Forwarder) Run(ctx context.Context, bus *events.Bus) {
+	sub := bus.Subscribe(0)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if event.Type == events.JobCompleted {
+				go f.forwardCompleted(ctx, event.JobID)
+			}
+		}
+	}
+}
+
+// forwardCompleted sends a completed job to its queue's printers and the ones requested for it
+func (f *Forwarder) forwardCompleted(ctx context.Context, jobID string) {
+	var job models.PrintJob
+	if err := f.db.WithContext(ctx).Preload("User").First(&job, "id = ?", jobID).Error; err != nil {
+		return
+	}
+
+	requested, _ := f.ParsePrinters(job.ForwardTo)
+	seen := make(map[string]bool)
+	for _, name := range append(f.config.Queues[job.Queue], requested...) {
+		if seen[name] {
+			continue
+		}
+		seen[name] = true
+		f.Forward(ctx, &job, name, "")
+	}
+}
+
+// Forward sends a job to a downstream printer and records the outcome.
+// An empty document uses the printer's configured document.
+func (f *Forwarder) Forward(ctx context.Context, job *models.PrintJob, printer, doc string) (*models.JobForward, error) {
+	p, ok := f.printers[printer]
+	if !ok {
+		return nil, fmt.Errorf("%w %q", ErrUnknownPrinter, printer)
+	}
+	if doc == "" {
+		doc = p.Document
+	}
+
+	forward := &models.JobForward{
+		JobID:    job.ID,
+		Printer:  printer,
+		Document: doc,
+		Status:   models.ForwardPending,
+	}
+	if err := f.db.Create(forward).Error; err != nil {
+		return nil, err
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, f.config.Timeout)
+	defer cancel()
+
+	err := f.send(ctx, p, job, doc)
+	if err != nil {
+		logger.Warn("Forwarding: failed to send job %s to %s: %v", job.ID, printer, err)
+		forward.Status = models.ForwardFailed
+		forward.Error = err.Error()
+	} else {
+		logger.Info("Forwarding: sent job %s to %s", job.ID, printer)
+		now := time.Now()
+		forward.Status = models.ForwardSent
+		forward.SentAt = &now
+	}
+	if err := f.db.Save(forward).Error; err != nil {
+		logger.Error("Forwarding: failed to record forward of job %s: %v", job.ID, err)
+	}
+	return forward, nil
+}
+
+// send opens the job's document and sends it with the printer's protocol
+func (f *Forwarder) send(ctx context.Context, p config.DownstreamPrinterConfig, job *models.PrintJob, doc string) error {
+	d, err := f.open(ctx, job, doc)
+	if err != nil {
+		return err
+	}
+	defer d.Close()
+
+	switch p.Protocol {
+	case ProtocolIPP:
+		return sendIPP(ctx, p, d)
+	case ProtocolLPR:
+		return sendLPR(ctx, p, d)
+	default:
+		return sendRaw(ctx, p, d)
+	}
+}
+
+// open opens the document of a job that gets forwarded
+func (f *Forwarder) open(ctx context.Context, job *models.PrintJob, doc string) (*document, error) {
+	key, format := job.PDFFile, "application/pdf"
+	if doc == models.ForwardDocumentOriginal {
+		key, format = job.OriginalFile, documentFormat(job.OriginalFile)
+	}
+	if key == "" {
+		return nil, fmt.Errorf("job has no %s document", doc)
+	}
+
+	size, err := f.store.Size(ctx, job, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+	reader, err := f.store.Open(ctx, job, key)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read document: %w", err)
+	}
+
+	name := job.DocumentName
+	if name == "" {
+		name = job.ID
+	}
+	user := "zikzi"
+	if job.User != nil {
+		user = job.User.Username
+	}
+	return &document{ReadCloser: reader, Size: size, Format: format, Name: name, User: user}, nil
+}
+
+// documentFormat guesses the MIME type of an original document from its extension
+func documentFormat(key string) string {
+	switch strings.ToLower(path.Ext(key)) {
+	case ".pdf":
+		return "application/pdf"
+	case ".ps":
+		return "application/postscript"
+	case ".png":
+		return "image/png"
+	case ".jpg", ".jpeg":
+		return "image/jpeg"
+	case ".gif":
+		return "image/gif"
+	case ".txt":
+		return "text/plain"
+	}
+	return "application/octet-stream"
+}
@@ -0,0 +1,202 @@
+package forward
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/OpenPrinting/goipp"
+	"github.com/alex4386/zikzi/internal/config"
+)
+
+// lprHost identifies Zikzi in LPR control files
+const lprHost = "zikzi"
+
+// sendIPP submits the document with an IPP Print-Job request
+func sendIPP(ctx context.Context, p config.DownstreamPrinterConfig, d *document) error {
+	printerURI, err := url.Parse(p.Address)
+	if err != nil {
+		return fmt.Errorf("invalid IPP printer URL: %w", err)
+	}
+
+	// IPP runs over HTTP, ipp:// defaults to port 631
+	endpoint := *printerURI
+	switch printerURI.Scheme {
+	case "ipp", "ipps":
+		if endpoint.Port() == "" {
+			endpoint.Host = net.JoinHostPort(endpoint.Hostname(), "631")
+		}
+		endpoint.Scheme = "http"
+		if printerURI.Scheme == "ipps" {
+			endpoint.Scheme = "https"
+		}
+	case "http", "https":
+	default:
+		return fmt.Errorf("unsupported IPP printer URL scheme %q", printerURI.Scheme)
+	}
+
+	msg := goipp.NewRequest(goipp.DefaultVersion, goipp.OpPrintJob, 1)
+	msg.Operation.Add(goipp.MakeAttribute("attributes-charset", goipp.TagCharset, goipp.String("utf-8")))
+	msg.Operation.Add(goipp.MakeAttribute("attributes-natural-language", goipp.TagLanguage, goipp.String("en-US")))
+	msg.Operation.Add(goipp.MakeAttribute("printer-uri", goipp.TagURI, goipp.String(p.Address)))
+	msg.Operation.Add(goipp.MakeAttribute("requesting-user-name", goipp.TagName, goipp.String(d.User)))
+	msg.Operation.Add(goipp.MakeAttribute("job-name", goipp.TagName, goipp.String(d.Name)))
+	msg.Operation.Add(goipp.MakeAttribute("document-format", goipp.TagMimeType, goipp.String(d.Format)))
+	payload, err := msg.EncodeBytes()
+	if err != nil {
+		return fmt.Errorf("failed to encode IPP request: %w", err)
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, endpoint.String(),
+		io.MultiReader(bytes.NewReader(payload), d))
+	if err != nil {
+		return err
+	}
+	req.ContentLength = int64(len(payload)) + d.Size
+	req.Header.Set("Content-Type", goipp.ContentType)
+	req.Header.Set("Accept", goipp.ContentType)
+	if p.Username != "" {
+		req.SetBasicAuth(p.Username, p.Password)
+	}
+
+	resp, err := http.DefaultClient.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("printer responded with HTTP %s", resp.Status)
+	}
+
+	var result goipp.Message
+	if err := result.Decode(resp.Body); err != nil {
+		return fmt.Errorf("invalid IPP response: %w", err)
+	}
+	if status := goipp.Status(result.Code); status >= 0x0100 {
+		return fmt.Errorf("printer rejected the job: %s", status)
+	}
+	return nil
+}
+
+// sendRaw streams the document to a raw (AppSocket/JetDirect) port
+func sendRaw(ctx context.Context, p config.DownstreamPrinterConfig, d *document) error {
+	conn, err := dial(ctx, p.Address, "9100")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+
+	if _, err := io.Copy(conn, d); err != nil {
+		return fmt.Errorf("failed to send document: %w", err)
+	}
+
+	// Signal the end of the job and wait for the printer to close the connection
+	if tcp, ok := conn.(*net.TCPConn); ok {
+		if err := tcp.CloseWrite(); err != nil {
+			return err
+		}
+	}
+	io.Copy(io.Discard, conn)
+	return nil
+}
+
+// sendLPR submits the document to an LPD queue (RFC 1179)
+func sendLPR(ctx context.Context, p config.DownstreamPrinterConfig, d *document) error {
+	queue := p.Queue
+	if queue == "" {
+		queue = "lp"
+	}
+
+	conn, err := dial(ctx, p.Address, "515")
+	if err != nil {
+		return err
+	}
+	defer conn.Close()
+	r := bufio.NewReader(conn)
+
+	n, err := rand.Int(rand.Reader, big.NewInt(1000))
+	if err != nil {
+		return err
+	}
+	jobNumber := fmt.Sprintf("%03d", n.Int64())
+	dataFile := "dfA" + jobNumber + lprHost
+	control := fmt.Sprintf("H%s\nP%s\nJ%s\nN%s\nl%s\nU%s\n",
+		lprHost, lprField(d.User), lprField(d.Name), lprField(d.Name), dataFile, dataFile)
+
+	// Receive a printer job
+	if err := lprCommand(conn, r, "\x02"+queue+"\n"); err != nil {
+		return fmt.Errorf("queue %q: %w", queue, err)
+	}
+
+	// Control file
+	if err := lprCommand(conn, r, fmt.Sprintf("\x02%d cfA%s%s\n", len(control), jobNumber, lprHost)); err != nil {
+		return err
+	}
+	if err := lprCommand(conn, r, control+"\x00"); err != nil {
+		return err
+	}
+
+	// Data file
+	if err := lprCommand(conn, r, fmt.Sprintf("\x03%d %s\n", d.Size, dataFile)); err != nil {
+		return err
+	}
+	if _, err := io.Copy(conn, d); err != nil {
+		return fmt.Errorf("failed to send document: %w", err)
+	}
+	return lprCommand(conn, r, "\x00")
+}
+
+// lprCommand writes an LPR command and waits for the positive acknowledgement
+func lprCommand(conn net.Conn, r *bufio.Reader, cmd string) error {
+	if _, err := io.WriteString(conn, cmd); err != nil {
+		return err
+	}
+	ack, err := r.ReadByte()
+	if err != nil {
+		return fmt.Errorf("no acknowledgement from LPD: %w", err)
+	}
+	if ack != 0 {
+		return errors.New("LPD refused the job")
+	}
+	return nil
+}
+
+// lprField keeps a value on one control file line
+func lprField(s string) string {
+	s = strings.NewReplacer("\n", " ", "\r", " ", "\x00", "").Replace(s)
+	for len(s) > 99 {
+		_, size := utf8.DecodeLastRuneInString(s)
+		s = s[:len(s)-size]
+	}
+	return s
+}
+
+// dial connects to host:port, using the default port when none is given
+func dial(ctx context.Context, address, defaultPort string) (net.Conn, error) {
+	if _, _, err := net.SplitHostPort(address); err != nil {
+		address = net.JoinHostPort(address, defaultPort)
+	}
+
+	var dialer net.Dialer
+	conn, err := dialer.DialContext(ctx, "tcp", address)
+	if err != nil {
+		return nil, err
+	}
+	if deadline, ok := ctx.Deadline(); ok {
+		conn.SetDeadline(deadline)
+	} else {
+		conn.SetDeadline(time.Now().Add(time.Minute))
+	}
+	return conn, nil
+}
@@ -0,0 +1,454 @@
+// Package hotfolder copies completed PDFs into watched directories
+package hotfolder
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Export modes
+const (
+	ModeCopy = "copy"
+	ModeLink = "link"
+)
+
+// DefaultPath is the path template of rules that don't set one
+const DefaultPath = "{user}/{yyyy}/{mm}/{title}_{id}.pdf"
+
+// staleAfter is how long a pending export may run before it's considered abandoned
+const staleAfter = 10 * time.Minute
+
+var placeholderPattern = regexp.MustCompile(`\{([a-z_]+)\}`)
+
+// placeholders lists the values available in path templates
+var placeholders = map[string]func(job *models.PrintJob) string{
+	"id":      func(job *models.PrintJob) string { return job.ID },
+	"title":   title,
+	"user":    username,
+	"user_id": func(job *models.PrintJob) string { return job.UserID },
+	"queue":   func(job *models.PrintJob) string { return job.Queue },
+	"app":     func(job *models.PrintJob) string { return job.AppName },
+	"host":    func(job *models.PrintJob) string { return job.Hostname },
+	"yyyy":    func(job *models.PrintJob) string { return job.CreatedAt.Format("2006") },
+	"mm":      func(job *models.PrintJob) string { return job.CreatedAt.Format("01") },
+	"dd":      func(job *models.PrintJob) string { return job.CreatedAt.Format("02") },
+	"hour":    func(job *models.PrintJob) string { return job.CreatedAt.Format("15") },
+	"minute":  func(job *models.PrintJob) string { return job.CreatedAt.Format("04") },
+}
+
+// ErrUnknownRule is returned for exports of rules that are no longer configured
+var ErrUnknownRule = errors.New("unknown export rule")
+
+// Metadata is written to the optional sidecar file
+type Metadata struct {
+	ID           string     `json:"id"`
+	DocumentName string     `json:"document_name"`
+	UserID       string     `json:"user_id,omitempty"`
+	Username     string     `json:"username,omitempty"`
+	Queue        string     `json:"queue"`
+	AppName      string     `json:"app_name,omitempty"`
+	Hostname     string     `json:"hostname,omitempty"`
+	SourceIP     string     `json:"source_ip,omitempty"`
+	PageCount    int        `json:"page_count"`
+	PDFSHA256    string     `json:"pdf_sha256,omitempty"`
+	Signed       bool       `json:"signed"`
+	Tags         []string   `json:"tags,omitempty"`
+	Note         string     `json:"note,omitempty"`
+	CreatedAt    time.Time  `json:"created_at"`
+	ProcessedAt  *time.Time `json:"processed_at,omitempty"`
+	Rule         string     `json:"rule"`
+}
+
+type rule struct {
+	config.HotFolderRule
+	queues map[string]bool
+	users  map[string]bool
+}
+
+// matches reports whether the rule exports the job
+func (r *rule) matches(job *models.PrintJob) bool {
+	if len(r.queues) > 0 && !r.queues[job.Queue] {
+		return false
+	}
+	if len(r.users) > 0 && (job.User == nil || !r.users[job.User.Username]) {
+		return false
+	}
+	return true
+}
+
+// Exporter writes the PDFs of completed jobs to the directories of matching rules
+type Exporter struct {
+	config config.HotFolderConfig
+	db     *gorm.DB
+	store  *storage.JobStore
+	rules  []*rule
+}
+
+func NewExporter(cfg config.HotFolderConfig, db *gorm.DB, store *storage.JobStore) (*Exporter, error) {
+	seen := make(map[string]bool)
+	var rules []*rule
+	for _, rc := range cfg.Rules {
+		if rc.Name == "" {
+			return nil, errors.New("hot folder rule without a name")
+		}
+		if seen[rc.Name] {
+			return nil, fmt.Errorf("hot folder rule %q is defined twice", rc.Name)
+		}
+		seen[rc.Name] = true
+
+		if rc.Directory == "" {
+			return nil, fmt.Errorf("hot folder rule %q: directory is required", rc.Name)
+		}
+		if rc.Path == "" {
+			rc.Path = DefaultPath
+		}
+		switch rc.Mode {
+		case "":
+			rc.Mode = ModeCopy
+		case ModeCopy, ModeLink:
+		default:
+			return nil, fmt.Errorf("hot folder rule %q: mode must be copy or link", rc.Name)
+		}
+		for _, match := range placeholderPattern.FindAllStringSubmatch(rc.Path, -1) {
+			if _, ok := placeholders[match[1]]; !ok {
+				return nil, fmt.Errorf("hot folder rule %q: unknown placeholder %s", rc.Name, match[0])
+			}
+		}
+		if _, err := render(rc.Path, &models.PrintJob{ID: "sample", CreatedAt: time.Now()}); err != nil {
+			return nil, fmt.Errorf("hot folder rule %q: %w", rc.Name, err)
+		}
+
+		r := &rule{HotFolderRule: rc, queues: make(map[string]bool), users: make(map[string]bool)}
+		for _, queue := range rc.Queues {
+			r.queues[queue] = true
+		}
+		for _, user := range rc.Users {
+			r.users[user] = true
+		}
+		rules = append(rules, r)
+	}
+
+	return &Exporter{config: cfg, db: db, store: store, rules: rules}, nil
+}
+
+// Enabled reports whether any export rule is configured
+func (e *Exporter) Enabled() bool {
+	return e != nil && len(e.rules) > 0
+}
+
+func (e *Exporter) rule(name string) *rule {
+	for _, r := range e.rules {
+		if r.Name == name {
+			return r
+		}
+	}
+	return nil
+}
+
+// Run exports completed jobs published on the bus and retries failed exports until ctx is cancelled
+func (e *Exporter) Run(ctx context.Context, bus *events.Bus) {
+	sub := bus.Subscribe(0)
+	defer sub.Close()
+
+	ticker := time.NewTicker(e.config.RetryInterval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if event.Type == events.JobCompleted {
+				go e.exportJob(ctx, event.JobID)
+			}
+		case <-ticker.C:
+			e.retryFailed(ctx)
+		}
+	}
+}
+
+// exportJob exports a completed job for every matching rule
+func (e *Exporter) exportJob(ctx context.Context, jobID string) {
+	job, err := e.loadJob(ctx, jobID)
+	if err != nil {
+		return
+	}
+
+	for _, r := range e.rules {
+		if !r.matches(job) {
+			continue
+		}
+
+		export := models.HotFolderExport{JobID: job.ID, Rule: r.Name}
+		if err := e.db.WithContext(ctx).
+			Where(models.HotFolderExport{JobID: job.ID, Rule: r.Name}).
+			Attrs(models.HotFolderExport{Status: models.ExportPending}).
+			FirstOrCreate(&export).Error; err != nil {
+			logger.Error("Hot folder: failed to track export of job %s for %s: %v", job.ID, r.Name, err)
+			continue
+		}
+		e.attempt(ctx, r, job, &export)
+	}
+}
+
+// retryFailed retries failed exports and ones abandoned while pending
+func (e *Exporter) retryFailed(ctx context.Context) {
+	names := make([]string, 0, len(e.rules))
+	for _, r := range e.rules {
+		names = append(names, r.Name)
+	}
+	query := e.db.WithContext(ctx).
+		Where("rule IN ?", names).
+		Where("status = ? OR (status = ? AND updated_at < ?)",
+			models.ExportFailed, models.ExportPending, time.Now().Add(-staleAfter))
+	if e.config.MaxAttempts > 0 {
+		query = query.Where("attempts < ?", e.config.MaxAttempts)
+	}
+
+	var exports []models.HotFolderExport
+	if err := query.Order("updated_at").Limit(100).Find(&exports).Error; err != nil {
+		logger.Error("Hot folder: failed to load failed exports: %v", err)
+		return
+	}
+
+	for i := range exports {
+		if _, err := e.Retry(ctx, &exports[i]); err != nil && !errors.Is(err, gorm.ErrRecordNotFound) {
+			logger.Warn("Hot folder: cannot retry export %s: %v", exports[i].ID, err)
+		}
+	}
+}
+
+// Retry attempts an export again
+func (e *Exporter) Retry(ctx context.Context, export *models.HotFolderExport) (*models.HotFolderExport, error) {
+	r := e.rule(export.Rule)
+	if r == nil {
+		return nil, fmt.Errorf("%w %q", ErrUnknownRule, export.Rule)
+	}
+
+	// Jobs in the trash are skipped until they're restored
+	job, err := e.loadJob(ctx, export.JobID)
+	if err != nil {
+		return nil, err
+	}
+
+	e.attempt(ctx, r, job, export)
+	return export, nil
+}
+
+func (e *Exporter) loadJob(ctx context.Context, jobID string) (*models.PrintJob, error) {
+	var job models.PrintJob
+	if err := e.db.WithContext(ctx).Preload("User").Preload("Tags").First(&job, "id = ?", jobID).Error; err != nil {
+		return nil, err
+	}
+	return &job, nil
+}
+
+// attempt writes the job's PDF for a rule and records the outcome
+func (e *Exporter) attempt(ctx context.Context, r *rule, job *models.PrintJob, export *models.HotFolderExport) {
+	target, err := e.write(ctx, r, job)
+
+	export.Attempts++
+	export.Path = target
+	if err != nil {
+		logger.Warn("Hot folder: failed to export job %s for %s: %v", job.ID, r.Name, err)
+		export.Status = models.ExportFailed
+		export.Error = err.Error()
+	} else {
+		logger.Debug("Hot folder: exported job %s to %s", job.ID, target)
+		now := time.Now()
+		export.Status = models.ExportExported
+		export.Error = ""
+		export.ExportedAt = &now
+	}
+
+	if err := e.db.Save(export).Error; err != nil {
+		logger.Error("Hot folder: failed to record export of job %s: %v", job.ID, err)
+	}
+}
+
+// write exports the PDF, and the sidecar before it so watchers picking up the
+// PDF find its metadata, returning the path of the PDF
+func (e *Exporter) write(ctx context.Context, r *rule, job *models.PrintJob) (string, error) {
+	if job.PDFFile == "" {
+		return "", errors.New("job has no PDF")
+	}
+
+	rel, err := render(r.Path, job)
+	if err != nil {
+		return "", err
+	}
+	target := filepath.Join(r.Directory, rel)
+	if err := os.MkdirAll(filepath.Dir(target), 0755); err != nil {
+		return target, err
+	}
+
+	if r.Sidecar {
+		sidecar := strings.TrimSuffix(target, filepath.Ext(target)) + ".json"
+		data, err := json.MarshalIndent(metadata(r, job), "", "  ")
+		if err != nil {
+			return target, err
+		}
+		if err := writeAtomic(sidecar, func(f *os.File) error {
+			_, err := f.Write(append(data, '\n'))
+			return err
+		}); err != nil {
+			return target, fmt.Errorf("failed to write sidecar: %w", err)
+		}
+	}
+
+	if r.Mode == ModeLink {
+		if source, ok := e.store.LocalPath(job, job.PDFFile); ok {
+			err := linkAtomic(source, target)
+			if err == nil {
+				return target, nil
+			}
+			logger.Debug("Hot folder: cannot link %s, copying instead: %v", source, err)
+		}
+	}
+
+	reader, err := e.store.Open(ctx, job, job.PDFFile)
+	if err != nil {
+		return target, fmt.Errorf("failed to read PDF: %w", err)
+	}
+	defer reader.Close()
+
+	return target, writeAtomic(target, func(f *os.File) error {
+		_, err := io.Copy(f, reader)
+		return err
+	})
+}
+
+// writeAtomic writes a file under a temporary name and renames it into place,
+// so watchers never see partial content
+func writeAtomic(target string, write func(f *os.File) error) error {
+	tmp, err := os.CreateTemp(filepath.Dir(target), ".zikzi-*.tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if err := write(tmp); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Sync(); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+	return os.Rename(tmp.Name(), target)
+}
+
+// linkAtomic hard-links source to target, replacing any existing file
+func linkAtomic(source, target string) error {
+	tmp := filepath.Join(filepath.Dir(target), ".zikzi-"+utils.GenerateShortID()+".tmp")
+	if err := os.Link(source, tmp); err != nil {
+		return err
+	}
+	if err := os.Rename(tmp, target); err != nil {
+		os.Remove(tmp)
+		return err
+	}
+	return nil
+}
+
+// render fills in a path template. Values can't contain path separators,
+// so the result always stays below the rule's directory.
+func render(tmpl string, job *models.PrintJob) (string, error) {
+	rendered := placeholderPattern.ReplaceAllStringFunc(tmpl, func(match string) string {
+		value, ok := placeholders[match[1:len(match)-1]]
+		if !ok {
+			return match
+		}
+		return pathSegment(value(job))
+	})
+
+	rel := filepath.Clean(filepath.FromSlash(rendered))
+	if !filepath.IsLocal(rel) {
+		return "", fmt.Errorf("path %q leaves the export directory", rendered)
+	}
+	return rel, nil
+}
+
+// pathSegment makes a value safe to use as (part of) a file name
+func pathSegment(value string) string {
+	value = strings.TrimSpace(utils.SanitizeFilename(value))
+	value = strings.TrimLeft(value, ".")
+	if value == "" {
+		return "_"
+	}
+	if runes := []rune(value); len(runes) > 120 {
+		value = string(runes[:120])
+	}
+	return value
+}
+
+// title is the document name without its file extension
+func title(job *models.PrintJob) string {
+	name := strings.TrimSpace(job.DocumentName)
+	if ext := path.Ext(name); utils.IsFileExtension(ext) {
+		name = strings.TrimSuffix(name, ext)
+	}
+	if name == "" {
+		return job.ID
+	}
+	return name
+}
+
+func username(job *models.PrintJob) string {
+	if job.User == nil {
+		return "unassigned"
+	}
+	return job.User.Username
+}
+
+func metadata(r *rule, job *models.PrintJob) Metadata {
+	meta := Metadata{
+		ID:           job.ID,
+		DocumentName: job.DocumentName,
+		UserID:       job.UserID,
+		Queue:        job.Queue,
+		AppName:      job.AppName,
+		Hostname:     job.Hostname,
+		SourceIP:     job.SourceIP,
+		PageCount:    job.PageCount,
+		PDFSHA256:    job.PDFSHA256,
+		Signed:       job.Signed,
+		Note:         job.Note,
+		CreatedAt:    job.CreatedAt,
+		ProcessedAt:  job.ProcessedAt,
+		Rule:         r.Name,
+	}
+	if job.User != nil {
+		meta.Username = job.User.Username
+	}
+	for _, tag := range job.Tags {
+		meta.Tags = append(meta.Tags, tag.Name)
+	}
+	return meta
+}
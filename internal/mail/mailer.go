@@ -0,0 +1,418 @@
+// Package mail emails finished PDFs to users over SMTP
+package mail
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/tls"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
+	"mime/multipart"
+	"mime/quotedprintable"
+	"net"
+	netmail "net/mail"
+	"net/smtp"
+	"net/textproto"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Connection security modes
+const (
+	SecurityStartTLS = "starttls"
+	SecurityTLS      = "tls"
+	SecurityNone     = "none"
+)
+
+// Message is a plain text email with an optional attachment
+type Message struct {
+	To         string
+	Subject    string
+	Body       string
+	Attachment *Attachment
+}
+
+// Attachment is a file attached to a message
+type Attachment struct {
+	Filename    string
+	ContentType string
+	Data        []byte
+}
+
+// TemplateData is what the subject and body templates are executed with.
+// All PrintJob fields are available directly, e.g. {{.DocumentName}}.
+type TemplateData struct {
+	*models.PrintJob
+	Link          string    // Share link, set when the PDF was too large to attach
+	LinkExpiresAt time.Time // Expiry of the share link
+}
+
+// Mailer sends the PDFs of completed jobs to users who asked for them
+type Mailer struct {
+	config  config.MailConfig
+	shares  config.SharesConfig
+	db      *gorm.DB
+	store   *storage.JobStore
+	from    *netmail.Address
+	subject *template.Template
+	body    *template.Template
+}
+
+func NewMailer(cfg config.MailConfig, sharesCfg config.SharesConfig, db *gorm.DB, store *storage.JobStore) (*Mailer, error) {
+	switch cfg.Security {
+	case SecurityStartTLS, SecurityTLS, SecurityNone:
+	default:
+		return nil, fmt.Errorf("mail.security must be starttls, tls or none, got %q", cfg.Security)
+	}
+	if cfg.Host == "" {
+		return nil, errors.New("mail.host is required")
+	}
+	from, err := netmail.ParseAddress(cfg.From)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mail.from: %w", err)
+	}
+
+	subject, err := template.New("subject").Parse(cfg.Subject)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mail.subject template: %w", err)
+	}
+	body, err := template.New("body").Parse(cfg.Body)
+	if err != nil {
+		return nil, fmt.Errorf("invalid mail.body template: %w", err)
+	}
+
+	// Catch references to fields that don't exist before the first job does
+	sample := TemplateData{PrintJob: &models.PrintJob{}}
+	if err := subject.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid mail.subject template: %w", err)
+	}
+	if err := body.Execute(io.Discard, sample); err != nil {
+		return nil, fmt.Errorf("invalid mail.body template: %w", err)
+	}
+
+	return &Mailer{
+		config:  cfg,
+		shares:  sharesCfg,
+		db:      db,
+		store:   store,
+		from:    from,
+		subject: subject,
+		body:    body,
+	}, nil
+}
+
+// Run emails completed jobs published on the bus until ctx is cancelled
+func (m *Mailer) Run(ctx context.Context, bus *events.Bus) {
+	sub := bus.Subscribe(0)
+	defer sub.Close()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if event.Type == events.JobCompleted && event.UserID != "" {
+				go m.deliver(ctx, event.JobID)
+			}
+		}
+	}
+}
+
+// deliver emails a job to its owner if they opted in and records the outcome on the job
+func (m *Mailer) deliver(ctx context.Context, jobID string) {
+	var job models.PrintJob
+	if err := m.db.WithContext(ctx).Preload("User").First(&job, "id = ?", jobID).Error; err != nil {
+		return
+	}
+	if job.User == nil || !job.User.EmailPrints || job.User.Email == "" || job.PDFFile == "" {
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if err := m.SendJob(ctx, &job, job.User.Email); err != nil {
+		logger.Warn("Mail: failed to email job %s to %s: %v", job.ID, job.User.Email, err)
+		updates["email_status"] = models.EmailStatusFailed
+		updates["email_error"] = err.Error()
+	} else {
+		logger.Info("Mail: emailed job %s to %s", job.ID, job.User.Email)
+		updates["email_status"] = models.EmailStatusSent
+		updates["email_error"] = ""
+		updates["emailed_at"] = time.Now()
+	}
+	if err := m.db.Model(&job).UpdateColumns(updates).Error; err != nil {
+		logger.Error("Mail: failed to record email status of job %s: %v", job.ID, err)
+	}
+}
+
+// SendJob emails the job's PDF to the given address, attached or as a share
+// link when it exceeds the attachment limit
+func (m *Mailer) SendJob(ctx context.Context, job *models.PrintJob, to string) error {
+	size, err := m.store.Size(ctx, job, job.PDFFile)
+	if err != nil {
+		return fmt.Errorf("failed to read PDF: %w", err)
+	}
+
+	data := TemplateData{PrintJob: job}
+	var attachment *Attachment
+	if size <= m.config.MaxAttachmentSize {
+		reader, err := m.store.Open(ctx, job, job.PDFFile)
+		if err != nil {
+			return fmt.Errorf("failed to read PDF: %w", err)
+		}
+		pdf, err := io.ReadAll(reader)
+		reader.Close()
+		if err != nil {
+			return fmt.Errorf("failed to read PDF: %w", err)
+		}
+		attachment = &Attachment{Filename: pdfFilename(job), ContentType: "application/pdf", Data: pdf}
+	} else {
+		data.Link, data.LinkExpiresAt, err = m.shareLink(ctx, job)
+		if err != nil {
+			return err
+		}
+	}
+
+	var subject, body strings.Builder
+	if err := m.subject.Execute(&subject, data); err != nil {
+		return fmt.Errorf("failed to render subject: %w", err)
+	}
+	if err := m.body.Execute(&body, data); err != nil {
+		return fmt.Errorf("failed to render body: %w", err)
+	}
+
+	return m.Send(ctx, &Message{
+		To:         to,
+		Subject:    strings.TrimSpace(subject.String()),
+		Body:       body.String(),
+		Attachment: attachment,
+	})
+}
+
+// shareLink creates a share link for a PDF too large to attach
+func (m *Mailer) shareLink(ctx context.Context, job *models.PrintJob) (string, time.Time, error) {
+	if !m.shares.Enabled {
+		return "", time.Time{}, fmt.Errorf("PDF exceeds the attachment limit of %d bytes and share links are disabled", m.config.MaxAttachmentSize)
+	}
+	if m.config.BaseURL == "" {
+		return "", time.Time{}, fmt.Errorf("PDF exceeds the attachment limit of %d bytes and mail.base_url is not set", m.config.MaxAttachmentSize)
+	}
+
+	expiry := m.config.LinkExpiry
+	if m.shares.MaxExpiry > 0 && expiry > m.shares.MaxExpiry {
+		expiry = m.shares.MaxExpiry
+	}
+
+	token, err := models.GenerateShareToken()
+	if err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create share link: %w", err)
+	}
+	share := models.JobShare{
+		JobID:     job.ID,
+		UserID:    job.UserID,
+		TokenHash: models.HashShareToken(token),
+		ExpiresAt: time.Now().Add(expiry),
+	}
+	if err := m.db.WithContext(ctx).Create(&share).Error; err != nil {
+		return "", time.Time{}, fmt.Errorf("failed to create share link: %w", err)
+	}
+
+	link := strings.TrimRight(m.config.BaseURL, "/") + "/api/v1/shares/" + token + "/pdf"
+	return link, share.ExpiresAt, nil
+}
+
+// pdfFilename names the attachment after the document
+func pdfFilename(job *models.PrintJob) string {
+	name := strings.TrimSpace(utils.SanitizeFilename(job.DocumentName))
+	name = strings.TrimSuffix(name, ".pdf")
+	if name == "" {
+		name = job.ID
+	}
+	return name + ".pdf"
+}
+
+// SendTest sends a short message to check the SMTP settings
+func (m *Mailer) SendTest(ctx context.Context, to string) error {
+	return m.Send(ctx, &Message{
+		To:      to,
+		Subject: "Zikzi test message",
+		Body:    "This is a test message from Zikzi. Email delivery is working.\n",
+	})
+}
+
+// Send delivers a message through the configured SMTP server
+func (m *Mailer) Send(ctx context.Context, msg *Message) error {
+	to, err := netmail.ParseAddress(msg.To)
+	if err != nil {
+		return fmt.Errorf("invalid recipient: %w", err)
+	}
+	data, err := m.compose(msg, to)
+	if err != nil {
+		return err
+	}
+
+	client, err := m.dial(ctx)
+	if err != nil {
+		return err
+	}
+	defer client.Close()
+
+	if m.config.Username != "" {
+		auth := smtp.PlainAuth("", m.config.Username, m.config.Password, m.config.Host)
+		if err := client.Auth(auth); err != nil {
+			return fmt.Errorf("SMTP authentication failed: %w", err)
+		}
+	}
+	if err := client.Mail(m.from.Address); err != nil {
+		return fmt.Errorf("SMTP server rejected sender: %w", err)
+	}
+	if err := client.Rcpt(to.Address); err != nil {
+		return fmt.Errorf("SMTP server rejected recipient: %w", err)
+	}
+	w, err := client.Data()
+	if err != nil {
+		return fmt.Errorf("SMTP server rejected message: %w", err)
+	}
+	if _, err := w.Write(data); err != nil {
+		return fmt.Errorf("failed to send message: %w", err)
+	}
+	if err := w.Close(); err != nil {
+		return fmt.Errorf("SMTP server rejected message: %w", err)
+	}
+	return client.Quit()
+}
+
+// dial connects to the SMTP server, switching to TLS as configured
+func (m *Mailer) dial(ctx context.Context) (*smtp.Client, error) {
+	addr := net.JoinHostPort(m.config.Host, strconv.Itoa(m.config.Port))
+	tlsConfig := &tls.Config{ServerName: m.config.Host}
+
+	dialer := &net.Dialer{Timeout: m.config.Timeout}
+	conn, err := dialer.DialContext(ctx, "tcp", addr)
+	if err != nil {
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if m.config.Timeout > 0 {
+		conn.SetDeadline(time.Now().Add(m.config.Timeout))
+	}
+	if m.config.Security == SecurityTLS {
+		conn = tls.Client(conn, tlsConfig)
+	}
+
+	client, err := smtp.NewClient(conn, m.config.Host)
+	if err != nil {
+		conn.Close()
+		return nil, fmt.Errorf("failed to connect to SMTP server: %w", err)
+	}
+	if err := client.Hello(localName(m.from.Address)); err != nil {
+		client.Close()
+		return nil, fmt.Errorf("SMTP handshake failed: %w", err)
+	}
+	if m.config.Security == SecurityStartTLS {
+		if ok, _ := client.Extension("STARTTLS"); !ok {
+			client.Close()
+			return nil, errors.New("SMTP server does not support STARTTLS")
+		}
+		if err := client.StartTLS(tlsConfig); err != nil {
+			client.Close()
+			return nil, fmt.Errorf("STARTTLS failed: %w", err)
+		}
+	}
+	return client, nil
+}
+
+// localName is the domain the client greets the server with
+func localName(from string) string {
+	if i := strings.LastIndex(from, "@"); i >= 0 && i < len(from)-1 {
+		return from[i+1:]
+	}
+	return "localhost"
+}
+
+// compose renders a message as MIME
+func (m *Mailer) compose(msg *Message, to *netmail.Address) ([]byte, error) {
+	var buf bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&buf, "%s: %s\r\n", key, value)
+	}
+
+	id := make([]byte, 16)
+	rand.Read(id)
+
+	header("From", m.from.String())
+	header("To", to.String())
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("Message-ID", fmt.Sprintf("<%s@%s>", hex.EncodeToString(id), localName(m.from.Address)))
+	header("MIME-Version", "1.0")
+
+	if msg.Attachment == nil {
+		header("Content-Type", "text/plain; charset=utf-8")
+		header("Content-Transfer-Encoding", "quoted-printable")
+		buf.WriteString("\r\n")
+		if err := writeQuotedPrintable(&buf, msg.Body); err != nil {
+			return nil, err
+		}
+		return buf.Bytes(), nil
+	}
+
+	mw := multipart.NewWriter(&buf)
+	header("Content-Type", mime.FormatMediaType("multipart/mixed", map[string]string{"boundary": mw.Boundary()}))
+	buf.WriteString("\r\n")
+
+	text, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {"text/plain; charset=utf-8"},
+		"Content-Transfer-Encoding": {"quoted-printable"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	if err := writeQuotedPrintable(text, msg.Body); err != nil {
+		return nil, err
+	}
+
+	file, err := mw.CreatePart(textproto.MIMEHeader{
+		"Content-Type":              {mime.FormatMediaType(msg.Attachment.ContentType, map[string]string{"name": msg.Attachment.Filename})},
+		"Content-Disposition":       {mime.FormatMediaType("attachment", map[string]string{"filename": msg.Attachment.Filename})},
+		"Content-Transfer-Encoding": {"base64"},
+	})
+	if err != nil {
+		return nil, err
+	}
+	encoded := base64.StdEncoding.EncodeToString(msg.Attachment.Data)
+	for len(encoded) > 76 {
+		io.WriteString(file, encoded[:76]+"\r\n")
+		encoded = encoded[76:]
+	}
+	io.WriteString(file, encoded+"\r\n")
+
+	if err := mw.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func writeQuotedPrintable(w io.Writer, text string) error {
+	qp := quotedprintable.NewWriter(w)
+	if _, err := io.WriteString(qp, text); err != nil {
+		return err
+	}
+	return qp.Close()
+}
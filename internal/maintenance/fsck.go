@@ -0,0 +1,220 @@
+package maintenance
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"gorm.io/gorm"
+)
+
+// Fsck issue types
+const (
+	IssueOrphanedFile = "orphaned_file" // File in storage that no job refers to
+	IssueMissingFile  = "missing_file"  // Job refers to a file that does not exist
+	IssueExpiredJob   = "expired_job"   // Job left in the trash past the trash period
+)
+
+// FsckIssue is a single inconsistency between the database and storage
+type FsckIssue struct {
+	Type     string `json:"type" example:"missing_file"`
+	JobID    string `json:"job_id,omitempty" example:"abc123def456"`
+	Key      string `json:"key,omitempty" example:"jobs/abc123def456.pdf"`
+	Detail   string `json:"detail" example:"PDF file is missing"`
+	Repaired bool   `json:"repaired"`
+	Error    string `json:"error,omitempty"`
+}
+
+// FsckReport lists the issues found by a consistency check
+type FsckReport struct {
+	Repair      bool        `json:"repair"`
+	GeneratedAt time.Time   `json:"generated_at"`
+	FilesListed int         `json:"files_listed"`
+	JobsChecked int         `json:"jobs_checked"`
+	Issues      []FsckIssue `json:"issues"`
+}
+
+// Unrepaired returns the number of issues that are still present
+func (r *FsckReport) Unrepaired() int {
+	n := 0
+	for _, issue := range r.Issues {
+		if !issue.Repaired {
+			n++
+		}
+	}
+	return n
+}
+
+// Log writes a summary of the report to the server log
+func (r *FsckReport) Log() {
+	if len(r.Issues) == 0 {
+		logger.Debug("Storage check: %d files, %d jobs, no issues", r.FilesListed, r.JobsChecked)
+		return
+	}
+	for _, issue := range r.Issues {
+		logger.Warn("Storage check: %s job=%s key=%s: %s (repaired: %t)", issue.Type, issue.JobID, issue.Key, issue.Detail, issue.Repaired)
+	}
+	logger.Info("Storage check: %d issues found, %d unrepaired", len(r.Issues), r.Unrepaired())
+}
+
+// Fsck checks that the database and the stored job files agree
+type Fsck struct {
+	config config.FsckConfig
+	db     *gorm.DB
+	store  *storage.JobStore
+	trash  *Trash
+}
+
+// NewFsck creates a consistency checker
+func NewFsck(cfg config.FsckConfig, trash *Trash, db *gorm.DB, store *storage.JobStore) *Fsck {
+	return &Fsck{config: cfg, db: db, store: store, trash: trash}
+}
+
+// jobFile is a file reference held by a job
+type jobFile struct {
+	label     string
+	key       string
+	fileField string
+	hashField string
+	sizeField string // Empty when the size describes the job rather than the stored file
+}
+
+func jobFiles(job *models.PrintJob) []jobFile {
+	return []jobFile{
+		{"original", job.OriginalFile, "OriginalFile", "OriginalSHA256", ""},
+		{"PDF", job.PDFFile, "PDFFile", "PDFSHA256", "PDFSize"},
+		{"thumbnail", job.ThumbnailFile, "ThumbnailFile", "ThumbnailSHA256", "ThumbnailSize"},
+	}
+}
+
+// Run checks storage and, if repair is set, fixes what it finds
+func (f *Fsck) Run(ctx context.Context, repair bool) (*FsckReport, error) {
+	backend := f.store.Backend()
+	now := time.Now()
+	report := &FsckReport{Repair: repair, GeneratedAt: now, Issues: []FsckIssue{}}
+
+	// List storage before loading jobs, so files written in between are always referenced
+	objects, err := backend.List(ctx, storage.JobKey("")+"/")
+	if err != nil {
+		return nil, fmt.Errorf("failed to list storage: %w", err)
+	}
+	report.FilesListed = len(objects)
+
+	stored := make(map[string]bool, len(objects))
+	for _, obj := range objects {
+		stored[obj.Key] = true
+	}
+
+	var jobs []models.PrintJob
+	if err := f.db.WithContext(ctx).Unscoped().Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+	report.JobsChecked = len(jobs)
+
+	referenced := make(map[string]bool)
+	for i := range jobs {
+		job := &jobs[i]
+		for _, file := range jobFiles(job) {
+			if file.key != "" {
+				referenced[storage.CanonicalKey(backend, file.key)] = true
+			}
+		}
+
+		if job.DeletedAt.Valid {
+			if f.trash.ExpiresAt(job.DeletedAt.Time).Before(now) {
+				issue := FsckIssue{
+					Type:   IssueExpiredJob,
+					JobID:  job.ID,
+					Detail: fmt.Sprintf("deleted on %s", job.DeletedAt.Time.Format(time.RFC3339)),
+				}
+				if repair {
+					if err := f.trash.Purge(ctx, job); err != nil {
+						issue.Error = err.Error()
+					} else {
+						issue.Repaired = true
+					}
+				}
+				report.Issues = append(report.Issues, issue)
+			}
+			continue
+		}
+
+		for _, file := range jobFiles(job) {
+			if file.key == "" || stored[storage.CanonicalKey(backend, file.key)] {
+				continue
+			}
+			// Not listed; confirm, since the file may live outside the listed prefix or be brand new
+			if _, err := backend.Stat(ctx, file.key); !errors.Is(err, storage.ErrNotFound) {
+				continue
+			}
+
+			issue := FsckIssue{
+				Type:   IssueMissingFile,
+				JobID:  job.ID,
+				Key:    file.key,
+				Detail: file.label + " file is missing",
+			}
+			if repair {
+				f.dropReference(ctx, job, file, &issue)
+			}
+			report.Issues = append(report.Issues, issue)
+		}
+	}
+
+	for _, obj := range objects {
+		if referenced[obj.Key] || obj.ModTime.After(now.Add(-f.config.OrphanGrace)) {
+			continue
+		}
+
+		issue := FsckIssue{
+			Type:   IssueOrphanedFile,
+			Key:    obj.Key,
+			Detail: fmt.Sprintf("%d bytes, modified %s", obj.Size, obj.ModTime.Format(time.RFC3339)),
+		}
+		if repair {
+			if err := backend.Delete(ctx, obj.Key); err != nil {
+				issue.Error = err.Error()
+			} else {
+				issue.Repaired = true
+			}
+		}
+		report.Issues = append(report.Issues, issue)
+	}
+
+	return report, nil
+}
+
+// dropReference clears a job's reference to a missing file.
+// Jobs left without any file are marked as failed.
+func (f *Fsck) dropReference(ctx context.Context, job *models.PrintJob, file jobFile, issue *FsckIssue) {
+	updates := map[string]interface{}{
+		file.fileField: "",
+		file.hashField: "",
+	}
+	if file.sizeField != "" {
+		updates[file.sizeField] = 0
+	}
+	switch file.fileField {
+	case "OriginalFile":
+		job.OriginalFile = ""
+	case "PDFFile":
+		job.PDFFile = ""
+	case "ThumbnailFile":
+		job.ThumbnailFile = ""
+	}
+	if job.OriginalFile == "" && job.PDFFile == "" {
+		updates["Status"] = models.JobStatusFailed
+		updates["Error"] = "stored files are missing"
+	}
+
+	if err := f.db.WithContext(ctx).Model(job).Updates(updates).Error; err != nil {
+		issue.Error = err.Error()
+		return
+	}
+	issue.Repaired = true
+}
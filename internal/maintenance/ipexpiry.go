@@ -0,0 +1,126 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/mail"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/webhook"
+	"gorm.io/gorm"
+)
+
+// IPExpiry deactivates expired IP registrations, warns their owners before
+// they expire and removes old claim codes
+type IPExpiry struct {
+	config   config.IPRegistrationsConfig
+	db       *gorm.DB
+	mailer   *mail.Mailer        // nil when email delivery is disabled
+	webhooks *webhook.Dispatcher // nil when webhooks are disabled
+}
+
+// NewIPExpiry creates the expiry check. mailer and webhooks may be nil.
+func NewIPExpiry(cfg config.IPRegistrationsConfig, db *gorm.DB, mailer *mail.Mailer, webhooks *webhook.Dispatcher) *IPExpiry {
+	return &IPExpiry{config: cfg, db: db, mailer: mailer, webhooks: webhooks}
+}
+
+// Run warns about registrations expiring soon, deactivates expired ones and
+// removes claim codes that expired more than a day ago
+func (e *IPExpiry) Run(ctx context.Context) error {
+	now := time.Now()
+	if err := e.warn(ctx, now); err != nil {
+		return err
+	}
+	if err := e.deactivate(ctx, now); err != nil {
+		return err
+	}
+	return e.db.WithContext(ctx).Where("expires_at < ?", now.Add(-24*time.Hour)).Delete(&models.IPClaimCode{}).Error
+}
+
+// warn notifies owners of registrations expiring within the warning period, once per expiry
+func (e *IPExpiry) warn(ctx context.Context, now time.Time) error {
+	if e.config.WarnBefore <= 0 {
+		return nil
+	}
+
+	var registrations []models.IPRegistration
+	if err := e.db.WithContext(ctx).Preload("User").
+		Where("is_active = ? AND expiry_warned_at IS NULL", true).
+		Where("expires_at > ? AND expires_at <= ?", now, now.Add(e.config.WarnBefore)).
+		Find(&registrations).Error; err != nil {
+		return err
+	}
+
+	for i := range registrations {
+		registration := &registrations[i]
+		if e.mailer != nil && registration.User != nil && registration.User.Email != "" {
+			if err := e.mailer.Send(ctx, expiryWarning(registration)); err != nil {
+				logger.Warn("IP expiry: failed to email %s about %s: %v", registration.User.Email, registration.IPAddress, err)
+			}
+		}
+		if e.webhooks != nil {
+			e.webhooks.NotifyIPRegistration(ctx, webhook.EventIPExpiring, registration)
+		}
+
+		if err := e.db.WithContext(ctx).Model(registration).Update("expiry_warned_at", now).Error; err != nil {
+			logger.Error("IP expiry: failed to record warning for %s: %v", registration.IPAddress, err)
+		}
+	}
+
+	if len(registrations) > 0 {
+		logger.Info("IP expiry: warned about %d registrations expiring soon", len(registrations))
+	}
+	return nil
+}
+
+// deactivate marks active registrations past their expiry as expired
+func (e *IPExpiry) deactivate(ctx context.Context, now time.Time) error {
+	var registrations []models.IPRegistration
+	if err := e.db.WithContext(ctx).
+		Where("is_active = ? AND expires_at IS NOT NULL AND expires_at <= ?", true, now).
+		Find(&registrations).Error; err != nil {
+		return err
+	}
+
+	deactivated := 0
+	for i := range registrations {
+		registration := &registrations[i]
+		registration.IsActive = false
+		registration.Expired = true
+		if err := e.db.WithContext(ctx).Model(registration).
+			Select("is_active", "expired").Updates(registration).Error; err != nil {
+			logger.Error("IP expiry: failed to deactivate %s: %v", registration.IPAddress, err)
+			continue
+		}
+		deactivated++
+
+		if e.webhooks != nil {
+			e.webhooks.NotifyIPRegistration(ctx, webhook.EventIPExpired, registration)
+		}
+	}
+
+	if deactivated > 0 {
+		logger.Info("IP expiry: deactivated %d expired registrations", deactivated)
+	}
+	return nil
+}
+
+// expiryWarning is the email sent before a registration expires
+func expiryWarning(registration *models.IPRegistration) *mail.Message {
+	body := fmt.Sprintf("Your IP registration %s", registration.IPAddress)
+	if registration.Description != "" {
+		body += fmt.Sprintf(" (%s)", registration.Description)
+	}
+	body += fmt.Sprintf(" expires on %s.\n\n", registration.ExpiresAt.Format("2006-01-02 15:04"))
+	body += "Print jobs from it will no longer be attributed to your account after that. " +
+		"Renew the registration in Zikzi to keep using it.\n"
+
+	return &mail.Message{
+		To:      registration.User.Email,
+		Subject: fmt.Sprintf("Zikzi: IP registration %s expires soon", registration.IPAddress),
+		Body:    body,
+	}
+}
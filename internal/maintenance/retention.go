@@ -0,0 +1,195 @@
+package maintenance
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"gorm.io/gorm"
+)
+
+// Retention actions
+const (
+	ActionDeleteOriginal = "delete_original"
+	ActionDeleteJob      = "delete_job"
+)
+
+// RetentionAction describes what retention does (or would do) to a job
+type RetentionAction struct {
+	JobID     string    `json:"job_id" example:"abc123def456"`
+	UserID    string    `json:"user_id" example:"abc123def456"`
+	Queue     string    `json:"queue" example:"raw"`
+	CreatedAt time.Time `json:"created_at"`
+	Action    string    `json:"action" example:"delete_original"` // delete_original, delete_job
+	Reason    string    `json:"reason" example:"original older than 7 days"`
+}
+
+// RetentionReport lists the actions of a retention run
+type RetentionReport struct {
+	DryRun      bool              `json:"dry_run" example:"true"`
+	GeneratedAt time.Time         `json:"generated_at"`
+	Actions     []RetentionAction `json:"actions"`
+}
+
+// Retention applies the configured retention policies to jobs and their files
+type Retention struct {
+	config config.RetentionConfig
+	db     *gorm.DB
+	store  *storage.JobStore
+}
+
+// NewRetention creates a retention runner
+func NewRetention(cfg config.RetentionConfig, db *gorm.DB, store *storage.JobStore) *Retention {
+	return &Retention{config: cfg, db: db, store: store}
+}
+
+// policyFor returns the effective policy of a job: user overrides queue overrides default.
+// Unset (zero) values inherit, negative values disable a rule.
+func (r *Retention) policyFor(queue, username string) config.RetentionPolicy {
+	policy := r.config.Default
+
+	overlay := func(o config.RetentionPolicy) {
+		if o.OriginalDays != 0 {
+			policy.OriginalDays = o.OriginalDays
+		}
+		if o.JobDays != 0 {
+			policy.JobDays = o.JobDays
+		}
+		if o.KeepLast != 0 {
+			policy.KeepLast = o.KeepLast
+		}
+		if o.FailedHours != 0 {
+			policy.FailedHours = o.FailedHours
+		}
+	}
+
+	// Viper lowercases map keys
+	if o, ok := r.config.Queues[strings.ToLower(queue)]; ok {
+		overlay(o)
+	}
+	if o, ok := r.config.Users[strings.ToLower(username)]; ok && username != "" {
+		overlay(o)
+	}
+	return policy
+}
+
+// Plan computes the retention actions without applying them
+func (r *Retention) Plan(ctx context.Context) (*RetentionReport, error) {
+	var jobs []models.PrintJob
+	if err := r.db.WithContext(ctx).Order("created_at DESC").Find(&jobs).Error; err != nil {
+		return nil, err
+	}
+
+	usernames := make(map[string]string)
+	if len(r.config.Users) > 0 {
+		var users []models.User
+		if err := r.db.WithContext(ctx).Select("id", "username").Find(&users).Error; err != nil {
+			return nil, err
+		}
+		for _, u := range users {
+			usernames[u.ID] = u.Username
+		}
+	}
+
+	now := time.Now()
+	report := &RetentionReport{DryRun: true, GeneratedAt: now, Actions: []RetentionAction{}}
+	perUser := make(map[string]int)
+
+	for _, job := range jobs {
+		policy := r.policyFor(job.Queue, usernames[job.UserID])
+
+		// Jobs are ordered newest first, so this is the job's rank for its user
+		rank := perUser[job.UserID]
+		perUser[job.UserID]++
+
+		// Never touch jobs that are still being received or converted
+		if job.Status == models.JobStatusReceived || job.Status == models.JobStatusProcessing {
+			continue
+		}
+
+		reason := ""
+		switch {
+		case policy.FailedHours > 0 && job.Status == models.JobStatusFailed &&
+			job.CreatedAt.Before(now.Add(-time.Duration(policy.FailedHours)*time.Hour)):
+			reason = fmt.Sprintf("failed job older than %d hours", policy.FailedHours)
+		case policy.JobDays > 0 && job.CreatedAt.Before(now.AddDate(0, 0, -policy.JobDays)):
+			reason = fmt.Sprintf("job older than %d days", policy.JobDays)
+		case policy.KeepLast > 0 && rank >= policy.KeepLast:
+			reason = fmt.Sprintf("user has more than %d newer jobs", policy.KeepLast)
+		}
+
+		action := RetentionAction{
+			JobID:     job.ID,
+			UserID:    job.UserID,
+			Queue:     job.Queue,
+			CreatedAt: job.CreatedAt,
+		}
+
+		if reason != "" {
+			action.Action = ActionDeleteJob
+			action.Reason = reason
+			report.Actions = append(report.Actions, action)
+			continue
+		}
+
+		// Originals are only dropped once a PDF exists, otherwise nothing would be left
+		if policy.OriginalDays > 0 && job.OriginalFile != "" && job.PDFFile != "" &&
+			job.CreatedAt.Before(now.AddDate(0, 0, -policy.OriginalDays)) {
+			action.Action = ActionDeleteOriginal
+			action.Reason = fmt.Sprintf("original older than %d days", policy.OriginalDays)
+			report.Actions = append(report.Actions, action)
+		}
+	}
+
+	return report, nil
+}
+
+// Apply computes and executes the retention actions
+func (r *Retention) Apply(ctx context.Context) (*RetentionReport, error) {
+	report, err := r.Plan(ctx)
+	if err != nil {
+		return nil, err
+	}
+	report.DryRun = false
+
+	var deletedJobs, deletedOriginals int
+	for _, action := range report.Actions {
+		var job models.PrintJob
+		if err := r.db.WithContext(ctx).First(&job, "id = ?", action.JobID).Error; err != nil {
+			continue
+		}
+
+		switch action.Action {
+		case ActionDeleteJob:
+			// Expired jobs skip the trash
+			if err := purgeJob(ctx, r.db, r.store, &job); err != nil {
+				logger.Warn("Retention: failed to delete job %s: %v", job.ID, err)
+				continue
+			}
+			deletedJobs++
+		case ActionDeleteOriginal:
+			if err := r.store.Backend().Delete(ctx, job.OriginalFile); err != nil {
+				logger.Warn("Retention: failed to delete original of job %s: %v", job.ID, err)
+				continue
+			}
+			if err := r.db.WithContext(ctx).Model(&job).Updates(map[string]interface{}{
+				"original_file":   "",
+				"original_sha256": "",
+			}).Error; err != nil {
+				return report, err
+			}
+			deletedOriginals++
+		}
+		logger.Debug("Retention: %s on job %s (%s)", action.Action, action.JobID, action.Reason)
+	}
+
+	if deletedJobs > 0 || deletedOriginals > 0 {
+		logger.Info("Retention: deleted %d jobs and %d original files", deletedJobs, deletedOriginals)
+	}
+	return report, nil
+}
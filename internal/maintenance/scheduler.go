@@ -0,0 +1,59 @@
+package maintenance
+
+import (
+	"context"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/logger"
+)
+
+// task is a function run periodically by the scheduler
+type task struct {
+	name     string
+	interval time.Duration
+	run      func(ctx context.Context) error
+}
+
+// Scheduler runs background maintenance tasks on fixed intervals
+type Scheduler struct {
+	tasks []task
+}
+
+// NewScheduler creates an empty scheduler
+func NewScheduler() *Scheduler {
+	return &Scheduler{}
+}
+
+// Add registers a task. It first runs one interval after Start.
+func (s *Scheduler) Add(name string, interval time.Duration, run func(ctx context.Context) error) {
+	if interval <= 0 {
+		interval = time.Hour
+	}
+	s.tasks = append(s.tasks, task{name: name, interval: interval, run: run})
+}
+
+// Start runs all registered tasks until ctx is cancelled
+func (s *Scheduler) Start(ctx context.Context) {
+	for _, t := range s.tasks {
+		go s.loop(ctx, t)
+	}
+}
+
+func (s *Scheduler) loop(ctx context.Context, t task) {
+	logger.Info("Maintenance task %s scheduled every %s", t.name, t.interval)
+
+	ticker := time.NewTicker(t.interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			logger.Debug("Running maintenance task %s", t.name)
+			if err := t.run(ctx); err != nil {
+				logger.Error("Maintenance task %s failed: %v", t.name, err)
+			}
+		}
+	}
+}
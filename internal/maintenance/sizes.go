@@ -0,0 +1,48 @@
+package maintenance
+
+import (
+	"context"
+
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"gorm.io/gorm"
+)
+
+// BackfillFileSizes records the PDF and thumbnail sizes of jobs processed
+// before they were tracked, so they count towards storage quotas. Files that
+// can't be read are left for the storage check to report.
+func BackfillFileSizes(ctx context.Context, db *gorm.DB, store *storage.JobStore) (int, error) {
+	var jobs []models.PrintJob
+	if err := db.WithContext(ctx).Unscoped().
+		Where("(pdf_file <> '' AND pdf_size = 0) OR (thumbnail_file <> '' AND thumbnail_size = 0)").
+		Find(&jobs).Error; err != nil {
+		return 0, err
+	}
+
+	updated := 0
+	for i := range jobs {
+		job := &jobs[i]
+		updates := map[string]interface{}{}
+		if job.PDFFile != "" && job.PDFSize == 0 {
+			if size, err := store.Size(ctx, job, job.PDFFile); err == nil {
+				updates["pdf_size"] = size
+			}
+		}
+		if job.ThumbnailFile != "" && job.ThumbnailSize == 0 {
+			if size, err := store.Size(ctx, job, job.ThumbnailFile); err == nil {
+				updates["thumbnail_size"] = size
+			}
+		}
+		if len(updates) == 0 {
+			continue
+		}
+
+		if err := db.WithContext(ctx).Unscoped().Model(job).UpdateColumns(updates).Error; err != nil {
+			logger.Warn("Failed to record file sizes of job %s: %v", job.ID, err)
+			continue
+		}
+		updated++
+	}
+	return updated, nil
+}
@@ -0,0 +1,95 @@
+package maintenance
+
+import (
+	"context"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"gorm.io/gorm"
+)
+
+// Trash keeps soft-deleted jobs and their files until the trash period expires
+type Trash struct {
+	config config.TrashConfig
+	db     *gorm.DB
+	store  *storage.JobStore
+}
+
+// NewTrash creates a trash manager
+func NewTrash(cfg config.TrashConfig, db *gorm.DB, store *storage.JobStore) *Trash {
+	return &Trash{config: cfg, db: db, store: store}
+}
+
+// Enabled reports whether deleted jobs are kept in the trash at all
+func (t *Trash) Enabled() bool {
+	return t.config.Period > 0
+}
+
+// ExpiresAt returns when a job deleted at deletedAt will be purged
+func (t *Trash) ExpiresAt(deletedAt time.Time) time.Time {
+	return deletedAt.Add(t.config.Period)
+}
+
+// Delete moves a job to the trash, or purges it right away if the trash is disabled
+func (t *Trash) Delete(ctx context.Context, job *models.PrintJob) error {
+	if !t.Enabled() {
+		return t.Purge(ctx, job)
+	}
+	return t.db.WithContext(ctx).Delete(job).Error
+}
+
+// Purge permanently deletes a job and its files
+func (t *Trash) Purge(ctx context.Context, job *models.PrintJob) error {
+	return purgeJob(ctx, t.db, t.store, job)
+}
+
+// PurgeExpired permanently deletes all jobs that have been in the trash longer than the trash period
+func (t *Trash) PurgeExpired(ctx context.Context) (int, error) {
+	var jobs []models.PrintJob
+	if err := t.db.WithContext(ctx).Unscoped().
+		Where("deleted_at IS NOT NULL AND deleted_at < ?", time.Now().Add(-t.config.Period)).
+		Find(&jobs).Error; err != nil {
+		return 0, err
+	}
+
+	purged := 0
+	for i := range jobs {
+		if err := t.Purge(ctx, &jobs[i]); err != nil {
+			logger.Warn("Trash: failed to purge job %s: %v", jobs[i].ID, err)
+			continue
+		}
+		purged++
+	}
+
+	if purged > 0 {
+		logger.Info("Trash: purged %d expired jobs", purged)
+	}
+	return purged, nil
+}
+
+// purgeJob deletes a job's files and then the job itself, bypassing the trash.
+// The record is kept if a file cannot be deleted, so the purge can be retried.
+func purgeJob(ctx context.Context, db *gorm.DB, store *storage.JobStore, job *models.PrintJob) error {
+	if errs := store.Delete(ctx, job); len(errs) > 0 {
+		return errs[0]
+	}
+	return db.WithContext(ctx).Transaction(func(tx *gorm.DB) error {
+		shares := tx.Model(&models.JobShare{}).Select("id").Where("job_id = ?", job.ID)
+		if err := tx.Where("share_id IN (?)", shares).Delete(&models.ShareAccess{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("job_id = ?", job.ID).Delete(&models.JobShare{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("job_id = ?", job.ID).Delete(&models.JobForward{}).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("job_id = ?", job.ID).Delete(&models.HotFolderExport{}).Error; err != nil {
+			return err
+		}
+		return tx.Unscoped().Delete(job).Error
+	})
+}
@@ -0,0 +1,42 @@
+package models
+
+import "github.com/alex4386/zikzi/internal/utils"
+
+// DigestHashColumns are the columns of DigestHashes, for selective updates
+var DigestHashColumns = []string{"digest_ha1", "digest_ha1_sha256", "digest_ha1_sha512_256"}
+
+// DigestHashes are the pre-computed H(username:realm:secret) of a password or
+// token for each Digest algorithm, so the secret itself needn't be kept
+type DigestHashes struct {
+	DigestHA1          string `json:"-"` // MD5
+	DigestHA1SHA256    string `gorm:"column:digest_ha1_sha256" json:"-"`
+	DigestHA1SHA512256 string `gorm:"column:digest_ha1_sha512_256" json:"-"`
+}
+
+// HA1 returns the hash for a Digest algorithm, or an empty string if there is none
+func (d *DigestHashes) HA1(algorithm string) string {
+	switch algorithm {
+	case utils.DigestMD5:
+		return d.DigestHA1
+	case utils.DigestSHA256:
+		return d.DigestHA1SHA256
+	case utils.DigestSHA512256:
+		return d.DigestHA1SHA512256
+	}
+	return ""
+}
+
+// SetDigest computes the hashes of secret for every algorithm, reporting
+// whether they changed
+func (d *DigestHashes) SetDigest(username, realm, secret string) bool {
+	hashes := DigestHashes{
+		DigestHA1:          utils.ComputeDigestHA1With(utils.DigestMD5, username, realm, secret),
+		DigestHA1SHA256:    utils.ComputeDigestHA1With(utils.DigestSHA256, username, realm, secret),
+		DigestHA1SHA512256: utils.ComputeDigestHA1With(utils.DigestSHA512256, username, realm, secret),
+	}
+	if *d == hashes {
+		return false
+	}
+	*d = hashes
+	return true
+}
@@ -0,0 +1,25 @@
+package models
+
+import (
+	"time"
+
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Folder groups a user's print jobs
+type Folder struct {
+	ID        string    `gorm:"type:varchar(12);primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID string `gorm:"type:varchar(12);uniqueIndex:idx_folders_user_name;not null" json:"user_id"`
+	Name   string `gorm:"uniqueIndex:idx_folders_user_name;not null" json:"name"`
+}
+
+func (f *Folder) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = utils.GenerateShortID()
+	}
+	return nil
+}
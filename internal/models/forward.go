@@ -0,0 +1,42 @@
+package models
+
+import (
+	"time"
+
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Forward states
+const (
+	ForwardPending = "pending"
+	ForwardSent    = "sent"
+	ForwardFailed  = "failed"
+)
+
+// Documents that can be forwarded
+const (
+	ForwardDocumentPDF      = "pdf"
+	ForwardDocumentOriginal = "original"
+)
+
+// JobForward records sending a job to a downstream printer
+type JobForward struct {
+	ID        string    `gorm:"type:varchar(12);primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	JobID    string     `gorm:"type:varchar(12);index;not null" json:"job_id"`
+	Printer  string     `gorm:"not null" json:"printer"`  // Name of the downstream printer
+	Document string     `gorm:"not null" json:"document"` // pdf or original
+	Status   string     `gorm:"index;not null" json:"status"`
+	Error    string     `json:"error,omitempty"`
+	SentAt   *time.Time `json:"sent_at,omitempty"`
+}
+
+func (f *JobForward) BeforeCreate(tx *gorm.DB) error {
+	if f.ID == "" {
+		f.ID = utils.GenerateShortID()
+	}
+	return nil
+}
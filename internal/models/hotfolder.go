@@ -0,0 +1,37 @@
+package models
+
+import (
+	"time"
+
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Hot folder export states
+const (
+	ExportPending  = "pending"
+	ExportExported = "exported"
+	ExportFailed   = "failed"
+)
+
+// HotFolderExport tracks copying a job's PDF into a hot folder
+type HotFolderExport struct {
+	ID        string    `gorm:"type:varchar(12);primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	JobID      string     `gorm:"type:varchar(12);uniqueIndex:idx_hotfolder_job_rule;not null" json:"job_id"`
+	Rule       string     `gorm:"uniqueIndex:idx_hotfolder_job_rule;not null" json:"rule"` // Name of the export rule
+	Path       string     `json:"path"`                                                    // File written for the last attempt
+	Status     string     `gorm:"index;not null" json:"status"`                            // pending, exported, failed
+	Attempts   int        `gorm:"default:0;not null" json:"attempts"`
+	Error      string     `json:"error,omitempty"`
+	ExportedAt *time.Time `json:"exported_at,omitempty"`
+}
+
+func (e *HotFolderExport) BeforeCreate(tx *gorm.DB) error {
+	if e.ID == "" {
+		e.ID = utils.GenerateShortID()
+	}
+	return nil
+}
@@ -0,0 +1,151 @@
+package models
+
+import (
+	"crypto/rand"
+	"errors"
+	"fmt"
+	"regexp"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// ClaimCodePrefix starts every claim code so it can be found in a document stream
+const ClaimCodePrefix = "ZIKZI-"
+
+// claimCodeAlphabet leaves out characters that are easily confused when read off a page
+const claimCodeAlphabet = "23456789ABCDEFGHJKLMNPQRSTUVWXYZ"
+
+// ClaimCodePattern matches a claim code
+var ClaimCodePattern = regexp.MustCompile(ClaimCodePrefix + "[2-9A-HJ-NP-Z]{8}")
+
+var (
+	ErrClaimCodeInvalid  = errors.New("claim code is unknown, used or expired")
+	ErrClaimCodeConflict = errors.New("IP address is registered by another user")
+)
+
+// IPClaimCode is a short-lived code that registers the IP address of the
+// machine that prints it to the code's owner
+type IPClaimCode struct {
+	ID        string    `gorm:"type:varchar(12);primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	UserID      string    `gorm:"type:varchar(12);index;not null" json:"user_id"`
+	Code        string    `gorm:"uniqueIndex;not null" json:"code"`
+	Description string    `json:"description"` // Description of the registration created by the code
+	ExpiresAt   time.Time `gorm:"index;not null" json:"expires_at"`
+
+	ClaimedAt      *time.Time `json:"claimed_at,omitempty"`
+	ClaimedIP      string     `json:"claimed_ip,omitempty"`
+	RegistrationID string     `gorm:"type:varchar(12)" json:"registration_id,omitempty"`
+}
+
+func (c *IPClaimCode) BeforeCreate(tx *gorm.DB) error {
+	if c.ID == "" {
+		c.ID = utils.GenerateShortID()
+	}
+	return nil
+}
+
+// GenerateClaimCode generates a new claim code such as ZIKZI-7KQ2M9XD
+func GenerateClaimCode() (string, error) {
+	buf := make([]byte, 8)
+	if _, err := rand.Read(buf); err != nil {
+		return "", err
+	}
+	for i, b := range buf {
+		buf[i] = claimCodeAlphabet[int(b)%len(claimCodeAlphabet)]
+	}
+	return ClaimCodePrefix + string(buf), nil
+}
+
+// CreateClaimCode creates a claim code for a user valid for ttl, replacing
+// the user's earlier unused codes
+func CreateClaimCode(db *gorm.DB, userID, description string, ttl time.Duration) (*IPClaimCode, error) {
+	code, err := GenerateClaimCode()
+	if err != nil {
+		return nil, err
+	}
+	claim := IPClaimCode{
+		UserID:      userID,
+		Code:        code,
+		Description: description,
+		ExpiresAt:   time.Now().Add(ttl),
+	}
+	err = db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("user_id = ? AND claimed_at IS NULL", userID).Delete(&IPClaimCode{}).Error; err != nil {
+			return err
+		}
+		return tx.Create(&claim).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &claim, nil
+}
+
+// RedeemClaimCode registers ip to the owner of an unused, unexpired claim code
+// and marks the code as used. Users other than admins can't claim addresses
+// overlapping someone else's registration, not even inside another user's
+// range, since the most specific registration would take over their jobs.
+func RedeemClaimCode(db *gorm.DB, code, ip string) (*IPRegistration, error) {
+	var registration IPRegistration
+	err := db.Transaction(func(tx *gorm.DB) error {
+		var claim IPClaimCode
+		if err := tx.Where("code = ? AND claimed_at IS NULL AND expires_at > ?", code, time.Now()).
+			First(&claim).Error; err != nil {
+			return ErrClaimCodeInvalid
+		}
+		var owner User
+		if err := tx.First(&owner, "id = ?", claim.UserID).Error; err != nil {
+			return ErrClaimCodeInvalid
+		}
+
+		registration = IPRegistration{UserID: owner.ID, Description: claim.Description, IsActive: true}
+		if err := registration.SetAddress(ip); err != nil {
+			return err
+		}
+
+		overlapping, err := registration.Overlapping(tx)
+		if err != nil {
+			return err
+		}
+		for _, other := range overlapping {
+			if other.UserID != owner.ID && (!owner.IsAdmin || other.IPAddress == registration.IPAddress) {
+				return fmt.Errorf("%w: %s", ErrClaimCodeConflict, other.IPAddress)
+			}
+		}
+
+		// Reuse an earlier registration of the same address
+		var existing IPRegistration
+		if err := tx.Unscoped().Where("ip_address = ?", registration.IPAddress).First(&existing).Error; err == nil {
+			existing.DeletedAt = gorm.DeletedAt{}
+			existing.UserID = owner.ID
+			existing.IsActive = true
+			existing.Expired = false
+			existing.ExpiresAt = nil
+			existing.ExpiryWarnedAt = nil
+			if claim.Description != "" {
+				existing.Description = claim.Description
+			}
+			if err := tx.Unscoped().Save(&existing).Error; err != nil {
+				return err
+			}
+			registration = existing
+		} else if err := tx.Create(&registration).Error; err != nil {
+			return err
+		}
+
+		now := time.Now()
+		return tx.Model(&claim).Updates(map[string]interface{}{
+			"claimed_at":      now,
+			"claimed_ip":      registration.IPAddress,
+			"registration_id": registration.ID,
+		}).Error
+	})
+	if err != nil {
+		return nil, err
+	}
+	return &registration, nil
+}
@@ -1,12 +1,22 @@
 package models
 
 import (
+	"encoding/hex"
+	"fmt"
+	"net/netip"
+	"strings"
 	"time"
 
 	"github.com/alex4386/zikzi/internal/utils"
 	"gorm.io/gorm"
 )
 
+// Smallest prefixes non-admin users may register
+const (
+	MinUserPrefixIPv4 = 24
+	MinUserPrefixIPv6 = 64
+)
+
 type IPRegistration struct {
 	ID        string         `gorm:"type:varchar(12);primarykey" json:"id"`
 	CreatedAt time.Time      `json:"created_at"`
@@ -16,10 +26,18 @@ type IPRegistration struct {
 	UserID string `gorm:"type:varchar(12);index" json:"user_id"`
 	User   *User  `gorm:"foreignKey:UserID" json:"user,omitempty"`
 
-	IPAddress   string     `gorm:"uniqueIndex;not null" json:"ip_address"`
+	IPAddress   string     `gorm:"uniqueIndex;not null" json:"ip_address"` // Canonical IP address or CIDR range
 	Description string     `json:"description"`
 	ExpiresAt   *time.Time `json:"expires_at,omitempty"`
 	IsActive    bool       `gorm:"default:true" json:"is_active"`
+
+	Expired        bool       `gorm:"default:false;not null" json:"expired"` // Deactivated because it expired, renewing reactivates it
+	ExpiryWarnedAt *time.Time `json:"expiry_warned_at,omitempty"`            // When the owner was warned about the upcoming expiry
+
+	// Covered range as 16-byte addresses (IPv4 mapped into IPv6) in hex, for range lookups
+	NetworkStart string `gorm:"type:varchar(32);index" json:"-"`
+	NetworkEnd   string `gorm:"type:varchar(32)" json:"-"`
+	PrefixLen    int    `json:"-"` // Prefix length in the IPv6 address space, larger is more specific
 }
 
 func (r *IPRegistration) BeforeCreate(tx *gorm.DB) error {
@@ -28,3 +46,116 @@ func (r *IPRegistration) BeforeCreate(tx *gorm.DB) error {
 	}
 	return nil
 }
+
+// IsExpired reports whether the registration has expired at the given time
+func (r *IPRegistration) IsExpired(now time.Time) bool {
+	return r.ExpiresAt != nil && !r.ExpiresAt.After(now)
+}
+
+// Renew moves the expiry to until and reactivates the registration if it was
+// deactivated because it expired
+func (r *IPRegistration) Renew(until time.Time) {
+	r.ExpiresAt = &until
+	r.ExpiryWarnedAt = nil
+	if r.Expired {
+		r.Expired = false
+		r.IsActive = true
+	}
+}
+
+// ParseIPPrefix parses an IP address or CIDR range into its canonical prefix:
+// host bits are cleared, IPv4-mapped IPv6 addresses become IPv4 and zones are dropped
+func ParseIPPrefix(s string) (netip.Prefix, error) {
+	s = strings.TrimSpace(s)
+	if !strings.Contains(s, "/") {
+		addr, err := netip.ParseAddr(s)
+		if err != nil {
+			return netip.Prefix{}, fmt.Errorf("invalid IP address %q", s)
+		}
+		addr = addr.WithZone("").Unmap()
+		return netip.PrefixFrom(addr, addr.BitLen()), nil
+	}
+
+	prefix, err := netip.ParsePrefix(s)
+	if err != nil {
+		return netip.Prefix{}, fmt.Errorf("invalid CIDR range %q", s)
+	}
+	addr, bits := prefix.Addr(), prefix.Bits()
+	if addr.Is4In6() {
+		if bits < 96 {
+			return netip.Prefix{}, fmt.Errorf("invalid CIDR range %q", s)
+		}
+		addr, bits = addr.Unmap(), bits-96
+	}
+	return netip.PrefixFrom(addr, bits).Masked(), nil
+}
+
+// SetAddress stores an IP address or CIDR range in canonical form
+func (r *IPRegistration) SetAddress(s string) error {
+	prefix, err := ParseIPPrefix(s)
+	if err != nil {
+		return err
+	}
+
+	if prefix.IsSingleIP() {
+		r.IPAddress = prefix.Addr().String()
+	} else {
+		r.IPAddress = prefix.String()
+	}
+
+	start := prefix.Addr().As16()
+	end := start
+	bits := prefix.Bits()
+	if prefix.Addr().Is4() {
+		bits += 96
+	}
+	for i := bits; i < 128; i++ {
+		end[i/8] |= 1 << (7 - i%8)
+	}
+	r.NetworkStart = hex.EncodeToString(start[:])
+	r.NetworkEnd = hex.EncodeToString(end[:])
+	r.PrefixLen = bits
+	return nil
+}
+
+// IsRange reports whether the registration covers more than one address
+func (r *IPRegistration) IsRange() bool {
+	return r.PrefixLen < 128
+}
+
+// TooBroadForUsers reports whether the range is larger than non-admin users may register
+func (r *IPRegistration) TooBroadForUsers() bool {
+	if strings.Contains(r.IPAddress, ":") {
+		return r.PrefixLen < MinUserPrefixIPv6
+	}
+	return r.PrefixLen < 96+MinUserPrefixIPv4
+}
+
+// Overlapping returns the other registrations sharing addresses with this one
+func (r *IPRegistration) Overlapping(db *gorm.DB) ([]IPRegistration, error) {
+	var overlapping []IPRegistration
+	err := db.Preload("User").
+		Where("network_start <= ? AND network_end >= ?", r.NetworkEnd, r.NetworkStart).
+		Where("id <> ?", r.ID).
+		Order("prefix_len DESC").
+		Find(&overlapping).Error
+	return overlapping, err
+}
+
+// FindIPRegistration returns the active, unexpired registration covering an
+// IP address, preferring the most specific range
+func FindIPRegistration(db *gorm.DB, ip string) (*IPRegistration, error) {
+	var key IPRegistration
+	if err := key.SetAddress(ip); err != nil {
+		return nil, err
+	}
+
+	var registration IPRegistration
+	if err := db.Where("network_start <= ? AND network_end >= ? AND is_active = ?", key.NetworkStart, key.NetworkStart, true).
+		Where("expires_at IS NULL OR expires_at > ?", time.Now()).
+		Order("prefix_len DESC").
+		First(&registration).Error; err != nil {
+		return nil, err
+	}
+	return &registration, nil
+}
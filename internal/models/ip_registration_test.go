@@ -0,0 +1,200 @@
+package models
+
+import (
+	"errors"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"gorm.io/driver/sqlite"
+	"gorm.io/gorm"
+)
+
+func newTestDB(t *testing.T) *gorm.DB {
+	t.Helper()
+
+	db, err := gorm.Open(sqlite.Open(filepath.Join(t.TempDir(), "zikzi.db")), &gorm.Config{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := db.AutoMigrate(&User{}, &IPRegistration{}, &IPClaimCode{}); err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
+// register stores a registration of address for userID, applying any changes before saving
+func register(t *testing.T, db *gorm.DB, userID, address string, changes ...func(*IPRegistration)) *IPRegistration {
+	t.Helper()
+
+	registration := &IPRegistration{UserID: userID, IsActive: true}
+	if err := registration.SetAddress(address); err != nil {
+		t.Fatal(err)
+	}
+	for _, change := range changes {
+		change(registration)
+	}
+	active := registration.IsActive
+	if err := db.Create(registration).Error; err != nil {
+		t.Fatal(err)
+	}
+	// is_active defaults to true, so false has to be written explicitly
+	if !active {
+		if err := db.Model(registration).Update("is_active", false).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	return registration
+}
+
+func addresses(registrations []IPRegistration) []string {
+	list := []string{}
+	for _, registration := range registrations {
+		list = append(list, registration.IPAddress)
+	}
+	return list
+}
+
+func TestOverlapping(t *testing.T) {
+	db := newTestDB(t)
+	register(t, db, "alice", "10.0.0.0/16")
+	subnet := register(t, db, "bob", "10.0.1.0/24")
+	register(t, db, "carol", "10.0.1.7")
+	register(t, db, "alice", "10.0.2.0/24")
+	register(t, db, "bob", "2001:db8::/64")
+
+	tests := []struct {
+		address string
+		want    []string
+	}{
+		{"10.0.1.0/24", []string{"10.0.1.7", "10.0.1.0/24", "10.0.0.0/16"}},
+		{"10.0.1.8", []string{"10.0.1.0/24", "10.0.0.0/16"}},
+		{"10.0.1.7", []string{"10.0.1.7", "10.0.1.0/24", "10.0.0.0/16"}},
+		{"::ffff:10.0.1.7", []string{"10.0.1.7", "10.0.1.0/24", "10.0.0.0/16"}},
+		{"10.0.3.0/24", []string{"10.0.0.0/16"}},
+		{"10.0.0.0/8", []string{"10.0.1.7", "10.0.1.0/24", "10.0.2.0/24", "10.0.0.0/16"}},
+		{"10.1.0.0/24", []string{}},
+		{"2001:db8::1", []string{"2001:db8::/64"}},
+		{"2001:db8:1::/64", []string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.address, func(t *testing.T) {
+			var candidate IPRegistration
+			if err := candidate.SetAddress(tt.address); err != nil {
+				t.Fatal(err)
+			}
+			overlapping, err := candidate.Overlapping(db)
+			if err != nil {
+				t.Fatal(err)
+			}
+			// Ranges of the same prefix length can't overlap, so the order is fixed
+			if got := addresses(overlapping); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("Overlapping(%s) = %v, want %v", tt.address, got, tt.want)
+			}
+		})
+	}
+
+	t.Run("excludes itself", func(t *testing.T) {
+		overlapping, err := subnet.Overlapping(db)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got, want := addresses(overlapping), []string{"10.0.1.7", "10.0.0.0/16"}; !reflect.DeepEqual(got, want) {
+			t.Errorf("Overlapping = %v, want %v", got, want)
+		}
+	})
+}
+
+func TestFindIPRegistration(t *testing.T) {
+	db := newTestDB(t)
+	past := time.Now().Add(-time.Hour)
+	future := time.Now().Add(time.Hour)
+
+	register(t, db, "alice", "10.0.0.0/16")
+	register(t, db, "bob", "10.0.1.0/24")
+	register(t, db, "carol", "10.0.1.7")
+	register(t, db, "carol", "10.0.1.8", func(r *IPRegistration) { r.IsActive = false })
+	register(t, db, "carol", "10.0.1.9", func(r *IPRegistration) { r.ExpiresAt = &past })
+	register(t, db, "carol", "10.0.1.10", func(r *IPRegistration) { r.ExpiresAt = &future })
+	register(t, db, "bob", "2001:db8::/64")
+	register(t, db, "carol", "2001:db8::1")
+
+	tests := []struct {
+		ip   string
+		want string // Empty when no registration matches
+	}{
+		{"10.0.1.7", "10.0.1.7"},
+		{"::ffff:10.0.1.7", "10.0.1.7"},
+		{"10.0.1.8", "10.0.1.0/24"},   // Most specific is inactive
+		{"10.0.1.9", "10.0.1.0/24"},   // Most specific has expired
+		{"10.0.1.10", "10.0.1.10"},    // Not expired yet
+		{"10.0.1.200", "10.0.1.0/24"}, // Only ranges match
+		{"10.0.5.5", "10.0.0.0/16"},
+		{"10.1.0.1", ""},
+		{"2001:db8::1", "2001:db8::1"},
+		{"2001:db8::2", "2001:db8::/64"},
+		{"2001:db8:1::1", ""},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			registration, err := FindIPRegistration(db, tt.ip)
+			if tt.want == "" {
+				if err == nil {
+					t.Fatalf("FindIPRegistration(%s) = %s, want no match", tt.ip, registration.IPAddress)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("FindIPRegistration(%s): %v", tt.ip, err)
+			}
+			if registration.IPAddress != tt.want {
+				t.Errorf("FindIPRegistration(%s) = %s, want %s", tt.ip, registration.IPAddress, tt.want)
+			}
+		})
+	}
+}
+
+func TestRedeemClaimCodeOverlap(t *testing.T) {
+	db := newTestDB(t)
+	for _, user := range []User{
+		{ID: "alice", Username: "alice", Email: "alice@example.com"},
+		{ID: "bob", Username: "bob", Email: "bob@example.com"},
+		{ID: "admin", Username: "admin", Email: "admin@example.com", IsAdmin: true},
+	} {
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	register(t, db, "alice", "10.0.1.0/24")
+	register(t, db, "alice", "10.0.2.5")
+
+	tests := []struct {
+		name    string
+		userID  string
+		ip      string
+		wantErr error
+	}{
+		{"inside another user's range", "bob", "10.0.1.7", ErrClaimCodeConflict},
+		{"address of another user", "bob", "10.0.2.5", ErrClaimCodeConflict},
+		{"inside own range", "alice", "10.0.1.8", nil},
+		{"admin inside another user's range", "admin", "10.0.1.9", nil},
+		{"admin on another user's address", "admin", "10.0.2.5", ErrClaimCodeConflict},
+		{"free address", "bob", "10.0.3.1", nil},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			claim, err := CreateClaimCode(db, tt.userID, "", time.Minute)
+			if err != nil {
+				t.Fatal(err)
+			}
+			registration, err := RedeemClaimCode(db, claim.Code, tt.ip)
+			if !errors.Is(err, tt.wantErr) {
+				t.Fatalf("RedeemClaimCode(%s) error = %v, want %v", tt.ip, err, tt.wantErr)
+			}
+			if err == nil && registration.UserID != tt.userID {
+				t.Errorf("registered to %s, want %s", registration.UserID, tt.userID)
+			}
+		})
+	}
+}
@@ -3,6 +3,10 @@ package models
 import (
 	"crypto/rand"
 	"crypto/subtle"
+	"errors"
+	"fmt"
+	"slices"
+	"strings"
 	"time"
 
 	"github.com/alex4386/zikzi/internal/utils"
@@ -19,16 +23,29 @@ type IPPToken struct {
 	UpdatedAt time.Time      `json:"updated_at"`
 	DeletedAt gorm.DeletedAt `gorm:"index" json:"-"`
 
-	UserID     string     `gorm:"type:varchar(12);index;not null" json:"user_id"`
-	User       User       `gorm:"foreignKey:UserID" json:"-"`
-	Name       string     `gorm:"not null" json:"name"` // Human-readable token name
-	Token      string     `gorm:"not null" json:"-"`    // Plaintext token value (for Digest auth compatibility)
-	LastUsedAt *time.Time `json:"last_used_at"`         // Track last usage
-	LastUsedIP string     `json:"last_used_ip"`         // Track last IP
-	ExpiresAt  *time.Time `json:"expires_at"`           // Optional expiration
-	IsActive   bool       `gorm:"default:true;not null" json:"is_active"`
+	UserID      string     `gorm:"type:varchar(12);index;not null" json:"user_id"`
+	User        User       `gorm:"foreignKey:UserID" json:"-"`
+	Name        string     `gorm:"not null" json:"name"`           // Human-readable token name
+	LegacyToken string     `gorm:"column:token;not null" json:"-"` // Plaintext token from before hashing, cleared by HashIPPTokens
+	TokenHash   string     `json:"-"`                              // bcrypt hash of the token, for Basic auth
+	DigestRealm string     `json:"digest_realm"`                   // Realm the Digest hashes were computed for
+	LastUsedAt  *time.Time `json:"last_used_at"`                   // Track last usage
+	LastUsedIP  string     `json:"last_used_ip"`                   // Track last IP
+	ExpiresAt   *time.Time `json:"expires_at"`                     // Optional expiration
+	IsActive    bool       `gorm:"default:true;not null" json:"is_active"`
+
+	// Pre-computed H(username:realm:token) for Digest auth
+	DigestHashes
+
+	// Optional restrictions, empty means unrestricted
+	Queues      string `json:"queues"`                                    // Comma-separated IPP printer names the token may print to
+	SourceCIDRs string `gorm:"column:source_cidrs" json:"source_cidrs"`   // Comma-separated networks the token may be used from
+	SubmitOnly  bool   `gorm:"default:false;not null" json:"submit_only"` // Only submit jobs, no listing or cancelling
 }
 
+// ErrTokenScope is returned when a request is outside of a token's restrictions
+var ErrTokenScope = errors.New("outside of token scope")
+
 func (t *IPPToken) BeforeCreate(tx *gorm.DB) error {
 	if t.ID == "" {
 		t.ID = utils.GenerateShortID()
@@ -65,7 +82,116 @@ func GenerateIPPToken() (string, error) {
 	return string(result), nil
 }
 
-// VerifyIPPToken checks if the provided token matches using constant-time comparison
-func VerifyIPPToken(storedToken, providedToken string) bool {
-	return subtle.ConstantTimeCompare([]byte(storedToken), []byte(providedToken)) == 1
+// QueueList returns the IPP printer names the token is restricted to
+func (t *IPPToken) QueueList() []string {
+	return splitList(t.Queues)
+}
+
+// SourceCIDRList returns the networks the token is restricted to
+func (t *IPPToken) SourceCIDRList() []string {
+	return splitList(t.SourceCIDRs)
+}
+
+// SetScope validates and stores the token's restrictions
+func (t *IPPToken) SetScope(queues, sourceCIDRs []string, submitOnly bool) error {
+	var queueList []string
+	for _, queue := range queues {
+		if queue = strings.TrimSpace(queue); queue == "" {
+			continue
+		}
+		if strings.ContainsAny(queue, ",/ ") {
+			return fmt.Errorf("invalid queue name %q", queue)
+		}
+		queueList = append(queueList, queue)
+	}
+
+	var networks []string
+	for _, cidr := range sourceCIDRs {
+		if strings.TrimSpace(cidr) == "" {
+			continue
+		}
+		network, err := ParseIPPrefix(cidr)
+		if err != nil {
+			return err
+		}
+		networks = append(networks, network.String())
+	}
+
+	t.Queues = strings.Join(queueList, ",")
+	t.SourceCIDRs = strings.Join(networks, ",")
+	t.SubmitOnly = submitOnly
+	return nil
+}
+
+// CheckScope returns an error wrapping ErrTokenScope naming the violated
+// restriction, if the token may not be used for the request
+func (t *IPPToken) CheckScope(queue, clientIP string, manage bool) error {
+	if queues := t.QueueList(); len(queues) > 0 && !slices.Contains(queues, queue) {
+		return fmt.Errorf("%w: queue %q is not allowed", ErrTokenScope, queue)
+	}
+
+	if networks := t.SourceCIDRList(); len(networks) > 0 {
+		ip, ipErr := ParseIPPrefix(clientIP)
+		allowed := false
+		for _, cidr := range networks {
+			if network, err := ParseIPPrefix(cidr); err == nil && ipErr == nil && network.Contains(ip.Addr()) {
+				allowed = true
+				break
+			}
+		}
+		if !allowed {
+			return fmt.Errorf("%w: source %s is not allowed", ErrTokenScope, clientIP)
+		}
+	}
+
+	if manage && t.SubmitOnly {
+		return fmt.Errorf("%w: token may only submit jobs", ErrTokenScope)
+	}
+	return nil
+}
+
+// SetSecret stores the token value as a bcrypt hash and Digest hashes bound to
+// the username and realm. The value itself is not kept.
+func (t *IPPToken) SetSecret(username, realm, value string) error {
+	hash, err := utils.HashToken(value)
+	if err != nil {
+		return err
+	}
+	t.TokenHash = hash
+	t.SetDigest(username, realm, value)
+	t.DigestRealm = realm
+	t.LegacyToken = ""
+	return nil
+}
+
+// Verify checks a provided token value against the stored hash
+func (t *IPPToken) Verify(value string) bool {
+	if t.TokenHash == "" {
+		// Not converted yet
+		return t.LegacyToken != "" && subtle.ConstantTimeCompare([]byte(t.LegacyToken), []byte(value)) == 1
+	}
+	return utils.VerifyPassword(t.TokenHash, value)
+}
+
+// IPPTokenUsage records a successful authentication with an IPP token
+type IPPTokenUsage struct {
+	ID        uint      `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+
+	TokenID   string `gorm:"type:varchar(12);index;not null" json:"token_id"`
+	Method    string `json:"method"`    // basic, digest
+	Operation string `json:"operation"` // IPP operation, e.g. Print-Job
+	IP        string `json:"ip"`
+	JobID     string `gorm:"type:varchar(12)" json:"job_id,omitempty"` // Job created by the request
+}
+
+// splitList splits a comma-separated list, dropping empty entries
+func splitList(list string) []string {
+	var items []string
+	for _, item := range strings.Split(list, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+	return items
 }
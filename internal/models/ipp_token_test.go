@@ -0,0 +1,46 @@
+package models
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestTokenSourceScope(t *testing.T) {
+	var token IPPToken
+	if err := token.SetScope(nil, []string{"10.0.1.77/24", " 192.168.1.5 ", "::ffff:172.16.0.1", "2001:db8::/64"}, false); err != nil {
+		t.Fatal(err)
+	}
+	if got, want := token.SourceCIDRs, "10.0.1.0/24,192.168.1.5/32,172.16.0.1/32,2001:db8::/64"; got != want {
+		t.Errorf("SourceCIDRs = %q, want %q", got, want)
+	}
+
+	tests := []struct {
+		ip      string
+		allowed bool
+	}{
+		{"10.0.1.200", true},
+		{"::ffff:10.0.1.200", true},
+		{"10.0.2.1", false},
+		{"192.168.1.5", true},
+		{"192.168.1.6", false},
+		{"172.16.0.1", true},
+		{"2001:db8::1", true},
+		{"2001:db8:1::1", false},
+		{"not an ip", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.ip, func(t *testing.T) {
+			err := token.CheckScope("", tt.ip, false)
+			if tt.allowed && err != nil {
+				t.Errorf("CheckScope(%s) = %v, want allowed", tt.ip, err)
+			}
+			if !tt.allowed && !errors.Is(err, ErrTokenScope) {
+				t.Errorf("CheckScope(%s) = %v, want ErrTokenScope", tt.ip, err)
+			}
+		})
+	}
+
+	if err := token.SetScope(nil, []string{"10.0.0.0/33"}, false); err == nil {
+		t.Error("SetScope accepted an invalid CIDR range")
+	}
+}
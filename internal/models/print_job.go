@@ -27,14 +27,42 @@ type PrintJob struct {
 	OriginalFile  string `json:"original_file"`  // Path to stored PostScript
 	PDFFile       string `json:"pdf_file"`       // Path to generated PDF
 	ThumbnailFile string `json:"thumbnail_file"` // Path to thumbnail image
+	PDFSize       int64  `json:"pdf_size"`       // Size of the generated PDF
+	ThumbnailSize int64  `json:"thumbnail_size"` // Size of the thumbnail image
+	Signed        bool   `json:"signed"`         // PDF carries a PAdES signature
+
+	// SHA-256 checksums recorded when each file is written
+	OriginalSHA256  string `gorm:"type:varchar(64)" json:"original_sha256,omitempty"`
+	PDFSHA256       string `gorm:"type:varchar(64)" json:"pdf_sha256,omitempty"`
+	ThumbnailSHA256 string `gorm:"type:varchar(64)" json:"thumbnail_sha256,omitempty"`
+
+	// Envelope encryption: per-job data key wrapped by the master key
+	DataKey string `gorm:"type:text" json:"-"`
+	KeyID   string `gorm:"type:varchar(16);index" json:"-"` // Identifies the master key that wrapped DataKey
 
 	// Job metadata
+	Queue     string `gorm:"index" json:"queue"` // Intake the job arrived through: raw, ipp, web
 	PageCount int    `json:"page_count"`
 	FileSize  int64  `json:"file_size"`
 	Status    string `gorm:"index;default:received" json:"status"` // received, processing, completed, failed
 
 	ProcessedAt *time.Time `json:"processed_at,omitempty"`
 	Error       string     `json:"error,omitempty"`
+
+	// Email delivery of the PDF, for users who opted in
+	EmailStatus string     `json:"email_status,omitempty"` // sent, failed
+	EmailError  string     `json:"email_error,omitempty"`
+	EmailedAt   *time.Time `json:"emailed_at,omitempty"`
+
+	// Downstream printers the job is also printed on
+	ForwardTo string       `json:"forward_to,omitempty"` // Comma-separated printer names requested for this job
+	Forwards  []JobForward `gorm:"foreignKey:JobID" json:"forwards,omitempty"`
+
+	// Organization
+	FolderID *string `gorm:"type:varchar(12);index" json:"folder_id,omitempty"`
+	Folder   *Folder `gorm:"foreignKey:FolderID;constraint:OnDelete:SET NULL" json:"folder,omitempty"`
+	Tags     []Tag   `gorm:"many2many:print_job_tags;constraint:OnDelete:CASCADE" json:"tags,omitempty"`
+	Note     string  `gorm:"type:text" json:"note,omitempty"`
 }
 
 func (j *PrintJob) BeforeCreate(tx *gorm.DB) error {
@@ -50,3 +78,14 @@ const (
 	JobStatusCompleted  = "completed"
 	JobStatusFailed     = "failed"
 )
+
+const (
+	EmailStatusSent   = "sent"
+	EmailStatusFailed = "failed"
+)
+
+const (
+	JobQueueRaw = "raw"
+	JobQueueIPP = "ipp"
+	JobQueueWeb = "web"
+)
@@ -0,0 +1,86 @@
+package models
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Share access actions
+const (
+	ShareActionView     = "view"
+	ShareActionDownload = "download"
+)
+
+// JobShare is a public, expiring download link for a job's PDF
+type JobShare struct {
+	ID        string    `gorm:"type:varchar(12);primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	JobID         string     `gorm:"type:varchar(12);index;not null" json:"job_id"`
+	UserID        string     `gorm:"type:varchar(12);index;not null" json:"user_id"` // User who created the link
+	TokenHash     string     `gorm:"uniqueIndex;not null" json:"-"`                  // SHA-256 of the link token
+	PasswordHash  string     `json:"-"`                                              // Optional bcrypt password hash
+	ExpiresAt     time.Time  `gorm:"not null" json:"expires_at"`
+	MaxDownloads  int        `gorm:"default:0;not null" json:"max_downloads"` // 0 = unlimited
+	DownloadCount int        `gorm:"default:0;not null" json:"download_count"`
+	RevokedAt     *time.Time `json:"revoked_at"`
+}
+
+func (s *JobShare) BeforeCreate(tx *gorm.DB) error {
+	if s.ID == "" {
+		s.ID = utils.GenerateShortID()
+	}
+	return nil
+}
+
+// HasPassword reports whether the link is password protected
+func (s *JobShare) HasPassword() bool {
+	return s.PasswordHash != ""
+}
+
+// IsExpired checks if the link has expired
+func (s *JobShare) IsExpired() bool {
+	return time.Now().After(s.ExpiresAt)
+}
+
+// IsExhausted checks if the link has reached its download limit
+func (s *JobShare) IsExhausted() bool {
+	return s.MaxDownloads > 0 && s.DownloadCount >= s.MaxDownloads
+}
+
+// IsActive checks if the link can still be used
+func (s *JobShare) IsActive() bool {
+	return s.RevokedAt == nil && !s.IsExpired() && !s.IsExhausted()
+}
+
+// ShareAccess records a request made with a share link. Repeated refusals
+// from one IP address are folded into a single record.
+type ShareAccess struct {
+	ID        uint       `gorm:"primarykey" json:"id"`
+	CreatedAt time.Time  `json:"created_at"`
+	LastAt    *time.Time `json:"last_at,omitempty"` // Latest refusal folded into this record
+
+	ShareID   string `gorm:"type:varchar(12);index;not null" json:"share_id"`
+	Action    string `json:"action"` // view, download
+	IP        string `json:"ip"`
+	UserAgent string `json:"user_agent"`
+	Success   bool   `json:"success"`
+	Reason    string `json:"reason,omitempty"`       // Why the request was refused
+	Count     int    `gorm:"default:1" json:"count"` // Requests this record stands for
+}
+
+// GenerateShareToken generates a new random share link token
+func GenerateShareToken() (string, error) {
+	return GenerateIPPToken()
+}
+
+// HashShareToken returns the hash a share token is stored and looked up by
+func HashShareToken(token string) string {
+	sum := sha256.Sum256([]byte(token))
+	return hex.EncodeToString(sum[:])
+}
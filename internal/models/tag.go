@@ -0,0 +1,74 @@
+package models
+
+import (
+	"time"
+
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Tag is a user-defined label that can be attached to print jobs
+type Tag struct {
+	ID        string    `gorm:"type:varchar(12);primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID string `gorm:"type:varchar(12);uniqueIndex:idx_tags_user_name;not null" json:"user_id"`
+	Name   string `gorm:"uniqueIndex:idx_tags_user_name;not null" json:"name"`
+	Color  string `gorm:"type:varchar(7)" json:"color,omitempty"` // Hex color for the WebUI (e.g. "#ff8800")
+}
+
+func (t *Tag) BeforeCreate(tx *gorm.DB) error {
+	if t.ID == "" {
+		t.ID = utils.GenerateShortID()
+	}
+	return nil
+}
+
+// TagRule automatically tags new jobs whose field matches a regular expression
+type TagRule struct {
+	ID        string    `gorm:"type:varchar(12);primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID  string `gorm:"type:varchar(12);index;not null" json:"user_id"`
+	TagID   string `gorm:"type:varchar(12);index;not null" json:"tag_id"`
+	Tag     *Tag   `gorm:"foreignKey:TagID;constraint:OnDelete:CASCADE" json:"tag,omitempty"`
+	Field   string `gorm:"not null" json:"field"`   // document_name, app_name, hostname, source_ip, queue
+	Pattern string `gorm:"not null" json:"pattern"` // Go regular expression
+	Enabled bool   `gorm:"default:true" json:"enabled"`
+}
+
+func (r *TagRule) BeforeCreate(tx *gorm.DB) error {
+	if r.ID == "" {
+		r.ID = utils.GenerateShortID()
+	}
+	return nil
+}
+
+// Fields a tag rule can match against
+const (
+	TagRuleFieldDocumentName = "document_name"
+	TagRuleFieldAppName      = "app_name"
+	TagRuleFieldHostname     = "hostname"
+	TagRuleFieldSourceIP     = "source_ip"
+	TagRuleFieldQueue        = "queue"
+)
+
+// FieldValue returns the value of a tag rule field on a job
+func (j *PrintJob) FieldValue(field string) (string, bool) {
+	switch field {
+	case TagRuleFieldDocumentName:
+		return j.DocumentName, true
+	case TagRuleFieldAppName:
+		return j.AppName, true
+	case TagRuleFieldHostname:
+		return j.Hostname, true
+	case TagRuleFieldSourceIP:
+		return j.SourceIP, true
+	case TagRuleFieldQueue:
+		return j.Queue, true
+	default:
+		return "", false
+	}
+}
@@ -19,7 +19,7 @@ type User struct {
 
 	// Local auth (optional)
 	PasswordHash string `json:"-"`
-	DigestHA1    string `json:"-"` // Pre-computed MD5(username:realm:password) for Digest auth
+	DigestHashes        // Pre-computed H(username:realm:password) for Digest auth
 
 	// OIDC fields
 	OIDCSubject  string `gorm:"column:oidc_subject;index" json:"-"`
@@ -33,6 +33,14 @@ type User struct {
 
 	// IPP authentication settings
 	AllowIPPPassword bool `gorm:"column:allow_ipp_password;default:true" json:"allow_ipp_password"` // Allow using account password for IPP auth
+
+	// Email the PDF of every completed job to the user
+	EmailPrints bool `gorm:"default:false" json:"email_prints"`
+
+	// Quota overrides: 0 uses the configured default, -1 is unlimited
+	QuotaBytes         int64 `gorm:"default:0" json:"quota_bytes"`
+	QuotaJobsPerDay    int   `gorm:"default:0" json:"quota_jobs_per_day"`
+	QuotaPagesPerMonth int   `gorm:"default:0" json:"quota_pages_per_month"`
 }
 
 func (u *User) BeforeCreate(tx *gorm.DB) error {
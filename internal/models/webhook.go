@@ -0,0 +1,96 @@
+package models
+
+import (
+	"strings"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Webhook delivery states
+const (
+	DeliveryPending   = "pending"
+	DeliverySucceeded = "succeeded"
+	DeliveryFailed    = "failed"
+)
+
+// Webhook posts job events to an external URL. Webhooks without a user are
+// managed by admins and receive events for every user's jobs.
+type Webhook struct {
+	ID        string    `gorm:"type:varchar(12);primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	UserID    *string `gorm:"type:varchar(12);index" json:"user_id"` // nil for global webhooks
+	Name      string  `gorm:"not null" json:"name"`
+	URL       string  `gorm:"not null" json:"url"`
+	Secret    string  `gorm:"not null" json:"-"`                        // HMAC-SHA256 signing key
+	Events    string  `gorm:"not null" json:"events"`                   // Comma-separated event types
+	AttachPDF bool    `gorm:"default:false;not null" json:"attach_pdf"` // Send the PDF as multipart/form-data
+	Enabled   bool    `gorm:"default:true;not null" json:"enabled"`
+}
+
+func (w *Webhook) BeforeCreate(tx *gorm.DB) error {
+	if w.ID == "" {
+		w.ID = utils.GenerateShortID()
+	}
+	return nil
+}
+
+// IsGlobal reports whether the webhook receives events for all users
+func (w *Webhook) IsGlobal() bool {
+	return w.UserID == nil
+}
+
+// EventList returns the event types the webhook is subscribed to
+func (w *Webhook) EventList() []string {
+	var list []string
+	for _, event := range strings.Split(w.Events, ",") {
+		if event = strings.TrimSpace(event); event != "" {
+			list = append(list, event)
+		}
+	}
+	return list
+}
+
+// Subscribes reports whether the webhook wants events of the given type
+func (w *Webhook) Subscribes(eventType string) bool {
+	for _, event := range w.EventList() {
+		if event == eventType {
+			return true
+		}
+	}
+	return false
+}
+
+// GenerateWebhookSecret generates a new signing secret for a webhook
+func GenerateWebhookSecret() (string, error) {
+	return GenerateIPPToken()
+}
+
+// WebhookDelivery records a webhook call and its retries
+type WebhookDelivery struct {
+	ID        string    `gorm:"type:varchar(12);primarykey" json:"id"`
+	CreatedAt time.Time `json:"created_at"`
+	UpdatedAt time.Time `json:"updated_at"`
+
+	WebhookID     string     `gorm:"type:varchar(12);index;not null" json:"webhook_id"`
+	JobID         string     `gorm:"type:varchar(12);index" json:"job_id,omitempty"`
+	Event         string     `gorm:"not null" json:"event"`
+	Payload       string     `gorm:"type:text;not null" json:"payload"`
+	Status        string     `gorm:"index;not null" json:"status"` // pending, succeeded, failed
+	Attempts      int        `gorm:"default:0;not null" json:"attempts"`
+	StatusCode    int        `json:"status_code,omitempty"` // HTTP status of the last attempt
+	Error         string     `json:"error,omitempty"`       // Error of the last attempt
+	DurationMs    int64      `json:"duration_ms"`           // Duration of the last attempt
+	NextAttemptAt *time.Time `gorm:"index" json:"next_attempt_at,omitempty"`
+	DeliveredAt   *time.Time `json:"delivered_at,omitempty"`
+}
+
+func (d *WebhookDelivery) BeforeCreate(tx *gorm.DB) error {
+	if d.ID == "" {
+		d.ID = utils.GenerateShortID()
+	}
+	return nil
+}
@@ -0,0 +1,45 @@
+package printer
+
+import (
+	"errors"
+
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+	"gorm.io/gorm"
+)
+
+// claimScanLimit is how much of a document is searched for a claim code.
+// Pairing pages are a single line of text.
+const claimScanLimit = 1 << 20
+
+// claimResult is the outcome of looking for a claim code in a document
+type claimResult struct {
+	found        bool // The document carried a valid claim code and must be discarded
+	registration *models.IPRegistration
+	err          error // Why the address couldn't be registered
+}
+
+// claimIP registers clientIP to the owner of the claim code in data, if any.
+// Documents with unknown or expired codes are treated as ordinary jobs.
+func claimIP(db *gorm.DB, data []byte, clientIP string) claimResult {
+	if len(data) > claimScanLimit {
+		data = data[:claimScanLimit]
+	}
+	code := models.ClaimCodePattern.Find(data)
+	if code == nil {
+		return claimResult{}
+	}
+
+	registration, err := models.RedeemClaimCode(db, string(code), clientIP)
+	switch {
+	case errors.Is(err, models.ErrClaimCodeInvalid):
+		logger.Debug("Ignoring invalid claim code from %s", clientIP)
+		return claimResult{}
+	case err != nil:
+		logger.Warn("Failed to register %s with a claim code: %v", clientIP, err)
+		return claimResult{found: true, err: err}
+	}
+
+	logger.Info("Registered %s to user %s with a claim code", registration.IPAddress, registration.UserID)
+	return claimResult{found: true, registration: registration}
+}
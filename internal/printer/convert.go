@@ -0,0 +1,274 @@
+package printer
+
+import (
+	"bufio"
+	"compress/zlib"
+	"encoding/ascii85"
+	"fmt"
+	"image"
+	"image/color"
+	_ "image/gif"
+	_ "image/jpeg"
+	_ "image/png"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// A4 page layout used for converted uploads, in points
+const (
+	pageWidth  = 595
+	pageHeight = 842
+	pageMargin = 36
+
+	textFontSize   = 10
+	textLineHeight = 12
+	textCharWidth  = 6 // Courier advance width at 10pt
+	textTabWidth   = 8
+
+	// Refuse images that would need an unreasonable amount of memory to decode
+	maxImagePixels = 50_000_000
+)
+
+// needsPostScript reports whether GhostScript can't read the file directly
+// and it has to be converted to PostScript first
+func needsPostScript(path string) bool {
+	switch strings.ToLower(filepath.Ext(path)) {
+	case ".png", ".jpg", ".jpeg", ".gif", ".txt":
+		return true
+	}
+	return false
+}
+
+// toPostScript converts an image or plain text file into a PostScript file
+func toPostScript(inputPath, outputPath, title string) error {
+	in, err := os.Open(inputPath)
+	if err != nil {
+		return err
+	}
+	defer in.Close()
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	if strings.ToLower(filepath.Ext(inputPath)) == ".txt" {
+		err = writeTextPostScript(w, in, title)
+	} else {
+		cfg, _, cerr := image.DecodeConfig(in)
+		if cerr != nil {
+			return fmt.Errorf("failed to decode image: %w", cerr)
+		}
+		if cfg.Width*cfg.Height > maxImagePixels {
+			return fmt.Errorf("image too large: %dx%d", cfg.Width, cfg.Height)
+		}
+		if _, err := in.Seek(0, io.SeekStart); err != nil {
+			return err
+		}
+		err = writeImagePostScript(w, in, title)
+	}
+	if err != nil {
+		return err
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+	return out.Close()
+}
+
+// writeImagePostScript renders an image on a single A4 page, scaled to fit and centered.
+// The page is turned to landscape for wide images.
+func writeImagePostScript(w io.Writer, r io.Reader, title string) error {
+	img, _, err := image.Decode(r)
+	if err != nil {
+		return fmt.Errorf("failed to decode image: %w", err)
+	}
+
+	bounds := img.Bounds()
+	width, height := bounds.Dx(), bounds.Dy()
+	if width == 0 || height == 0 {
+		return fmt.Errorf("image is empty")
+	}
+
+	pw, ph := float64(pageWidth), float64(pageHeight)
+	if width > height {
+		pw, ph = ph, pw
+	}
+	scale := min((pw-2*pageMargin)/float64(width), (ph-2*pageMargin)/float64(height))
+	sw, sh := float64(width)*scale, float64(height)*scale
+
+	writePostScriptHeader(w, title, pw, ph, 1)
+	fmt.Fprintf(w, "%%%%Page: 1 1\n")
+	fmt.Fprintf(w, "<< /PageSize [%g %g] >> setpagedevice\n", pw, ph)
+	fmt.Fprintf(w, "gsave\n%.2f %.2f translate\n%.2f %.2f scale\n", (pw-sw)/2, (ph-sh)/2, sw, sh)
+	fmt.Fprintf(w, "/DeviceRGB setcolorspace\n")
+	fmt.Fprintf(w, "<< /ImageType 1 /Width %d /Height %d /BitsPerComponent 8 /Decode [0 1 0 1 0 1]\n", width, height)
+	fmt.Fprintf(w, "   /ImageMatrix [%d 0 0 -%d 0 %d]\n", width, height, height)
+	fmt.Fprintf(w, "   /DataSource currentfile /ASCII85Decode filter /FlateDecode filter >> image\n")
+
+	// Pixels are written as RGB rows, with transparency composited onto white
+	enc := ascii85.NewEncoder(&lineWrapper{w: w, width: 76})
+	zw := zlib.NewWriter(enc)
+	row := make([]byte, width*3)
+	for y := bounds.Min.Y; y < bounds.Max.Y; y++ {
+		for x := bounds.Min.X; x < bounds.Max.X; x++ {
+			c := color.NRGBAModel.Convert(img.At(x, y)).(color.NRGBA)
+			i := (x - bounds.Min.X) * 3
+			row[i] = blendWhite(c.R, c.A)
+			row[i+1] = blendWhite(c.G, c.A)
+			row[i+2] = blendWhite(c.B, c.A)
+		}
+		if _, err := zw.Write(row); err != nil {
+			return err
+		}
+	}
+	if err := zw.Close(); err != nil {
+		return err
+	}
+	if err := enc.Close(); err != nil {
+		return err
+	}
+
+	fmt.Fprintf(w, "~>\ngrestore\nshowpage\n%%%%EOF\n")
+	return nil
+}
+
+// writeTextPostScript typesets plain text in Courier on A4 pages, wrapping long lines.
+// Characters outside Latin-1 can't be shown with the standard fonts and print as "?".
+func writeTextPostScript(w io.Writer, r io.Reader, title string) error {
+	perLine := (pageWidth - 2*pageMargin) / textCharWidth
+	perPage := (pageHeight - 2*pageMargin) / textLineHeight
+
+	var lines []string
+	scanner := bufio.NewScanner(r)
+	scanner.Buffer(make([]byte, 64*1024), 1024*1024)
+	for scanner.Scan() {
+		lines = append(lines, wrapLine(expandTabs(scanner.Text()), perLine)...)
+	}
+	if err := scanner.Err(); err != nil {
+		return fmt.Errorf("failed to read text: %w", err)
+	}
+
+	pages := max((len(lines)+perPage-1)/perPage, 1)
+	writePostScriptHeader(w, title, pageWidth, pageHeight, pages)
+	fmt.Fprintf(w, "/Courier findfont dup length dict begin\n")
+	fmt.Fprintf(w, "  { 1 index /FID ne { def } { pop pop } ifelse } forall\n")
+	fmt.Fprintf(w, "  /Encoding ISOLatin1Encoding def\n")
+	fmt.Fprintf(w, "currentdict end /Courier-ISO exch definefont pop\n")
+
+	for page := 0; page < pages; page++ {
+		fmt.Fprintf(w, "%%%%Page: %d %d\n", page+1, page+1)
+		fmt.Fprintf(w, "<< /PageSize [%d %d] >> setpagedevice\n", pageWidth, pageHeight)
+		fmt.Fprintf(w, "/Courier-ISO findfont %d scalefont setfont\n", textFontSize)
+
+		start := page * perPage
+		end := min(start+perPage, len(lines))
+		for i := start; i < end; i++ {
+			y := pageHeight - pageMargin - textFontSize - (i-start)*textLineHeight
+			fmt.Fprintf(w, "%d %d moveto (%s) show\n", pageMargin, y, escapePostScript(lines[i]))
+		}
+		fmt.Fprintf(w, "showpage\n")
+	}
+
+	fmt.Fprintf(w, "%%%%EOF\n")
+	return nil
+}
+
+func writePostScriptHeader(w io.Writer, title string, width, height float64, pages int) {
+	fmt.Fprintf(w, "%%!PS-Adobe-3.0\n")
+	fmt.Fprintf(w, "%%%%Title: (%s)\n", escapePostScript(title))
+	fmt.Fprintf(w, "%%%%Creator: Zikzi\n")
+	fmt.Fprintf(w, "%%%%BoundingBox: 0 0 %d %d\n", int(width), int(height))
+	fmt.Fprintf(w, "%%%%Pages: %d\n", pages)
+	fmt.Fprintf(w, "%%%%EndComments\n")
+}
+
+// blendWhite composites a non-premultiplied color channel onto a white background
+func blendWhite(v, alpha uint8) uint8 {
+	return uint8((uint16(v)*uint16(alpha) + 255*uint16(255-alpha)) / 255)
+}
+
+// expandTabs replaces tabs with spaces up to the next tab stop
+func expandTabs(line string) string {
+	if !strings.Contains(line, "\t") {
+		return line
+	}
+	var b strings.Builder
+	col := 0
+	for _, r := range line {
+		if r == '\t' {
+			n := textTabWidth - col%textTabWidth
+			b.WriteString(strings.Repeat(" ", n))
+			col += n
+			continue
+		}
+		b.WriteRune(r)
+		col++
+	}
+	return b.String()
+}
+
+// wrapLine splits a line into chunks of at most width characters
+func wrapLine(line string, width int) []string {
+	runes := []rune(strings.TrimRight(line, "\r"))
+	if len(runes) <= width {
+		return []string{string(runes)}
+	}
+	var out []string
+	for len(runes) > width {
+		out = append(out, string(runes[:width]))
+		runes = runes[width:]
+	}
+	return append(out, string(runes))
+}
+
+// escapePostScript encodes a string for use inside a PostScript string literal
+func escapePostScript(s string) string {
+	var b strings.Builder
+	for _, r := range s {
+		switch {
+		case r == '(' || r == ')' || r == '\\':
+			b.WriteByte('\\')
+			b.WriteRune(r)
+		case r >= 0x20 && r < 0x7f:
+			b.WriteRune(r)
+		case r >= 0xa0 && r <= 0xff:
+			fmt.Fprintf(&b, "\\%03o", r)
+		default:
+			b.WriteByte('?')
+		}
+	}
+	return b.String()
+}
+
+// lineWrapper breaks the ASCII85 stream into lines, since some PostScript
+// consumers limit line length
+type lineWrapper struct {
+	w     io.Writer
+	width int
+	col   int
+}
+
+func (l *lineWrapper) Write(p []byte) (int, error) {
+	written := 0
+	for len(p) > 0 {
+		n := min(l.width-l.col, len(p))
+		if _, err := l.w.Write(p[:n]); err != nil {
+			return written, err
+		}
+		written += n
+		l.col += n
+		p = p[n:]
+		if l.col == l.width {
+			if _, err := l.w.Write([]byte{'\n'}); err != nil {
+				return written, err
+			}
+			l.col = 0
+		}
+	}
+	return written, nil
+}
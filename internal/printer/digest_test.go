@@ -0,0 +1,391 @@
+package printer
+
+import (
+	"fmt"
+	"net/http/httptest"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/auth"
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/database"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/utils"
+)
+
+const (
+	testRealm    = "Zikzi Test"
+	testClientIP = "192.0.2.10"
+	testURI      = "/ipp/print"
+)
+
+// newDigestTestServer creates an IPP server on a fresh database that locks
+// accounts out after maxUserFailures failed logins
+func newDigestTestServer(t *testing.T, maxUserFailures int) *IPPServer {
+	t.Helper()
+
+	db, err := database.Connect(config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "zikzi.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+
+	cfg := config.IPPConfig{Auth: config.IPPAuthConfig{
+		AllowLogin:       true,
+		Realm:            testRealm,
+		DigestAlgorithms: utils.DigestAlgorithms,
+	}}
+	limiter := auth.NewLimiter(config.LockoutConfig{
+		Enabled:         true,
+		MaxUserFailures: maxUserFailures,
+		MaxIPFailures:   1000,
+		Window:          time.Hour,
+		Duration:        time.Minute,
+		MaxDuration:     time.Hour,
+	})
+	return NewIPPServer(cfg, config.PrinterConfig{}, nil, nil, nil, nil, db, limiter)
+}
+
+// addDigestUser creates a user whose password has the given Digest hashes
+func addDigestUser(t *testing.T, s *IPPServer, username string, hashes models.DigestHashes) *models.User {
+	t.Helper()
+
+	user := &models.User{Username: username, Email: username + "@example.com", AllowIPPPassword: true, DigestHashes: hashes}
+	if err := s.db.Create(user).Error; err != nil {
+		t.Fatal(err)
+	}
+	return user
+}
+
+// digestRequest holds the fields of a Digest Authorization header
+type digestRequest struct {
+	username, algorithm, nonce, nc, cnonce, qop string
+}
+
+// authorize returns the Authorization header answering the request with secret
+func (d digestRequest) authorize(secret string, body []byte) string {
+	ha1 := utils.ComputeDigestHA1With(d.algorithm, d.username, testRealm, secret)
+	response := computeDigestResponse(d.algorithm, ha1, d.nonce, d.nc, d.cnonce, d.qop, "POST", testURI, body)
+	header := fmt.Sprintf(`Digest username="%s", realm="%s", nonce="%s", uri="%s", algorithm=%s, response="%s"`,
+		d.username, testRealm, d.nonce, testURI, d.algorithm, response)
+	if d.qop != "" {
+		header += fmt.Sprintf(`, qop=%s, nc=%s, cnonce="%s"`, d.qop, d.nc, d.cnonce)
+	}
+	return header
+}
+
+// tryDigest answers a fresh nonce with secret for username
+func tryDigest(s *IPPServer, username, algorithm, secret string) (*models.User, *models.IPPToken, bool) {
+	req := digestRequest{username: username, algorithm: algorithm, nonce: s.nonceCache.generate(), nc: "00000001", cnonce: "0a4f113b", qop: "auth"}
+	header := req.authorize(secret, nil)
+	return s.authenticateDigest(httptest.NewRequest("POST", testURI, nil), nil, header, testClientIP)
+}
+
+func TestDigestLockoutMixedCredentials(t *testing.T) {
+	const maxFailures = 3
+	s := newDigestTestServer(t, maxFailures)
+
+	// A password with every hash and a token created before SHA-256 was supported
+	var password models.DigestHashes
+	password.SetDigest("alice", testRealm, "correct horse")
+	alice := addDigestUser(t, s, "alice", password)
+	token := models.IPPToken{
+		UserID:       alice.ID,
+		Name:         "Old laptop",
+		DigestRealm:  testRealm,
+		IsActive:     true,
+		DigestHashes: models.DigestHashes{DigestHA1: utils.ComputeDigestHA1With(utils.DigestMD5, "alice", testRealm, "old-token")},
+	}
+	if err := s.db.Create(&token).Error; err != nil {
+		t.Fatal(err)
+	}
+
+	if user, _, _ := tryDigest(s, "alice", utils.DigestSHA256, "correct horse"); user == nil {
+		t.Fatal("SHA-256 Digest auth with the right password failed")
+	}
+	if _, matched, _ := tryDigest(s, "alice", utils.DigestMD5, "old-token"); matched == nil {
+		t.Fatal("MD5 Digest auth with the MD5-only token failed")
+	}
+
+	// The token can't be checked with SHA-256, but the password can
+	for i := 0; i < maxFailures; i++ {
+		if user, _, _ := tryDigest(s, "alice", utils.DigestSHA256, fmt.Sprintf("guess %d", i)); user != nil {
+			t.Fatal("SHA-256 Digest auth with a wrong password succeeded")
+		}
+	}
+	if _, locked := s.limiter.Check("alice", testClientIP); !locked {
+		t.Fatalf("alice isn't locked out after %d wrong SHA-256 responses", maxFailures)
+	}
+}
+
+func TestDigestLockoutWithoutHash(t *testing.T) {
+	const maxFailures = 3
+	s := newDigestTestServer(t, maxFailures)
+
+	// Only an MD5 hash, so a SHA-256 response can't be told apart from a wrong password
+	addDigestUser(t, s, "bob", models.DigestHashes{DigestHA1: utils.ComputeDigestHA1With(utils.DigestMD5, "bob", testRealm, "bob's password")})
+
+	for i := 0; i < maxFailures*2; i++ {
+		if user, _, _ := tryDigest(s, "bob", utils.DigestSHA256, "bob's password"); user != nil {
+			t.Fatal("SHA-256 Digest auth succeeded without a SHA-256 hash")
+		}
+	}
+	if _, locked := s.limiter.Check("bob", testClientIP); locked {
+		t.Fatal("bob is locked out although no hash could be compared")
+	}
+	if user, _, _ := tryDigest(s, "bob", utils.DigestMD5, "bob's password"); user == nil {
+		t.Fatal("MD5 Digest auth with the right password failed")
+	}
+
+	for i := 0; i < maxFailures; i++ {
+		tryDigest(s, "bob", utils.DigestMD5, "wrong")
+	}
+	if _, locked := s.limiter.Check("bob", testClientIP); !locked {
+		t.Fatalf("bob isn't locked out after %d wrong MD5 responses", maxFailures)
+	}
+}
+
+func TestNonceCacheUse(t *testing.T) {
+	cache := &nonceCache{nonces: make(map[string]*nonceEntry)}
+	nonce := cache.generate()
+	expired := cache.generate()
+	cache.nonces[expired].expiry = time.Now().Add(-time.Second)
+
+	// Steps run in order against the same cache
+	steps := []struct {
+		name  string
+		nonce string
+		nc    string
+		want  bool
+	}{
+		{"first count", nonce, "00000001", true},
+		{"replayed count", nonce, "00000001", false},
+		{"skipped counts", nonce, "00000005", true},
+		{"lower count", nonce, "00000003", false},
+		{"replayed after skip", nonce, "00000005", false},
+		{"next count", nonce, "00000006", true},
+		{"uppercase hex", nonce, "0000000A", true},
+		{"too short", nonce, "b", false},
+		{"too long", nonce, "00000000c", false},
+		{"not hex", nonce, "0000000g", false},
+		{"zero", cache.generate(), "00000000", false},
+		{"unknown nonce", "0123456789abcdef0123456789abcdef", "00000001", false},
+		{"expired nonce", expired, "00000001", false},
+	}
+	for _, step := range steps {
+		if got := cache.use(step.nonce, step.nc); got != step.want {
+			t.Errorf("%s: use(%s) = %t, want %t", step.name, step.nc, got, step.want)
+		}
+	}
+
+	cache.invalidate(nonce)
+	if cache.isValid(nonce) || cache.use(nonce, "00000010") {
+		t.Error("invalidated nonce is still accepted")
+	}
+}
+
+func TestParseDigestAuth(t *testing.T) {
+	tests := []struct {
+		name   string
+		header string
+		want   map[string]string
+	}{
+		{
+			"quoted and unquoted",
+			`username="alice", realm="Zikzi", nonce="abc", uri="/ipp/print", qop=auth, nc=00000001, cnonce="xyz", response="0123", algorithm=SHA-256`,
+			map[string]string{
+				"username": "alice", "realm": "Zikzi", "nonce": "abc", "uri": "/ipp/print", "qop": "auth",
+				"nc": "00000001", "cnonce": "xyz", "response": "0123", "algorithm": "SHA-256",
+			},
+		},
+		{
+			"comma inside quotes",
+			`username="Doe, Jane", realm="Zikzi"`,
+			map[string]string{"username": "Doe, Jane", "realm": "Zikzi"},
+		},
+		{
+			"escaped quote and backslash",
+			`username="say \"hi\"", realm="back\\slash", uri="/a\b"`,
+			map[string]string{"username": `say "hi"`, "realm": `back\slash`, "uri": "/ab"},
+		},
+		{
+			"keys are case insensitive",
+			`UserName="alice", QOP=auth-int`,
+			map[string]string{"username": "alice", "qop": "auth-int"},
+		},
+		{
+			"extra whitespace",
+			"  username = \"alice\" ,\tnc = 00000002 , qop=auth  ",
+			map[string]string{"username": "alice", "nc": "00000002", "qop": "auth"},
+		},
+		{
+			"empty values",
+			`username="", cnonce=, realm="Zikzi"`,
+			map[string]string{"username": "", "cnonce": "", "realm": "Zikzi"},
+		},
+		{
+			"unterminated quote",
+			`username="alice, realm="Zikzi`,
+			map[string]string{"username": "alice, realm="},
+		},
+		{"empty", "", map[string]string{}},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			if got := parseDigestAuth(tt.header); !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("parseDigestAuth(%q) = %v, want %v", tt.header, got, tt.want)
+			}
+		})
+	}
+}
+
+func TestComputeDigestResponse(t *testing.T) {
+	tests := []struct {
+		name                                      string
+		algorithm, username, realm, password      string
+		nonce, nc, cnonce, qop, method, uri, want string
+	}{
+		{
+			// RFC 7616 section 3.9.1
+			"RFC 7616 MD5", utils.DigestMD5, "Mufasa", "http-auth@example.org", "Circle of Life",
+			"7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", "00000001", "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", "auth",
+			"GET", "/dir/index.html", "8ca523f5e9506fed4657c9700eebdbec",
+		},
+		{
+			// RFC 7616 section 3.9.1
+			"RFC 7616 SHA-256", utils.DigestSHA256, "Mufasa", "http-auth@example.org", "Circle of Life",
+			"7ypf/xlj9XXwfDPEoM4URrv/xwf94BcCAzFZH4GiTo0v", "00000001", "f2/wE4q74E6zIJEtWaHKaf5wv/H5QzzpXusqGemxURZJ", "auth",
+			"GET", "/dir/index.html", "753927fa0e85d155564e2e272a28d1802ca10daf4496794697cf8db5856cb6c1",
+		},
+		{
+			// RFC 7616 section 3.9.2. The username is sent hashed but A1 uses it
+			// as is. The response printed in the RFC is wrong and was corrected
+			// in its errata, this one also matches Python's hashlib.
+			"RFC 7616 SHA-512-256", utils.DigestSHA512256, "Jäsøn Doe", "api@example.org", "Secret, or not?",
+			"5TsQWLVdgBdmrQ0XsxbDODV+57QdFR34I9HAbC/RVvkK", "00000001", "NTg6RKcb9boFIAS3KrFK9BGeh+iDa/sm6jUMp2wds69v", "auth",
+			"GET", "/doe.json", "3798d4131c277846293534c3edc11bd8a5e4cdcbff78b05db9d95eeb1cec68a5",
+		},
+		{
+			// RFC 2617 section 3.5
+			"RFC 2617 MD5", utils.DigestMD5, "Mufasa", "testrealm@host.com", "Circle Of Life",
+			"dcd98b7102dd2f0e8b11d0f600bfb0c093", "00000001", "0a4f113b", "auth",
+			"GET", "/dir/index.html", "6629fae49393a05397450978507c4ef1",
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ha1 := utils.ComputeDigestHA1With(tt.algorithm, tt.username, tt.realm, tt.password)
+			got := computeDigestResponse(tt.algorithm, ha1, tt.nonce, tt.nc, tt.cnonce, tt.qop, tt.method, tt.uri, nil)
+			if got != tt.want {
+				t.Errorf("response = %s, want %s", got, tt.want)
+			}
+		})
+	}
+
+	t.Run("auth-int covers the body", func(t *testing.T) {
+		ha1 := utils.ComputeDigestHA1With(utils.DigestSHA256, "alice", testRealm, "secret")
+		response := func(qop string, body []byte) string {
+			return computeDigestResponse(utils.DigestSHA256, ha1, "nonce", "00000001", "cnonce", qop, "POST", testURI, body)
+		}
+		if response("auth-int", []byte("job A")) == response("auth-int", []byte("job B")) {
+			t.Error("auth-int response doesn't depend on the body")
+		}
+		if response("auth", []byte("job A")) != response("auth", []byte("job B")) {
+			t.Error("auth response depends on the body")
+		}
+		if response("", nil) == response("auth", nil) {
+			t.Error("response without qop equals the one with qop=auth")
+		}
+	})
+}
+
+func TestDigestAuthInt(t *testing.T) {
+	s := newDigestTestServer(t, 5)
+	var hashes models.DigestHashes
+	hashes.SetDigest("alice", testRealm, "secret")
+	addDigestUser(t, s, "alice", hashes)
+
+	body := []byte("\x02\x00\x00\x02\x00\x00\x00\x01\x03%!PS")
+	tests := []struct {
+		name string
+		sent []byte // Body the request arrives with
+		want bool
+	}{
+		{"same body", body, true},
+		{"tampered body", append(body[:len(body):len(body)], '\n'), false},
+		{"missing body", nil, false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			for _, algorithm := range utils.DigestAlgorithms {
+				req := digestRequest{username: "alice", algorithm: algorithm, nonce: s.nonceCache.generate(), nc: "00000001", cnonce: "c", qop: "auth-int"}
+				header := req.authorize("secret", body)
+				user, _, _ := s.authenticateDigest(httptest.NewRequest("POST", testURI, nil), tt.sent, header, testClientIP)
+				if got := user != nil; got != tt.want {
+					t.Errorf("%s: authenticated = %t, want %t", algorithm, got, tt.want)
+				}
+			}
+		})
+	}
+}
+
+func TestDigestStaleNonce(t *testing.T) {
+	s := newDigestTestServer(t, 3)
+	var hashes models.DigestHashes
+	hashes.SetDigest("alice", testRealm, "secret")
+	addDigestUser(t, s, "alice", hashes)
+
+	expired := s.nonceCache.generate()
+	s.nonceCache.nonces[expired].expiry = time.Now().Add(-time.Second)
+
+	tests := []struct {
+		name      string
+		nonce     string
+		secret    string
+		wantStale bool
+	}{
+		{"expired nonce, right password", expired, "secret", true},
+		{"unknown nonce, right password", "0123456789abcdef0123456789abcdef", "secret", true},
+		{"expired nonce, wrong password", expired, "guess", false},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := digestRequest{username: "alice", algorithm: utils.DigestSHA256, nonce: tt.nonce, nc: "00000001", cnonce: "c", qop: "auth"}
+			user, _, stale := s.authenticateDigest(httptest.NewRequest("POST", testURI, nil), nil, req.authorize(tt.secret, nil), testClientIP)
+			if user != nil {
+				t.Fatal("authenticated with a stale nonce")
+			}
+			if stale != tt.wantStale {
+				t.Errorf("stale = %t, want %t", stale, tt.wantStale)
+			}
+		})
+	}
+
+	// Wrong passwords with stale nonces still count towards the lockout
+	for i := 0; i < 2; i++ {
+		req := digestRequest{username: "alice", algorithm: utils.DigestSHA256, nonce: expired, nc: "00000001", cnonce: "c", qop: "auth"}
+		s.authenticateDigest(httptest.NewRequest("POST", testURI, nil), nil, req.authorize("guess", nil), testClientIP)
+	}
+	if _, locked := s.limiter.Check("alice", testClientIP); !locked {
+		t.Error("wrong passwords with a stale nonce aren't counted")
+	}
+
+	for _, stale := range []bool{false, true} {
+		w := httptest.NewRecorder()
+		s.sendAuthChallenge(w, stale)
+		challenges := w.Header().Values("WWW-Authenticate")
+		if len(challenges) != 1+len(utils.DigestAlgorithms) {
+			t.Fatalf("got %d challenges, want Basic and one per Digest algorithm", len(challenges))
+		}
+		for _, challenge := range challenges[1:] {
+			if got := strings.Contains(challenge, "stale=true"); got != stale {
+				t.Errorf("sendAuthChallenge(stale=%t) challenge %q", stale, challenge)
+			}
+		}
+	}
+}
@@ -1,25 +1,33 @@
 package printer
 
 import (
+	"bytes"
 	"context"
-	"crypto/md5"
 	"crypto/rand"
+	"crypto/sha256"
+	"crypto/subtle"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
 	"fmt"
 	"io"
 	"net"
 	"net/http"
-	"os"
-	"path/filepath"
+	"net/netip"
+	"slices"
+	"strconv"
 	"strings"
 	"sync"
 	"time"
 
 	"github.com/OpenPrinting/goipp"
+	"github.com/alex4386/zikzi/internal/auth"
 	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/events"
 	"github.com/alex4386/zikzi/internal/logger"
 	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/quota"
+	"github.com/alex4386/zikzi/internal/storage"
 	"github.com/alex4386/zikzi/internal/utils"
 	"gorm.io/gorm"
 )
@@ -34,15 +42,20 @@ const (
 	OpCancelJob        goipp.Op = 0x0008
 )
 
-// Digest auth nonce cache (nonce -> expiry time)
+// Digest auth nonce cache (nonce -> expiry time and highest nonce count seen)
 type nonceCache struct {
 	mu     sync.RWMutex
-	nonces map[string]time.Time
+	nonces map[string]*nonceEntry
+}
+
+type nonceEntry struct {
+	expiry time.Time
+	count  uint64 // Highest nc accepted so far
 }
 
 func newNonceCache() *nonceCache {
 	nc := &nonceCache{
-		nonces: make(map[string]time.Time),
+		nonces: make(map[string]*nonceEntry),
 	}
 	// Start cleanup goroutine
 	go nc.cleanup()
@@ -55,7 +68,7 @@ func (nc *nonceCache) generate() string {
 	nonce := hex.EncodeToString(b)
 
 	nc.mu.Lock()
-	nc.nonces[nonce] = time.Now().Add(5 * time.Minute)
+	nc.nonces[nonce] = &nonceEntry{expiry: time.Now().Add(5 * time.Minute)}
 	nc.mu.Unlock()
 
 	return nonce
@@ -63,13 +76,35 @@ func (nc *nonceCache) generate() string {
 
 func (nc *nonceCache) isValid(nonce string) bool {
 	nc.mu.RLock()
-	expiry, exists := nc.nonces[nonce]
+	entry, exists := nc.nonces[nonce]
 	nc.mu.RUnlock()
 
 	if !exists {
 		return false
 	}
-	return time.Now().Before(expiry)
+	return time.Now().Before(entry.expiry)
+}
+
+// use records the nonce count (nc) of a verified response. Each request must
+// use a higher count than the ones before it, so captured responses can't be
+// replayed.
+func (nc *nonceCache) use(nonce, count string) bool {
+	if len(count) != 8 {
+		return false
+	}
+	n, err := strconv.ParseUint(count, 16, 32)
+	if err != nil {
+		return false
+	}
+
+	nc.mu.Lock()
+	defer nc.mu.Unlock()
+	entry, exists := nc.nonces[nonce]
+	if !exists || !time.Now().Before(entry.expiry) || n <= entry.count {
+		return false
+	}
+	entry.count = n
+	return true
 }
 
 func (nc *nonceCache) invalidate(nonce string) {
@@ -83,8 +118,8 @@ func (nc *nonceCache) cleanup() {
 	for range ticker.C {
 		nc.mu.Lock()
 		now := time.Now()
-		for nonce, expiry := range nc.nonces {
-			if now.After(expiry) {
+		for nonce, entry := range nc.nonces {
+			if now.After(entry.expiry) {
 				delete(nc.nonces, nonce)
 			}
 		}
@@ -92,59 +127,132 @@ func (nc *nonceCache) cleanup() {
 	}
 }
 
+// tokenCache remembers recently verified Basic auth tokens, so the bcrypt hash
+// isn't checked again for every request of a print job
+type tokenCache struct {
+	mu      sync.Mutex
+	entries map[string]tokenCacheEntry
+}
+
+type tokenCacheEntry struct {
+	tokenID   string
+	tokenHash string
+	expiry    time.Time
+}
+
+func newTokenCache() *tokenCache {
+	tc := &tokenCache{
+		entries: make(map[string]tokenCacheEntry),
+	}
+	go tc.cleanup()
+	return tc
+}
+
+// key derives the cache key from the user and the provided credential
+func (tc *tokenCache) key(userID, credential string) string {
+	h := sha256.Sum256([]byte(userID + ":" + credential))
+	return hex.EncodeToString(h[:])
+}
+
+// matches reports whether the credential was recently verified against the token
+func (tc *tokenCache) matches(key string, token *models.IPPToken) bool {
+	tc.mu.Lock()
+	entry, exists := tc.entries[key]
+	tc.mu.Unlock()
+
+	return exists && time.Now().Before(entry.expiry) &&
+		entry.tokenID == token.ID && entry.tokenHash == token.TokenHash
+}
+
+func (tc *tokenCache) add(key string, token *models.IPPToken) {
+	if token.TokenHash == "" {
+		return
+	}
+	tc.mu.Lock()
+	tc.entries[key] = tokenCacheEntry{tokenID: token.ID, tokenHash: token.TokenHash, expiry: time.Now().Add(5 * time.Minute)}
+	tc.mu.Unlock()
+}
+
+func (tc *tokenCache) cleanup() {
+	ticker := time.NewTicker(1 * time.Minute)
+	for range ticker.C {
+		tc.mu.Lock()
+		now := time.Now()
+		for key, entry := range tc.entries {
+			if now.After(entry.expiry) {
+				delete(tc.entries, key)
+			}
+		}
+		tc.mu.Unlock()
+	}
+}
+
 // IPPServer handles IPP protocol requests
 type IPPServer struct {
-	config         config.IPPConfig
-	printerCfg     config.PrinterConfig
-	storage        config.StorageConfig
-	db             *gorm.DB
-	ghostscript    *GhostScript
-	httpServer     *http.Server
-	printerURI     string
-	trustedProxies []*net.IPNet
-	nonceCache     *nonceCache
+	config           config.IPPConfig
+	printerCfg       config.PrinterConfig
+	store            *storage.JobStore
+	db               *gorm.DB
+	processor        *Processor
+	quotas           *quota.Checker
+	events           *events.Bus
+	httpServer       *http.Server
+	printerURI       string
+	trustedProxies   []netip.Prefix
+	nonceCache       *nonceCache
+	tokenCache       *tokenCache
+	limiter          *auth.Limiter // nil when lockouts are disabled
+	digestAlgorithms []string      // Digest algorithms offered, most preferred first
 }
 
 // NewIPPServer creates a new IPP server instance
-func NewIPPServer(cfg config.IPPConfig, printerCfg config.PrinterConfig, storage config.StorageConfig, db *gorm.DB) *IPPServer {
+func NewIPPServer(cfg config.IPPConfig, printerCfg config.PrinterConfig, store *storage.JobStore, processor *Processor, quotas *quota.Checker, bus *events.Bus, db *gorm.DB, limiter *auth.Limiter) *IPPServer {
 	s := &IPPServer{
-		config:      cfg,
-		printerCfg:  printerCfg,
-		storage:     storage,
-		db:          db,
-		ghostscript: NewGhostScript(storage.GhostscriptBin),
-		nonceCache:  newNonceCache(),
+		config:     cfg,
+		printerCfg: printerCfg,
+		store:      store,
+		db:         db,
+		processor:  processor,
+		quotas:     quotas,
+		events:     bus,
+		nonceCache: newNonceCache(),
+		tokenCache: newTokenCache(),
+		limiter:    limiter,
 	}
 
 	// Parse trusted proxies
 	s.trustedProxies = parseTrustedProxies(cfg.TrustedProxies)
+	s.digestAlgorithms = parseDigestAlgorithms(cfg.Auth.DigestAlgorithms)
 
 	return s
 }
 
-// parseTrustedProxies parses a list of IP addresses or CIDR ranges
-func parseTrustedProxies(proxies []string) []*net.IPNet {
-	var networks []*net.IPNet
+// parseDigestAlgorithms normalizes the configured Digest algorithms, dropping unsupported ones
+func parseDigestAlgorithms(names []string) []string {
+	var algorithms []string
+	for _, name := range names {
+		algorithm := strings.ToUpper(strings.TrimSpace(name))
+		if !slices.Contains(utils.DigestAlgorithms, algorithm) {
+			logger.Warn("IPP: Ignoring unsupported Digest algorithm %q", name)
+			continue
+		}
+		if !slices.Contains(algorithms, algorithm) {
+			algorithms = append(algorithms, algorithm)
+		}
+	}
+	return algorithms
+}
+
+// parseTrustedProxies parses a list of IP addresses or CIDR ranges, skipping invalid ones
+func parseTrustedProxies(proxies []string) []netip.Prefix {
+	var networks []netip.Prefix
 	for _, proxy := range proxies {
-		// Try parsing as CIDR
-		if strings.Contains(proxy, "/") {
-			_, network, err := net.ParseCIDR(proxy)
-			if err == nil {
-				networks = append(networks, network)
-			}
-		} else {
-			// Single IP - convert to /32 or /128
-			ip := net.ParseIP(proxy)
-			if ip != nil {
-				var mask net.IPMask
-				if ip.To4() != nil {
-					mask = net.CIDRMask(32, 32)
-				} else {
-					mask = net.CIDRMask(128, 128)
-				}
-				networks = append(networks, &net.IPNet{IP: ip, Mask: mask})
-			}
+		network, err := models.ParseIPPrefix(proxy)
+		if err != nil {
+			logger.Warn("IPP: Ignoring trusted proxy: %v", err)
+			continue
 		}
+		networks = append(networks, network)
 	}
 	return networks
 }
@@ -156,13 +264,13 @@ func (s *IPPServer) isTrustedProxy(ipStr string) bool {
 		return s.config.TrustProxy
 	}
 
-	ip := net.ParseIP(ipStr)
-	if ip == nil {
+	ip, err := models.ParseIPPrefix(ipStr)
+	if err != nil || !ip.IsSingleIP() {
 		return false
 	}
 
 	for _, network := range s.trustedProxies {
-		if network.Contains(ip) {
+		if network.Contains(ip.Addr()) {
 			return true
 		}
 	}
@@ -240,19 +348,37 @@ func (s *IPPServer) handleIPP(w http.ResponseWriter, r *http.Request) {
 	clientIP := s.getClientIP(r)
 	logger.Debug("IPP: %s from %s (op: 0x%04x)", goipp.Op(msg.Code).String(), clientIP, msg.Code)
 
+	// Pairing pages register the client's IP, whether or not it could authenticate
+	if goipp.Op(msg.Code) == OpPrintJob {
+		if claim := claimIP(s.db, s.extractDocumentData(body), clientIP); claim.found {
+			s.sendResponse(w, s.makeClaimResponse(&msg, claim))
+			return
+		}
+	}
+
 	// Check if operation requires authentication
 	requiresAuth := s.operationRequiresAuth(goipp.Op(msg.Code))
 
 	var auth authResult
 	if requiresAuth {
 		var needsChallenge bool
-		auth, needsChallenge = s.authenticateRequest(r, clientIP)
+		auth, needsChallenge = s.authenticateRequest(r, body, clientIP, goipp.Op(msg.Code))
 
+		if auth.forbidden {
+			s.sendResponse(w, s.makeResponse(goipp.StatusErrorForbidden, msg.RequestID))
+			return
+		}
+		if auth.lockedOut {
+			resp := s.makeResponse(goipp.StatusErrorNotAuthorized, msg.RequestID)
+			resp.Operation.Add(goipp.MakeAttribute("status-message", goipp.TagText, goipp.String("too many failed login attempts")))
+			s.sendResponse(w, resp)
+			return
+		}
 		if !auth.authenticated {
 			if needsChallenge {
 				// Send auth challenge - client should retry with credentials
 				logger.Debug("IPP: Sending auth challenge for %s from %s", goipp.Op(msg.Code).String(), clientIP)
-				s.sendAuthChallenge(w)
+				s.sendAuthChallenge(w, auth.staleNonce)
 				return
 			}
 			// No auth methods available/configured, and IP auth failed
@@ -269,9 +395,10 @@ func (s *IPPServer) handleIPP(w http.ResponseWriter, r *http.Request) {
 
 	// Route to appropriate handler
 	var resp *goipp.Message
+	var jobID string
 	switch goipp.Op(msg.Code) {
 	case OpPrintJob:
-		resp = s.handlePrintJob(r, &msg, body, clientIP, auth)
+		resp, jobID = s.handlePrintJob(r, &msg, body, clientIP, auth)
 	case OpValidateJob:
 		resp = s.handleValidateJob(&msg)
 	case OpGetPrinterAttrs:
@@ -287,10 +414,33 @@ func (s *IPPServer) handleIPP(w http.ResponseWriter, r *http.Request) {
 		resp = s.makeResponse(goipp.StatusErrorOperationNotSupported, msg.RequestID)
 	}
 
+	if auth.tokenID != "" {
+		s.recordTokenUsage(auth, goipp.Op(msg.Code).String(), clientIP, jobID)
+	}
+
 	// Send response
 	s.sendResponse(w, resp)
 }
 
+// recordTokenUsage updates the last use of an IPP token and adds it to the token's history
+func (s *IPPServer) recordTokenUsage(auth authResult, operation, clientIP, jobID string) {
+	s.db.Model(&models.IPPToken{}).Where("id = ?", auth.tokenID).Updates(map[string]interface{}{
+		"last_used_at": time.Now(),
+		"last_used_ip": clientIP,
+	})
+
+	usage := models.IPPTokenUsage{
+		TokenID:   auth.tokenID,
+		Method:    auth.method,
+		Operation: operation,
+		IP:        clientIP,
+		JobID:     jobID,
+	}
+	if err := s.db.Create(&usage).Error; err != nil {
+		logger.Error("IPP: Failed to record usage of token %s: %v", auth.tokenID, err)
+	}
+}
+
 // operationRequiresAuth returns true if the IPP operation requires authentication
 func (s *IPPServer) operationRequiresAuth(op goipp.Op) bool {
 	switch op {
@@ -322,19 +472,20 @@ func (s *IPPServer) getAdvertisedAuthMethods() []string {
 	return methods
 }
 
-// handlePrintJob processes Print-Job requests
-func (s *IPPServer) handlePrintJob(r *http.Request, msg *goipp.Message, body []byte, clientIP string, auth authResult) *goipp.Message {
+// handlePrintJob processes Print-Job requests, returning the ID of the created job
+func (s *IPPServer) handlePrintJob(r *http.Request, msg *goipp.Message, body []byte, clientIP string, auth authResult) (*goipp.Message, string) {
 	// Extract document data - it comes after the IPP attributes
 	docData := s.extractDocumentData(body)
 	if len(docData) == 0 {
 		logger.Debug("IPP: No document data in Print-Job request")
-		return s.makeResponse(goipp.StatusErrorBadRequest, msg.RequestID)
+		return s.makeResponse(goipp.StatusErrorBadRequest, msg.RequestID), ""
 	}
 
 	// Create print job record
 	job := &models.PrintJob{
 		SourceIP: clientIP,
 		Status:   models.JobStatusReceived,
+		Queue:    models.JobQueueIPP,
 	}
 
 	// Extract job attributes from operation group
@@ -360,17 +511,24 @@ func (s *IPPServer) handlePrintJob(r *http.Request, msg *goipp.Message, body []b
 		job.UserID = auth.userID
 	}
 
-	if err := s.db.Create(job).Error; err != nil {
-		logger.Error("IPP: Failed to create print job: %v", err)
-		return s.makeResponse(goipp.StatusErrorInternal, msg.RequestID)
+	if err := s.quotas.Check(r.Context(), job.UserID, int64(len(docData))); err != nil {
+		var exceeded *quota.ExceededError
+		if !errors.As(err, &exceeded) {
+			logger.Error("IPP: Failed to check quota: %v", err)
+			return s.makeResponse(goipp.StatusErrorInternal, msg.RequestID), ""
+		}
+		logger.Warn("IPP: Rejected print job from %s: %v", clientIP, err)
+		resp := s.makeResponse(goipp.StatusErrorNotAuthorized, msg.RequestID)
+		resp.Operation.Add(goipp.MakeAttribute("status-message", goipp.TagText, goipp.String(exceeded.Message)))
+		resp.Job.Add(goipp.MakeAttribute("job-state-message", goipp.TagText, goipp.String(exceeded.Message)))
+		return resp, ""
 	}
 
-	// Save the document data
-	dataDir := filepath.Join(s.storage.Path, "jobs")
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		logger.Error("IPP: Failed to create data directory: %v", err)
-		return s.makeResponse(goipp.StatusErrorInternal, msg.RequestID)
+	if err := s.db.Create(job).Error; err != nil {
+		logger.Error("IPP: Failed to create print job: %v", err)
+		return s.makeResponse(goipp.StatusErrorInternal, msg.RequestID), ""
 	}
+	s.events.Publish(events.JobCreated, job)
 
 	// Determine file extension based on document format
 	ext := ".ps"
@@ -385,22 +543,24 @@ func (s *IPPServer) handlePrintJob(r *http.Request, msg *goipp.Message, body []b
 		}
 	}
 
-	filename := fmt.Sprintf("%s_%s%s", job.ID, time.Now().Format("20060102_150405"), ext)
-	filePath := filepath.Join(dataDir, filename)
-
-	if err := os.WriteFile(filePath, docData, 0644); err != nil {
+	// Save the document data
+	key := storage.JobKey(fmt.Sprintf("%s_%s%s", job.ID, time.Now().Format("20060102_150405"), ext))
+	if err := s.store.Put(r.Context(), job, key, bytes.NewReader(docData), int64(len(docData))); err != nil {
 		logger.Error("IPP: Failed to write document: %v", err)
-		return s.makeResponse(goipp.StatusErrorInternal, msg.RequestID)
+		return s.makeResponse(goipp.StatusErrorInternal, msg.RequestID), ""
 	}
 
-	job.OriginalFile = filePath
+	docHash := sha256.Sum256(docData)
+	job.OriginalFile = key
+	job.OriginalSHA256 = hex.EncodeToString(docHash[:])
 	job.FileSize = int64(len(docData))
 	job.Status = models.JobStatusProcessing
 	job.AppName = "IPP Client"
 	s.db.Save(job)
+	s.events.Publish(events.JobProcessing, job)
 
 	// Queue for processing
-	go s.processJob(job)
+	go s.processor.Process(job)
 
 	// Build success response
 	resp := s.makeResponse(goipp.StatusOk, msg.RequestID)
@@ -413,6 +573,26 @@ func (s *IPPServer) handlePrintJob(r *http.Request, msg *goipp.Message, body []b
 	resp.Job.Add(goipp.MakeAttribute("job-state-reasons", goipp.TagKeyword, goipp.String("job-printing")))
 
 	logger.Info("IPP: Print job %s created successfully", job.ID)
+	return resp, job.ID
+}
+
+// makeClaimResponse answers a Print-Job that carried a claim code. The job
+// itself is discarded, so it is reported as completed right away.
+func (s *IPPServer) makeClaimResponse(msg *goipp.Message, claim claimResult) *goipp.Message {
+	if claim.err != nil {
+		resp := s.makeResponse(goipp.StatusErrorNotAuthorized, msg.RequestID)
+		resp.Operation.Add(goipp.MakeAttribute("status-message", goipp.TagText, goipp.String(claim.err.Error())))
+		return resp
+	}
+
+	message := fmt.Sprintf("%s registered", claim.registration.IPAddress)
+	resp := s.makeResponse(goipp.StatusOk, msg.RequestID)
+	resp.Operation.Add(goipp.MakeAttribute("status-message", goipp.TagText, goipp.String(message)))
+	resp.Job.Add(goipp.MakeAttribute("job-id", goipp.TagInteger, goipp.Integer(1)))
+	resp.Job.Add(goipp.MakeAttribute("job-uri", goipp.TagURI, goipp.String(s.printerURI+"/jobs/claim")))
+	resp.Job.Add(goipp.MakeAttribute("job-state", goipp.TagEnum, goipp.Integer(9))) // completed
+	resp.Job.Add(goipp.MakeAttribute("job-state-reasons", goipp.TagKeyword, goipp.String("job-completed-successfully")))
+	resp.Job.Add(goipp.MakeAttribute("job-state-message", goipp.TagText, goipp.String(message)))
 	return resp
 }
 
@@ -614,30 +794,6 @@ func (s *IPPServer) sendError(w http.ResponseWriter, status goipp.Status, reques
 	s.sendResponse(w, resp)
 }
 
-// processJob handles the PDF conversion workflow
-func (s *IPPServer) processJob(job *models.PrintJob) {
-	outputDir := filepath.Join(s.storage.Path, "jobs")
-
-	result := s.ghostscript.ProcessJob(job.OriginalFile, outputDir, job.ID)
-
-	now := time.Now()
-	job.ProcessedAt = &now
-
-	if result.Error != nil {
-		job.Status = models.JobStatusFailed
-		job.Error = result.Error.Error()
-		logger.Error("IPP: Print job %s failed: %v", job.ID, result.Error)
-	} else {
-		job.Status = models.JobStatusCompleted
-		job.PDFFile = result.PDFPath
-		job.ThumbnailFile = result.ThumbnailPath
-		job.PageCount = result.PageCount
-		logger.Info("IPP: Print job %s completed: %d pages", job.ID, result.PageCount)
-	}
-
-	s.db.Save(job)
-}
-
 // getQueuedJobCount returns the number of pending jobs
 func (s *IPPServer) getQueuedJobCount() int {
 	var count int64
@@ -729,17 +885,20 @@ type authResult struct {
 	authenticated bool
 	userID        string
 	method        string // "ip", "basic", "digest"
+	tokenID       string // IPP token used for basic or digest auth
+	forbidden     bool   // Credentials were valid but the token's scope doesn't allow the request
+	lockedOut     bool   // Too many failed logins for the account or client IP
+	staleNonce    bool   // Digest credentials were valid but the nonce expired
 }
 
 // authenticateRequest attempts to authenticate the request using configured methods
 // Returns: authResult with user info, and whether auth challenge should be sent
-func (s *IPPServer) authenticateRequest(r *http.Request, clientIP string) (authResult, bool) {
+func (s *IPPServer) authenticateRequest(r *http.Request, body []byte, clientIP string, op goipp.Op) (authResult, bool) {
 	result := authResult{}
 
 	// Try IP-based authentication first (if enabled)
 	if s.config.Auth.AllowIP {
-		var ipReg models.IPRegistration
-		if err := s.db.Where("ip_address = ? AND is_active = ?", clientIP, true).First(&ipReg).Error; err == nil {
+		if ipReg, err := models.FindIPRegistration(s.db, clientIP); err == nil {
 			result.authenticated = true
 			result.userID = ipReg.UserID
 			result.method = "ip"
@@ -751,20 +910,27 @@ func (s *IPPServer) authenticateRequest(r *http.Request, clientIP string) (authR
 	if s.config.Auth.AllowLogin {
 		authHeader := r.Header.Get("Authorization")
 		if authHeader != "" {
+			// Locked out logins are refused before any password is hashed
+			if until, locked := s.limiter.Check(credentialUsername(authHeader), clientIP); locked {
+				logger.Debug("IPP: Rejected login from %s, locked out until %s", clientIP, until.Format(time.RFC3339))
+				result.lockedOut = true
+				return result, false
+			}
+
 			if strings.HasPrefix(authHeader, "Basic ") {
-				if user := s.authenticateBasic(authHeader); user != nil {
-					result.authenticated = true
-					result.userID = user.ID
+				if user, token := s.authenticateBasic(authHeader, clientIP); user != nil {
+					s.limiter.Succeed(user.Username)
 					result.method = "basic"
-					return result, false
+					return s.authorizeToken(result, r, clientIP, op, user, token), false
 				}
-			} else if strings.HasPrefix(authHeader, "Digest ") {
-				if user := s.authenticateDigest(r, authHeader); user != nil {
-					result.authenticated = true
-					result.userID = user.ID
+			} else if strings.HasPrefix(authHeader, "Digest ") && len(s.digestAlgorithms) > 0 {
+				user, token, stale := s.authenticateDigest(r, body, authHeader, clientIP)
+				if user != nil {
+					s.limiter.Succeed(user.Username)
 					result.method = "digest"
-					return result, false
+					return s.authorizeToken(result, r, clientIP, op, user, token), false
 				}
+				result.staleNonce = stale
 			}
 		}
 		// Auth header missing or invalid - should send challenge
@@ -775,19 +941,63 @@ func (s *IPPServer) authenticateRequest(r *http.Request, clientIP string) (authR
 	return result, false
 }
 
+// authorizeToken completes a successful login, checking the restrictions of the token used (if any)
+func (s *IPPServer) authorizeToken(result authResult, r *http.Request, clientIP string, op goipp.Op, user *models.User, token *models.IPPToken) authResult {
+	if token != nil {
+		manage := op != OpPrintJob && op != OpValidateJob
+		if err := token.CheckScope(printerQueue(r.URL.Path), clientIP, manage); err != nil {
+			logger.Debug("IPP: Token %s of user %s rejected for %s from %s: %v", token.ID, user.Username, op.String(), clientIP, err)
+			result.forbidden = true
+			return result
+		}
+		result.tokenID = token.ID
+	}
+
+	result.authenticated = true
+	result.userID = user.ID
+	return result
+}
+
+// printerQueue returns the IPP printer name of a request path, e.g. "print" for /ipp/print
+func printerQueue(path string) string {
+	if rest, ok := strings.CutPrefix(path, "/ipp/"); ok {
+		if name, _, _ := strings.Cut(rest, "/"); name != "" {
+			return name
+		}
+	}
+	return "print"
+}
+
+// credentialUsername returns the username of a Basic or Digest Authorization header
+func credentialUsername(authHeader string) string {
+	if encoded, ok := strings.CutPrefix(authHeader, "Basic "); ok {
+		decoded, err := base64.StdEncoding.DecodeString(encoded)
+		if err != nil {
+			return ""
+		}
+		username, _, _ := strings.Cut(string(decoded), ":")
+		return username
+	}
+	if params, ok := strings.CutPrefix(authHeader, "Digest "); ok {
+		return parseDigestAuth(params)["username"]
+	}
+	return ""
+}
+
 // authenticateBasic handles HTTP Basic authentication
-// Supports: username/password OR username/token
-func (s *IPPServer) authenticateBasic(authHeader string) *models.User {
+// Supports: username/password OR username/token, returning the token used.
+// Wrong credentials count towards the lockout of the account and clientIP.
+func (s *IPPServer) authenticateBasic(authHeader, clientIP string) (*models.User, *models.IPPToken) {
 	// Decode base64 credentials
 	encoded := strings.TrimPrefix(authHeader, "Basic ")
 	decoded, err := base64.StdEncoding.DecodeString(encoded)
 	if err != nil {
-		return nil
+		return nil, nil
 	}
 
 	parts := strings.SplitN(string(decoded), ":", 2)
 	if len(parts) != 2 {
-		return nil
+		return nil, nil
 	}
 
 	username := parts[0]
@@ -796,37 +1006,63 @@ func (s *IPPServer) authenticateBasic(authHeader string) *models.User {
 	// Find user by username
 	var user models.User
 	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
-		return nil
+		utils.VerifyDummyPassword(credential)
+		s.limiter.Fail("ipp-basic", username, clientIP, "unknown_user")
+		return nil, nil
 	}
 
-	// Try password authentication first (if user allows it)
-	if user.PasswordHash != "" && user.AllowIPPPassword && utils.VerifyPassword(user.PasswordHash, credential) {
-		return &user
+	// Try password authentication first (if user allows it). Otherwise hash
+	// anyway, so users without IPP passwords can't be told from unknown ones.
+	realm := s.config.Auth.Realm
+	if user.PasswordHash == "" || !user.AllowIPPPassword {
+		utils.VerifyDummyPassword(credential)
+	} else if utils.VerifyPassword(user.PasswordHash, credential) {
+		// Update the Digest hashes once the realm changed or algorithms were added
+		if user.SetDigest(user.Username, realm, credential) {
+			s.db.Model(&user).Select(models.DigestHashColumns).Updates(&user)
+			logger.Info("IPP: Updated Digest hashes of user %s for realm %q", user.Username, realm)
+		}
+		return &user, nil
 	}
 
-	// Try IPP token authentication
+	// Try IPP token authentication, skipping bcrypt for recently verified tokens
 	var tokens []models.IPPToken
 	if err := s.db.Where("user_id = ? AND is_active = ?", user.ID, true).Find(&tokens).Error; err == nil {
+		cacheKey := s.tokenCache.key(user.ID, credential)
 		for _, token := range tokens {
-			if token.IsValid() && models.VerifyIPPToken(token.Token, credential) {
-				// Update last used info
-				now := time.Now()
-				token.LastUsedAt = &now
-				s.db.Save(&token)
-				return &user
+			if !token.IsValid() {
+				continue
 			}
+			if !s.tokenCache.matches(cacheKey, &token) {
+				if !token.Verify(credential) {
+					continue
+				}
+				s.tokenCache.add(cacheKey, &token)
+			}
+
+			// Update the Digest hashes once the realm or username changed or algorithms were added
+			if token.SetDigest(user.Username, realm, credential) || token.DigestRealm != realm {
+				token.DigestRealm = realm
+				s.db.Model(&token).Select(append([]string{"digest_realm"}, models.DigestHashColumns...)).Updates(&token)
+				logger.Info("IPP: Updated Digest hashes of token %s for realm %q", token.ID, realm)
+			}
+			return &user, &token
 		}
 	}
 
-	return nil
+	s.limiter.Fail("ipp-basic", username, clientIP, "bad_credentials")
+	return nil, nil
 }
 
-// authenticateDigest handles HTTP Digest authentication
-func (s *IPPServer) authenticateDigest(r *http.Request, authHeader string) *models.User {
+// authenticateDigest handles HTTP Digest authentication (RFC 7616)
+// Returns the token used, if any, and whether the credentials were valid but
+// the nonce is stale. Wrong credentials count towards the lockout of the
+// account and clientIP.
+func (s *IPPServer) authenticateDigest(r *http.Request, body []byte, authHeader, clientIP string) (*models.User, *models.IPPToken, bool) {
 	// Parse digest auth header
 	params := parseDigestAuth(strings.TrimPrefix(authHeader, "Digest "))
 	if params == nil {
-		return nil
+		return nil, nil, false
 	}
 
 	username := params["username"]
@@ -837,130 +1073,173 @@ func (s *IPPServer) authenticateDigest(r *http.Request, authHeader string) *mode
 	cnonce := params["cnonce"]
 	qop := params["qop"]
 
-	// Validate nonce
-	if !s.nonceCache.isValid(nonce) {
-		logger.Debug("IPP: Digest auth failed - invalid or expired nonce")
-		return nil
+	// Clients that don't name an algorithm use MD5
+	algorithm := strings.ToUpper(params["algorithm"])
+	if algorithm == "" {
+		algorithm = utils.DigestMD5
 	}
+	if !slices.Contains(s.digestAlgorithms, algorithm) {
+		logger.Debug("IPP: Digest auth failed - unsupported algorithm %q", params["algorithm"])
+		return nil, nil, false
+	}
+	if qop != "" && qop != "auth" && qop != "auth-int" {
+		logger.Debug("IPP: Digest auth failed - unsupported qop %q", qop)
+		return nil, nil, false
+	}
+
+	// Responses to an expired nonce are still checked, to tell clients with
+	// valid credentials to retry with a fresh one instead of asking the user.
+	// Wrong ones count as failures all the same, or unknown nonces could be
+	// used to guess passwords without being locked out.
+	stale := !s.nonceCache.isValid(nonce)
 
 	// Find user
 	var user models.User
 	if err := s.db.Where("username = ?", username).First(&user).Error; err != nil {
 		logger.Debug("IPP: Digest auth failed - user not found: %s", username)
-		return nil
+		s.limiter.Fail("ipp-digest", username, clientIP, "unknown_user")
+		return nil, nil, false
 	}
 
+	// Credentials set before an algorithm was supported have no hash for it.
+	// Failures are counted as soon as any hash was compared, and only skipped
+	// when no credential could be checked at all, or clients could guess with
+	// the password and get around the lockout through an old MD5-only token.
 	realm := s.config.Auth.Realm
-
-	// Try password authentication if user has DigestHA1 stored and allows password auth
-	// Note: DigestHA1 must be pre-computed and stored when password is set
-	if user.DigestHA1 != "" && user.AllowIPPPassword {
-		expectedResponse := computeDigestResponse(
-			user.DigestHA1,
-			nonce, nc, cnonce, qop,
-			r.Method, uri,
-		)
-
-		if expectedResponse == clientResponse {
-			logger.Debug("IPP: Digest auth succeeded for user %s via password", username)
-			return &user
+	compared := false
+	matches := func(ha1 string) bool {
+		if ha1 == "" {
+			return false
 		}
+		compared = true
+		expectedResponse := computeDigestResponse(algorithm, ha1, nonce, nc, cnonce, qop, r.Method, uri, body)
+		return subtle.ConstantTimeCompare([]byte(expectedResponse), []byte(clientResponse)) == 1
 	}
 
-	// Try IPP tokens - compute HA1 dynamically using plaintext token
-	var tokens []models.IPPToken
-	if err := s.db.Where("user_id = ? AND is_active = ?", user.ID, true).Find(&tokens).Error; err == nil {
-		for _, token := range tokens {
-			if !token.IsValid() {
-				continue
+	// Try password authentication if user has Digest hashes stored and allows password auth
+	// Note: the hashes must be pre-computed and stored when password is set
+	var matched bool
+	var matchedToken *models.IPPToken
+	if user.AllowIPPPassword && user.DigestHA1 != "" && matches(user.HA1(algorithm)) {
+		matched = true
+	} else {
+		// Try IPP tokens using the hashes stored for the current realm
+		var tokens []models.IPPToken
+		if err := s.db.Where("user_id = ? AND is_active = ? AND digest_realm = ?", user.ID, true, realm).Find(&tokens).Error; err == nil {
+			for i := range tokens {
+				if tokens[i].IsValid() && tokens[i].DigestHA1 != "" && matches(tokens[i].HA1(algorithm)) {
+					matched = true
+					matchedToken = &tokens[i]
+					break
+				}
 			}
+		}
+	}
 
-			// Compute HA1 dynamically: MD5(username:realm:token)
-			ha1 := computeDigestHA1(username, realm, token.Token)
-
-			// Compute expected response
-			expectedResponse := computeDigestResponse(
-				ha1,
-				nonce, nc, cnonce, qop,
-				r.Method, uri,
-			)
-
-			if expectedResponse == clientResponse {
-				// Update last used info
-				now := time.Now()
-				token.LastUsedAt = &now
-				s.db.Save(&token)
-
-				logger.Debug("IPP: Digest auth succeeded for user %s via token", username)
-				return &user
-			}
+	if !matched {
+		if !compared {
+			logger.Debug("IPP: Digest auth failed - no credential of user %s has a %s hash", username, algorithm)
+			return nil, nil, false
 		}
+		logger.Debug("IPP: Digest auth failed - no valid credential matched for user %s (%s)", username, algorithm)
+		s.limiter.Fail("ipp-digest", username, clientIP, "bad_credentials")
+		return nil, nil, false
+	}
+	if stale {
+		logger.Debug("IPP: Digest auth for user %s used a stale nonce", username)
+		return nil, nil, true
 	}
 
-	logger.Debug("IPP: Digest auth failed - no valid credential matched for user %s", username)
-	return nil
+	// Each nonce count may only be used once. Without qop there's no count,
+	// so the nonce itself is used up.
+	if qop == "" {
+		s.nonceCache.invalidate(nonce)
+	} else if !s.nonceCache.use(nonce, nc) {
+		logger.Warn("IPP: Digest auth failed - replayed nonce count %s for user %s from %s", nc, username, clientIP)
+		return nil, nil, false
+	}
+
+	method := "password"
+	if matchedToken != nil {
+		method = "token"
+	}
+	logger.Debug("IPP: Digest auth succeeded for user %s via %s (%s)", username, method, algorithm)
+	return &user, matchedToken, false
 }
 
-// parseDigestAuth parses a Digest authentication header into a map
+// parseDigestAuth parses a Digest authentication header into a map.
+// Quoted values may contain commas and backslash escapes.
 func parseDigestAuth(header string) map[string]string {
 	result := make(map[string]string)
-	// Parse key="value" or key=value pairs
-	parts := strings.Split(header, ",")
-	for _, part := range parts {
-		part = strings.TrimSpace(part)
-		idx := strings.Index(part, "=")
+	for header != "" {
+		// Parse key="value" or key=value pairs
+		header = strings.TrimLeft(header, ", \t")
+		idx := strings.Index(header, "=")
 		if idx == -1 {
-			continue
+			break
 		}
-		key := strings.TrimSpace(part[:idx])
-		value := strings.TrimSpace(part[idx+1:])
-		// Remove quotes if present
-		if len(value) >= 2 && value[0] == '"' && value[len(value)-1] == '"' {
-			value = value[1 : len(value)-1]
+		key := strings.ToLower(strings.TrimSpace(header[:idx]))
+		header = strings.TrimLeft(header[idx+1:], " \t")
+
+		var value strings.Builder
+		if strings.HasPrefix(header, `"`) {
+			i := 1
+			for ; i < len(header) && header[i] != '"'; i++ {
+				if header[i] == '\\' && i+1 < len(header) {
+					i++
+				}
+				value.WriteByte(header[i])
+			}
+			header = header[min(i+1, len(header)):]
+		} else {
+			end := strings.IndexByte(header, ',')
+			if end == -1 {
+				end = len(header)
+			}
+			value.WriteString(strings.TrimSpace(header[:end]))
+			header = header[end:]
 		}
-		result[key] = value
+		result[key] = value.String()
 	}
 	return result
 }
 
-// sendAuthChallenge sends WWW-Authenticate headers for Basic and Digest auth
-func (s *IPPServer) sendAuthChallenge(w http.ResponseWriter) {
+// sendAuthChallenge sends WWW-Authenticate headers for Basic and Digest auth.
+// stale tells Digest clients their credentials were valid but the nonce expired.
+func (s *IPPServer) sendAuthChallenge(w http.ResponseWriter, stale bool) {
 	realm := s.config.Auth.Realm
-	nonce := s.nonceCache.generate()
 
 	// Offer both Basic and Digest authentication
 	// Basic is simpler and works well over HTTPS
 	w.Header().Add("WWW-Authenticate", fmt.Sprintf(`Basic realm="%s"`, realm))
 
-	// Digest provides better security over HTTP (password not sent in clear)
-	w.Header().Add("WWW-Authenticate", fmt.Sprintf(
-		`Digest realm="%s", nonce="%s", qop="auth", algorithm=MD5`,
-		realm, nonce,
-	))
+	// Digest provides better security over HTTP (password not sent in clear),
+	// with one challenge per algorithm in order of preference
+	if len(s.digestAlgorithms) > 0 {
+		nonce := s.nonceCache.generate()
+		for _, algorithm := range s.digestAlgorithms {
+			challenge := fmt.Sprintf(`Digest realm="%s", nonce="%s", qop="auth, auth-int", algorithm=%s`, realm, nonce, algorithm)
+			if stale {
+				challenge += ", stale=true"
+			}
+			w.Header().Add("WWW-Authenticate", challenge)
+		}
+	}
 
 	w.WriteHeader(http.StatusUnauthorized)
 }
 
-// computeDigestHA1 computes the HA1 hash for digest authentication
-func computeDigestHA1(username, realm, password string) string {
-	h := md5.Sum([]byte(username + ":" + realm + ":" + password))
-	return hex.EncodeToString(h[:])
-}
-
-// computeDigestResponse computes the expected digest response
-func computeDigestResponse(ha1, nonce, nc, cnonce, qop, method, uri string) string {
-	ha2 := md5.Sum([]byte(method + ":" + uri))
-	ha2Hex := hex.EncodeToString(ha2[:])
+// computeDigestResponse computes the expected digest response. auth-int
+// includes the hash of the request body.
+func computeDigestResponse(algorithm, ha1, nonce, nc, cnonce, qop, method, uri string, body []byte) string {
+	a2 := method + ":" + uri
+	if qop == "auth-int" {
+		a2 += ":" + utils.DigestHash(algorithm, body)
+	}
+	ha2 := utils.DigestHash(algorithm, []byte(a2))
 
-	var response string
 	if qop == "auth" || qop == "auth-int" {
-		data := ha1 + ":" + nonce + ":" + nc + ":" + cnonce + ":" + qop + ":" + ha2Hex
-		h := md5.Sum([]byte(data))
-		response = hex.EncodeToString(h[:])
-	} else {
-		data := ha1 + ":" + nonce + ":" + ha2Hex
-		h := md5.Sum([]byte(data))
-		response = hex.EncodeToString(h[:])
+		return utils.DigestHash(algorithm, []byte(ha1+":"+nonce+":"+nc+":"+cnonce+":"+qop+":"+ha2))
 	}
-	return response
+	return utils.DigestHash(algorithm, []byte(ha1+":"+nonce+":"+ha2))
 }
@@ -0,0 +1,140 @@
+package printer
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"path/filepath"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/signing"
+	"github.com/alex4386/zikzi/internal/storage"
+	"github.com/alex4386/zikzi/internal/tagging"
+	"github.com/alex4386/zikzi/internal/utils"
+	"gorm.io/gorm"
+)
+
+// Processor converts received print jobs into PDFs and thumbnails
+type Processor struct {
+	store       *storage.JobStore
+	db          *gorm.DB
+	ghostscript *GhostScript
+	signer      *signing.Signer
+	events      *events.Bus
+}
+
+// NewProcessor creates a job processor. signer may be nil when signing is disabled.
+func NewProcessor(cfg config.StorageConfig, store *storage.JobStore, signer *signing.Signer, bus *events.Bus, db *gorm.DB) *Processor {
+	return &Processor{
+		store:       store,
+		db:          db,
+		ghostscript: NewGhostScript(cfg.GhostscriptBin),
+		signer:      signer,
+		events:      bus,
+	}
+}
+
+// Process handles the PDF conversion workflow for a received job
+func (p *Processor) Process(job *models.PrintJob) {
+	if err := tagging.Apply(p.db, job); err != nil {
+		logger.Warn("Failed to apply tag rules to job %s: %v", job.ID, err)
+	}
+
+	err := p.convert(context.Background(), job)
+
+	now := time.Now()
+	job.ProcessedAt = &now
+
+	if err != nil {
+		job.Status = models.JobStatusFailed
+		job.Error = err.Error()
+		logger.Error("Print job %s failed: %v", job.ID, err)
+	} else {
+		job.Status = models.JobStatusCompleted
+		logger.Info("Print job %s completed: %d pages", job.ID, job.PageCount)
+	}
+
+	p.db.Save(job)
+
+	if job.Status == models.JobStatusCompleted {
+		p.events.Publish(events.JobCompleted, job)
+	} else {
+		p.events.Publish(events.JobFailed, job)
+	}
+}
+
+// convert runs GhostScript on a local working copy of the job and stores the results
+func (p *Processor) convert(ctx context.Context, job *models.PrintJob) error {
+	workDir, err := os.MkdirTemp("", "zikzi-"+job.ID+"-")
+	if err != nil {
+		return fmt.Errorf("failed to create working directory: %w", err)
+	}
+	defer os.RemoveAll(workDir)
+
+	inputPath := filepath.Join(workDir, filepath.Base(job.OriginalFile))
+	if err := p.store.Download(ctx, job, job.OriginalFile, inputPath); err != nil {
+		return fmt.Errorf("failed to fetch original file: %w", err)
+	}
+
+	// Uploaded images and text are rendered to PostScript first
+	if needsPostScript(inputPath) {
+		psPath := filepath.Join(workDir, job.ID+"_input.ps")
+		if err := toPostScript(inputPath, psPath, job.DocumentName); err != nil {
+			return fmt.Errorf("failed to convert upload: %w", err)
+		}
+		inputPath = psPath
+	}
+
+	result := p.ghostscript.ProcessJob(inputPath, workDir, job.ID)
+	if result.Error != nil {
+		return result.Error
+	}
+
+	// Sign the generated PDF so it can later be proven unmodified
+	if p.signer != nil {
+		if err := p.signer.SignFile(result.PDFPath); err != nil {
+			return fmt.Errorf("PDF signing failed: %w", err)
+		}
+	}
+
+	// Record checksums and sizes of the final files
+	pdfHash, err := utils.HashFile(result.PDFPath)
+	if err != nil {
+		return err
+	}
+	thumbnailHash, err := utils.HashFile(result.ThumbnailPath)
+	if err != nil {
+		return err
+	}
+	pdfInfo, err := os.Stat(result.PDFPath)
+	if err != nil {
+		return err
+	}
+	thumbnailInfo, err := os.Stat(result.ThumbnailPath)
+	if err != nil {
+		return err
+	}
+
+	pdfKey := storage.JobKey(filepath.Base(result.PDFPath))
+	if err := p.store.PutFile(ctx, job, pdfKey, result.PDFPath); err != nil {
+		return fmt.Errorf("failed to store PDF: %w", err)
+	}
+	thumbnailKey := storage.JobKey(filepath.Base(result.ThumbnailPath))
+	if err := p.store.PutFile(ctx, job, thumbnailKey, result.ThumbnailPath); err != nil {
+		return fmt.Errorf("failed to store thumbnail: %w", err)
+	}
+
+	job.PDFFile = pdfKey
+	job.ThumbnailFile = thumbnailKey
+	job.PDFSHA256 = pdfHash
+	job.ThumbnailSHA256 = thumbnailHash
+	job.PDFSize = pdfInfo.Size()
+	job.ThumbnailSize = thumbnailInfo.Size()
+	job.PageCount = result.PageCount
+	job.Signed = p.signer != nil
+	return nil
+}
@@ -3,33 +3,41 @@ package printer
 import (
 	"bufio"
 	"context"
+	"crypto/sha256"
+	"encoding/hex"
 	"fmt"
 	"io"
 	"net"
 	"os"
-	"path/filepath"
 	"time"
 
 	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/events"
 	"github.com/alex4386/zikzi/internal/logger"
 	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/quota"
+	"github.com/alex4386/zikzi/internal/storage"
 	proxyproto "github.com/pires/go-proxyproto"
 	"gorm.io/gorm"
 )
 
 type Server struct {
-	config      config.PrinterConfig
-	storage     config.StorageConfig
-	db          *gorm.DB
-	ghostscript *GhostScript
+	config    config.PrinterConfig
+	store     *storage.JobStore
+	db        *gorm.DB
+	processor *Processor
+	quotas    *quota.Checker
+	events    *events.Bus
 }
 
-func NewServer(cfg config.PrinterConfig, storage config.StorageConfig, db *gorm.DB) *Server {
+func NewServer(cfg config.PrinterConfig, store *storage.JobStore, processor *Processor, quotas *quota.Checker, bus *events.Bus, db *gorm.DB) *Server {
 	return &Server{
-		config:      cfg,
-		storage:     storage,
-		db:          db,
-		ghostscript: NewGhostScript(storage.GhostscriptBin),
+		config:    cfg,
+		store:     store,
+		db:        db,
+		processor: processor,
+		quotas:    quotas,
+		events:    bus,
 	}
 }
 
@@ -134,84 +142,112 @@ func (s *Server) handleConnection(ctx context.Context, conn net.Conn) {
 	job := &models.PrintJob{
 		SourceIP: remoteAddr.IP.String(),
 		Status:   models.JobStatusReceived,
+		Queue:    models.JobQueueRaw,
 	}
 
 	// Try to find user by registered IP
-	var ipReg models.IPRegistration
-	if err := s.db.Where("ip_address = ? AND is_active = ?", remoteAddr.IP.String(), true).First(&ipReg).Error; err == nil {
+	if ipReg, err := models.FindIPRegistration(s.db, remoteAddr.IP.String()); err == nil {
 		job.UserID = ipReg.UserID
 	} else if !s.config.AllowUnregisteredIPs {
-		// IP not registered and unregistered IPs are not allowed
-		logger.Warn("Rejected print job from unregistered IP: %s", remoteAddr.IP.String())
+		// IP not registered and unregistered IPs are not allowed, unless the job is a pairing page
+		data, _ := io.ReadAll(io.LimitReader(conn, claimScanLimit))
+		if !claimIP(s.db, data, remoteAddr.IP.String()).found {
+			logger.Warn("Rejected print job from unregistered IP: %s", remoteAddr.IP.String())
+		}
 		return
 	}
 	// If AllowUnregisteredIPs is true and no IP registration found, job.UserID remains empty (orphaned)
 
-	if err := s.db.Create(job).Error; err != nil {
-		logger.Error("Failed to create print job: %v", err)
+	// Drop the connection if the user is already over quota
+	if err := s.quotas.Check(ctx, job.UserID, 0); err != nil {
+		logger.Warn("Rejected print job from %s: %v", remoteAddr.IP.String(), err)
 		return
 	}
 
-	// Save the raw PostScript data
-	dataDir := filepath.Join(s.storage.Path, "jobs")
-	if err := os.MkdirAll(dataDir, 0755); err != nil {
-		logger.Error("Failed to create data directory: %v", err)
+	// Stop receiving once the job is larger than the remaining storage quota,
+	// so users over quota can't fill the spool directory
+	remaining, limited, err := s.quotas.RemainingBytes(ctx, job.UserID)
+	if err != nil {
+		logger.Error("Failed to check quota for print job from %s: %v", remoteAddr.IP.String(), err)
 		return
 	}
+	var src io.Reader = bufio.NewReader(conn)
+	if limited {
+		src = io.LimitReader(src, remaining+1)
+	}
 
-	filename := fmt.Sprintf("%s_%s.ps", job.ID, time.Now().Format("20060102_150405"))
-	psFilePath := filepath.Join(dataDir, filename)
-
-	file, err := os.Create(psFilePath)
+	// Spool the raw PostScript data to a temporary file
+	file, err := os.CreateTemp("", "zikzi-*.ps")
 	if err != nil {
 		logger.Error("Failed to create file: %v", err)
 		return
 	}
+	defer os.Remove(file.Name())
+	defer file.Close()
 
-	// Use TeeReader to parse metadata while saving
-	reader := bufio.NewReader(conn)
-	teeReader := io.TeeReader(reader, file)
+	// Use TeeReader to parse metadata while saving and hashing
+	hash := sha256.New()
+	teeReader := io.TeeReader(src, io.MultiWriter(file, hash))
 
 	// Parse PostScript metadata
 	metadata := ParsePostScriptMetadata(teeReader)
-	file.Close()
+	// Make sure the whole job is stored even if the parser stopped early
+	io.Copy(io.Discard, teeReader)
+
+	size, err := file.Seek(0, io.SeekCurrent)
+	if err != nil {
+		logger.Error("Failed to read spooled job: %v", err)
+		return
+	}
+	if limited && size > remaining {
+		logger.Warn("Rejected print job from %s: storage quota exceeded while receiving", remoteAddr.IP.String())
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		logger.Error("Failed to read spooled job: %v", err)
+		return
+	}
+
+	// Pairing pages register the machine's IP instead of being printed
+	head, _ := io.ReadAll(io.LimitReader(file, claimScanLimit))
+	if claimIP(s.db, head, remoteAddr.IP.String()).found {
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		logger.Error("Failed to read spooled job: %v", err)
+		return
+	}
+
+	// The job's own size only becomes known once it has been received
+	if err := s.quotas.Check(ctx, job.UserID, size); err != nil {
+		logger.Warn("Rejected print job from %s: %v", remoteAddr.IP.String(), err)
+		return
+	}
+
+	if err := s.db.Create(job).Error; err != nil {
+		logger.Error("Failed to create print job: %v", err)
+		return
+	}
+	s.events.Publish(events.JobCreated, job)
+
+	key := storage.JobKey(fmt.Sprintf("%s_%s.ps", job.ID, time.Now().Format("20060102_150405")))
+	if err := s.store.Put(ctx, job, key, file, size); err != nil {
+		logger.Error("Failed to store print job %s: %v", job.ID, err)
+		return
+	}
 
 	// Update job with metadata
-	job.OriginalFile = psFilePath
+	job.OriginalFile = key
+	job.OriginalSHA256 = hex.EncodeToString(hash.Sum(nil))
+	job.FileSize = size
 	job.DocumentName = metadata.Title
 	job.Hostname = metadata.For
 	job.AppName = metadata.Creator
 	job.Status = models.JobStatusProcessing
 
-	if stat, err := os.Stat(psFilePath); err == nil {
-		job.FileSize = stat.Size()
-	}
-
 	s.db.Save(job)
+	s.events.Publish(events.JobProcessing, job)
 
 	// Queue for PDF conversion (async)
-	go s.processJob(job)
-}
-
-func (s *Server) processJob(job *models.PrintJob) {
-	outputDir := filepath.Join(s.storage.Path, "jobs")
-
-	result := s.ghostscript.ProcessJob(job.OriginalFile, outputDir, job.ID)
-
-	now := time.Now()
-	job.ProcessedAt = &now
-
-	if result.Error != nil {
-		job.Status = models.JobStatusFailed
-		job.Error = result.Error.Error()
-		logger.Error("Print job %s failed: %v", job.ID, result.Error)
-	} else {
-		job.Status = models.JobStatusCompleted
-		job.PDFFile = result.PDFPath
-		job.ThumbnailFile = result.ThumbnailPath
-		job.PageCount = result.PageCount
-		logger.Info("Print job %s completed: %d pages", job.ID, result.PageCount)
-	}
-
-	s.db.Save(job)
+	go s.processor.Process(job)
 }
@@ -0,0 +1,194 @@
+package quota
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/models"
+	"gorm.io/gorm"
+)
+
+// ExceededError is returned when a submission would exceed a user's quota
+type ExceededError struct {
+	Message string
+}
+
+func (e *ExceededError) Error() string {
+	return e.Message
+}
+
+// Limits are the effective quotas of a user. 0 means unlimited.
+type Limits struct {
+	MaxBytes         int64 `json:"max_bytes" example:"1073741824"`
+	MaxJobsPerDay    int   `json:"max_jobs_per_day" example:"50"`
+	MaxPagesPerMonth int   `json:"max_pages_per_month" example:"500"`
+}
+
+// Usage is a user's current consumption
+type Usage struct {
+	BytesStored    int64 `json:"bytes_stored" example:"52428800"`
+	JobsToday      int64 `json:"jobs_today" example:"3"`
+	PagesThisMonth int64 `json:"pages_this_month" example:"120"`
+}
+
+// storedBytes sums the size of the files jobs still hold in storage
+const storedBytes = "COALESCE(SUM(CASE WHEN original_file <> '' THEN file_size ELSE 0 END + pdf_size + thumbnail_size), 0)"
+
+// Checker computes usage and enforces quotas
+type Checker struct {
+	config config.QuotaConfig
+	db     *gorm.DB
+}
+
+// NewChecker creates a quota checker
+func NewChecker(cfg config.QuotaConfig, db *gorm.DB) *Checker {
+	return &Checker{config: cfg, db: db}
+}
+
+// LimitsFor returns the effective limits of a user.
+// User values of 0 fall back to the configured default, -1 means unlimited.
+func (c *Checker) LimitsFor(user *models.User) Limits {
+	limits := Limits{
+		MaxBytes:         c.config.MaxBytes,
+		MaxJobsPerDay:    c.config.MaxJobsPerDay,
+		MaxPagesPerMonth: c.config.MaxPagesPerMonth,
+	}
+	if user.QuotaBytes != 0 {
+		limits.MaxBytes = user.QuotaBytes
+	}
+	if user.QuotaJobsPerDay != 0 {
+		limits.MaxJobsPerDay = user.QuotaJobsPerDay
+	}
+	if user.QuotaPagesPerMonth != 0 {
+		limits.MaxPagesPerMonth = user.QuotaPagesPerMonth
+	}
+
+	if limits.MaxBytes < 0 {
+		limits.MaxBytes = 0
+	}
+	if limits.MaxJobsPerDay < 0 {
+		limits.MaxJobsPerDay = 0
+	}
+	if limits.MaxPagesPerMonth < 0 {
+		limits.MaxPagesPerMonth = 0
+	}
+	return limits
+}
+
+// UsageFor returns the current usage of a user
+func (c *Checker) UsageFor(ctx context.Context, userID string) (*Usage, error) {
+	db := c.db.WithContext(ctx)
+	now := time.Now()
+	startOfDay := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, now.Location())
+	startOfMonth := time.Date(now.Year(), now.Month(), 1, 0, 0, 0, 0, now.Location())
+
+	// Stored bytes are the original, PDF and thumbnail of every job, including
+	// jobs in the trash since they occupy storage until they are purged.
+	// Originals removed by retention no longer count.
+	var usage Usage
+	if err := db.Unscoped().Model(&models.PrintJob{}).Where("user_id = ?", userID).
+		Select(storedBytes).Scan(&usage.BytesStored).Error; err != nil {
+		return nil, err
+	}
+
+	// Jobs in the trash still count towards jobs and pages already printed
+	if err := db.Unscoped().Model(&models.PrintJob{}).
+		Where("user_id = ? AND created_at >= ?", userID, startOfDay).
+		Count(&usage.JobsToday).Error; err != nil {
+		return nil, err
+	}
+	if err := db.Unscoped().Model(&models.PrintJob{}).
+		Where("user_id = ? AND created_at >= ?", userID, startOfMonth).
+		Select("COALESCE(SUM(page_count), 0)").Scan(&usage.PagesThisMonth).Error; err != nil {
+		return nil, err
+	}
+
+	return &usage, nil
+}
+
+// Check returns an *ExceededError if the user may not submit a job of incoming bytes.
+// Jobs without a user are not subject to quotas.
+func (c *Checker) Check(ctx context.Context, userID string, incoming int64) error {
+	if userID == "" {
+		return nil
+	}
+
+	var user models.User
+	if err := c.db.WithContext(ctx).First(&user, "id = ?", userID).Error; err != nil {
+		return err
+	}
+
+	limits := c.LimitsFor(&user)
+	if limits == (Limits{}) {
+		return nil
+	}
+
+	usage, err := c.UsageFor(ctx, userID)
+	if err != nil {
+		return err
+	}
+
+	if limits.MaxJobsPerDay > 0 && usage.JobsToday >= int64(limits.MaxJobsPerDay) {
+		return &ExceededError{Message: fmt.Sprintf("daily job quota of %d jobs exceeded", limits.MaxJobsPerDay)}
+	}
+	if limits.MaxPagesPerMonth > 0 && usage.PagesThisMonth >= int64(limits.MaxPagesPerMonth) {
+		return &ExceededError{Message: fmt.Sprintf("monthly page quota of %d pages exceeded", limits.MaxPagesPerMonth)}
+	}
+	if limits.MaxBytes > 0 && usage.BytesStored+incoming > limits.MaxBytes {
+		return &ExceededError{Message: fmt.Sprintf("storage quota of %d bytes exceeded", limits.MaxBytes)}
+	}
+	return nil
+}
+
+// CheckRestore returns an *ExceededError if a job may not be restored from the
+// trash because its owner is over their storage quota, e.g. after it was
+// lowered. Jobs in the trash already count towards stored bytes, so the job's
+// own size is included in the usage and isn't added again.
+func (c *Checker) CheckRestore(ctx context.Context, job *models.PrintJob) error {
+	if job.UserID == "" {
+		return nil
+	}
+
+	var user models.User
+	if err := c.db.WithContext(ctx).First(&user, "id = ?", job.UserID).Error; err != nil {
+		return err
+	}
+	limits := c.LimitsFor(&user)
+	if limits.MaxBytes == 0 {
+		return nil
+	}
+
+	usage, err := c.UsageFor(ctx, job.UserID)
+	if err != nil {
+		return err
+	}
+	if usage.BytesStored > limits.MaxBytes {
+		return &ExceededError{Message: fmt.Sprintf("storage quota of %d bytes exceeded", limits.MaxBytes)}
+	}
+	return nil
+}
+
+// RemainingBytes returns how many more bytes a user may store, or false if
+// their storage is unlimited. Jobs without a user are not subject to quotas.
+func (c *Checker) RemainingBytes(ctx context.Context, userID string) (int64, bool, error) {
+	if userID == "" {
+		return 0, false, nil
+	}
+
+	var user models.User
+	if err := c.db.WithContext(ctx).First(&user, "id = ?", userID).Error; err != nil {
+		return 0, false, err
+	}
+	limits := c.LimitsFor(&user)
+	if limits.MaxBytes == 0 {
+		return 0, false, nil
+	}
+
+	usage, err := c.UsageFor(ctx, userID)
+	if err != nil {
+		return 0, false, err
+	}
+	return max(limits.MaxBytes-usage.BytesStored, 0), true, nil
+}
@@ -0,0 +1,423 @@
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"math/big"
+	"sort"
+)
+
+var (
+	oidData          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 1}
+	oidSignedData    = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 7, 2}
+	oidTSTInfo       = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 1, 4}
+	oidContentType   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 3}
+	oidMessageDigest = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 4}
+	oidSigningCertV2 = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 47}
+	oidTimestampAttr = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 9, 16, 2, 14}
+
+	oidSHA1   = asn1.ObjectIdentifier{1, 3, 14, 3, 2, 26}
+	oidSHA256 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 1}
+	oidSHA384 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 2}
+	oidSHA512 = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 2, 3}
+
+	oidSHA256WithRSA   = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 1, 11}
+	oidECDSAWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 10045, 4, 3, 2}
+)
+
+type contentInfo struct {
+	ContentType asn1.ObjectIdentifier
+	Content     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type encapsulatedContentInfo struct {
+	EContentType asn1.ObjectIdentifier
+	EContent     asn1.RawValue `asn1:"explicit,optional,tag:0"`
+}
+
+type signedData struct {
+	Version          int
+	DigestAlgorithms []pkix.AlgorithmIdentifier `asn1:"set"`
+	EncapContentInfo encapsulatedContentInfo
+	Certificates     asn1.RawValue `asn1:"optional,tag:0"`
+	CRLs             asn1.RawValue `asn1:"optional,tag:1"`
+	SignerInfos      []signerInfo  `asn1:"set"`
+}
+
+type signerInfo struct {
+	Version            int
+	SID                asn1.RawValue
+	DigestAlgorithm    pkix.AlgorithmIdentifier
+	SignedAttrs        asn1.RawValue `asn1:"optional,tag:0"`
+	SignatureAlgorithm pkix.AlgorithmIdentifier
+	Signature          []byte
+	UnsignedAttrs      asn1.RawValue `asn1:"optional,tag:1"`
+}
+
+type issuerAndSerial struct {
+	Issuer       asn1.RawValue
+	SerialNumber *big.Int
+}
+
+type attribute struct {
+	Type   asn1.ObjectIdentifier
+	Values asn1.RawValue
+}
+
+type essCertIDv2 struct {
+	CertHash     []byte
+	IssuerSerial issuerSerial
+}
+
+type issuerSerial struct {
+	Issuer       []asn1.RawValue // GeneralNames
+	SerialNumber *big.Int
+}
+
+type signingCertificateV2 struct {
+	Certs []essCertIDv2
+}
+
+// buildSignedData creates a detached CMS SignedData structure with
+// the attributes required by the PAdES baseline profile
+func (s *Signer) buildSignedData(digest []byte) ([]byte, error) {
+	certHash := crypto.SHA256.New()
+	certHash.Write(s.certificate.Raw)
+
+	signingCert, err := asn1.Marshal(signingCertificateV2{
+		Certs: []essCertIDv2{{
+			CertHash: certHash.Sum(nil),
+			IssuerSerial: issuerSerial{
+				Issuer:       []asn1.RawValue{{Class: asn1.ClassContextSpecific, Tag: 4, IsCompound: true, Bytes: s.certificate.RawIssuer}},
+				SerialNumber: s.certificate.SerialNumber,
+			},
+		}},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	contentTypeValue, _ := asn1.Marshal(oidData)
+	digestValue, _ := asn1.Marshal(digest)
+
+	attrs, err := marshalAttributes([]attribute{
+		{Type: oidContentType, Values: setOf(contentTypeValue)},
+		{Type: oidMessageDigest, Values: setOf(digestValue)},
+		{Type: oidSigningCertV2, Values: setOf(signingCert)},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	// The signature covers the DER encoding of the attributes as a SET
+	signedAttrs, err := asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: attrs})
+	if err != nil {
+		return nil, err
+	}
+	attrsDigest := crypto.SHA256.New()
+	attrsDigest.Write(signedAttrs)
+
+	signature, err := s.key.Sign(rand.Reader, attrsDigest.Sum(nil), crypto.SHA256)
+	if err != nil {
+		return nil, fmt.Errorf("failed to sign: %w", err)
+	}
+
+	sid, err := asn1.Marshal(issuerAndSerial{
+		Issuer:       asn1.RawValue{FullBytes: s.certificate.RawIssuer},
+		SerialNumber: s.certificate.SerialNumber,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	info := signerInfo{
+		Version:            1,
+		SID:                asn1.RawValue{FullBytes: sid},
+		DigestAlgorithm:    pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+		SignedAttrs:        asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: attrs},
+		SignatureAlgorithm: s.signatureAlgorithm(),
+		Signature:          signature,
+	}
+
+	if s.config.TSAURL != "" {
+		token, err := requestTimestamp(s.config.TSAURL, signature)
+		if err != nil {
+			return nil, fmt.Errorf("timestamp request failed: %w", err)
+		}
+		unsigned, err := marshalAttributes([]attribute{{Type: oidTimestampAttr, Values: setOf(token)}})
+		if err != nil {
+			return nil, err
+		}
+		info.UnsignedAttrs = asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 1, IsCompound: true, Bytes: unsigned}
+	}
+
+	var certs []byte
+	certs = append(certs, s.certificate.Raw...)
+	for _, cert := range s.chain {
+		certs = append(certs, cert.Raw...)
+	}
+
+	sd, err := asn1.Marshal(signedData{
+		Version:          1,
+		DigestAlgorithms: []pkix.AlgorithmIdentifier{{Algorithm: oidSHA256}},
+		EncapContentInfo: encapsulatedContentInfo{EContentType: oidData},
+		Certificates:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: certs},
+		SignerInfos:      []signerInfo{info},
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return asn1.Marshal(contentInfo{
+		ContentType: oidSignedData,
+		Content:     asn1.RawValue{Class: asn1.ClassContextSpecific, Tag: 0, IsCompound: true, Bytes: sd},
+	})
+}
+
+func (s *Signer) signatureAlgorithm() pkix.AlgorithmIdentifier {
+	if _, ok := s.key.(*ecdsa.PrivateKey); ok {
+		return pkix.AlgorithmIdentifier{Algorithm: oidECDSAWithSHA256}
+	}
+	return pkix.AlgorithmIdentifier{Algorithm: oidSHA256WithRSA, Parameters: asn1.NullRawValue}
+}
+
+// setOf wraps already encoded values into a SET
+func setOf(values ...[]byte) asn1.RawValue {
+	return asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: bytes.Join(values, nil)}
+}
+
+// marshalAttributes encodes attributes in DER SET OF order
+func marshalAttributes(attrs []attribute) ([]byte, error) {
+	encoded := make([][]byte, 0, len(attrs))
+	for _, attr := range attrs {
+		der, err := asn1.Marshal(attr)
+		if err != nil {
+			return nil, err
+		}
+		encoded = append(encoded, der)
+	}
+	sort.Slice(encoded, func(i, j int) bool {
+		return bytes.Compare(encoded[i], encoded[j]) < 0
+	})
+	return bytes.Join(encoded, nil), nil
+}
+
+// parseAttributes decodes the contents of a SET OF Attribute
+func parseAttributes(data []byte) ([]attribute, error) {
+	var attrs []attribute
+	for len(data) > 0 {
+		var attr attribute
+		rest, err := asn1.Unmarshal(data, &attr)
+		if err != nil {
+			return nil, err
+		}
+		attrs = append(attrs, attr)
+		data = rest
+	}
+	return attrs, nil
+}
+
+func findAttribute(attrs []attribute, oid asn1.ObjectIdentifier) ([]byte, bool) {
+	for _, attr := range attrs {
+		if attr.Type.Equal(oid) {
+			return attr.Values.Bytes, true
+		}
+	}
+	return nil, false
+}
+
+func hashForOID(oid asn1.ObjectIdentifier) (crypto.Hash, error) {
+	switch {
+	case oid.Equal(oidSHA1):
+		return crypto.SHA1, nil
+	case oid.Equal(oidSHA256):
+		return crypto.SHA256, nil
+	case oid.Equal(oidSHA384):
+		return crypto.SHA384, nil
+	case oid.Equal(oidSHA512):
+		return crypto.SHA512, nil
+	}
+	return 0, fmt.Errorf("unsupported digest algorithm %s", oid)
+}
+
+func x509SignatureAlgorithm(pub crypto.PublicKey, hash crypto.Hash) (x509.SignatureAlgorithm, error) {
+	switch pub.(type) {
+	case *rsa.PublicKey:
+		switch hash {
+		case crypto.SHA1:
+			return x509.SHA1WithRSA, nil
+		case crypto.SHA256:
+			return x509.SHA256WithRSA, nil
+		case crypto.SHA384:
+			return x509.SHA384WithRSA, nil
+		case crypto.SHA512:
+			return x509.SHA512WithRSA, nil
+		}
+	case *ecdsa.PublicKey:
+		switch hash {
+		case crypto.SHA1:
+			return x509.ECDSAWithSHA1, nil
+		case crypto.SHA256:
+			return x509.ECDSAWithSHA256, nil
+		case crypto.SHA384:
+			return x509.ECDSAWithSHA384, nil
+		case crypto.SHA512:
+			return x509.ECDSAWithSHA512, nil
+		}
+	}
+	return 0, fmt.Errorf("unsupported signer key %T with %s", pub, hash)
+}
+
+// cmsSignature is the result of verifying a CMS SignedData structure
+type cmsSignature struct {
+	certificate   *x509.Certificate
+	certificates  []*x509.Certificate
+	unsignedAttrs []attribute
+	contentType   asn1.ObjectIdentifier
+	content       []byte
+}
+
+// verifySignedData checks a CMS SignedData signature. When detached is nil,
+// the encapsulated content is verified instead.
+func verifySignedData(der []byte, detached []byte) (*cmsSignature, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, fmt.Errorf("invalid CMS structure: %w", err)
+	}
+	if !ci.ContentType.Equal(oidSignedData) {
+		return nil, errors.New("CMS content is not SignedData")
+	}
+
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, fmt.Errorf("invalid SignedData: %w", err)
+	}
+	if len(sd.SignerInfos) != 1 {
+		return nil, fmt.Errorf("expected one signer, found %d", len(sd.SignerInfos))
+	}
+
+	certs, err := x509.ParseCertificates(sd.Certificates.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("invalid certificates: %w", err)
+	}
+
+	content := detached
+	if content == nil {
+		if _, err := asn1.Unmarshal(sd.EncapContentInfo.EContent.Bytes, &content); err != nil {
+			return nil, fmt.Errorf("invalid encapsulated content: %w", err)
+		}
+	}
+
+	si := sd.SignerInfos[0]
+	cert, err := findSignerCertificate(si.SID, certs)
+	if err != nil {
+		return nil, err
+	}
+
+	hash, err := hashForOID(si.DigestAlgorithm.Algorithm)
+	if err != nil {
+		return nil, err
+	}
+	h := hash.New()
+	h.Write(content)
+	contentDigest := h.Sum(nil)
+
+	signedInput := content
+	if len(si.SignedAttrs.Bytes) > 0 {
+		attrs, err := parseAttributes(si.SignedAttrs.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("invalid signed attributes: %w", err)
+		}
+		value, ok := findAttribute(attrs, oidMessageDigest)
+		if !ok {
+			return nil, errors.New("message digest attribute missing")
+		}
+		var messageDigest []byte
+		if _, err := asn1.Unmarshal(value, &messageDigest); err != nil {
+			return nil, fmt.Errorf("invalid message digest attribute: %w", err)
+		}
+		if !bytes.Equal(messageDigest, contentDigest) {
+			return nil, errors.New("document digest does not match signature")
+		}
+		if value, ok := findAttribute(attrs, oidSigningCertV2); ok {
+			var sc signingCertificateV2
+			if _, err := asn1.Unmarshal(value, &sc); err == nil && len(sc.Certs) > 0 {
+				certHash := crypto.SHA256.New()
+				certHash.Write(cert.Raw)
+				if !bytes.Equal(sc.Certs[0].CertHash, certHash.Sum(nil)) {
+					return nil, errors.New("signing certificate attribute does not match signer")
+				}
+			}
+		}
+
+		signedInput, err = asn1.Marshal(asn1.RawValue{Class: asn1.ClassUniversal, Tag: asn1.TagSet, IsCompound: true, Bytes: si.SignedAttrs.Bytes})
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	algorithm, err := x509SignatureAlgorithm(cert.PublicKey, hash)
+	if err != nil {
+		return nil, err
+	}
+	if err := cert.CheckSignature(algorithm, signedInput, si.Signature); err != nil {
+		return nil, fmt.Errorf("signature verification failed: %w", err)
+	}
+
+	result := &cmsSignature{
+		certificate:  cert,
+		certificates: certs,
+		contentType:  sd.EncapContentInfo.EContentType,
+		content:      content,
+	}
+	if len(si.UnsignedAttrs.Bytes) > 0 {
+		result.unsignedAttrs, _ = parseAttributes(si.UnsignedAttrs.Bytes)
+	}
+	return result, nil
+}
+
+// signerValue returns the signature value of the first signer in a SignedData structure
+func signerValue(der []byte) ([]byte, error) {
+	var ci contentInfo
+	if _, err := asn1.Unmarshal(der, &ci); err != nil {
+		return nil, err
+	}
+	var sd signedData
+	if _, err := asn1.Unmarshal(ci.Content.Bytes, &sd); err != nil {
+		return nil, err
+	}
+	if len(sd.SignerInfos) == 0 {
+		return nil, errors.New("no signer found")
+	}
+	return sd.SignerInfos[0].Signature, nil
+}
+
+func findSignerCertificate(sid asn1.RawValue, certs []*x509.Certificate) (*x509.Certificate, error) {
+	switch {
+	case sid.Class == asn1.ClassUniversal && sid.Tag == asn1.TagSequence:
+		var ias issuerAndSerial
+		if _, err := asn1.Unmarshal(sid.FullBytes, &ias); err != nil {
+			return nil, fmt.Errorf("invalid signer identifier: %w", err)
+		}
+		for _, cert := range certs {
+			if cert.SerialNumber.Cmp(ias.SerialNumber) == 0 && bytes.Equal(cert.RawIssuer, ias.Issuer.FullBytes) {
+				return cert, nil
+			}
+		}
+	case sid.Class == asn1.ClassContextSpecific && sid.Tag == 0:
+		for _, cert := range certs {
+			if bytes.Equal(cert.SubjectKeyId, sid.Bytes) {
+				return cert, nil
+			}
+		}
+	}
+	return nil, errors.New("signer certificate not found")
+}
@@ -0,0 +1,456 @@
+package signing
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+)
+
+// ErrUnsupportedPDF is returned for documents the incremental writer cannot update
+// (cross-reference streams, encrypted files, ...)
+var ErrUnsupportedPDF = errors.New("unsupported PDF structure")
+
+type objRef struct {
+	num, gen int
+}
+
+func (r objRef) String() string {
+	return fmt.Sprintf("%d %d R", r.num, r.gen)
+}
+
+// dictEntry is a key and its raw PDF value
+type dictEntry struct {
+	key   string
+	value string
+}
+
+type pdfDict []dictEntry
+
+func (d pdfDict) get(key string) (string, bool) {
+	for _, e := range d {
+		if e.key == key {
+			return e.value, true
+		}
+	}
+	return "", false
+}
+
+func (d pdfDict) set(key, value string) pdfDict {
+	for i, e := range d {
+		if e.key == key {
+			d[i].value = value
+			return d
+		}
+	}
+	return append(d, dictEntry{key: key, value: value})
+}
+
+func (d pdfDict) String() string {
+	var b strings.Builder
+	b.WriteString("<<")
+	for _, e := range d {
+		b.WriteString(" ")
+		b.WriteString(e.key)
+		b.WriteString(" ")
+		b.WriteString(e.value)
+	}
+	b.WriteString(" >>")
+	return b.String()
+}
+
+// lexer is a minimal PDF tokenizer, sufficient to read dictionaries and arrays
+type lexer struct {
+	data []byte
+	pos  int
+}
+
+func isWhitespace(c byte) bool {
+	return c == 0 || c == '\t' || c == '\n' || c == '\f' || c == '\r' || c == ' '
+}
+
+func isDelimiter(c byte) bool {
+	return strings.IndexByte("()<>[]{}/%", c) >= 0
+}
+
+func (l *lexer) skipSpace() {
+	for l.pos < len(l.data) {
+		c := l.data[l.pos]
+		if isWhitespace(c) {
+			l.pos++
+		} else if c == '%' {
+			for l.pos < len(l.data) && l.data[l.pos] != '\n' && l.data[l.pos] != '\r' {
+				l.pos++
+			}
+		} else {
+			return
+		}
+	}
+}
+
+func (l *lexer) peek(s string) bool {
+	l.skipSpace()
+	return bytes.HasPrefix(l.data[l.pos:], []byte(s))
+}
+
+// token reads a regular token (number, keyword)
+func (l *lexer) token() string {
+	l.skipSpace()
+	start := l.pos
+	for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+		l.pos++
+	}
+	return string(l.data[start:l.pos])
+}
+
+func (l *lexer) integer() (int64, error) {
+	tok := l.token()
+	n, err := strconv.ParseInt(tok, 10, 64)
+	if err != nil {
+		return 0, fmt.Errorf("%w: expected integer, got %q", ErrUnsupportedPDF, tok)
+	}
+	return n, nil
+}
+
+// value reads the next object and returns its raw text
+func (l *lexer) value() (string, error) {
+	l.skipSpace()
+	if l.pos >= len(l.data) {
+		return "", fmt.Errorf("%w: unexpected end of data", ErrUnsupportedPDF)
+	}
+	start := l.pos
+
+	switch c := l.data[l.pos]; {
+	case c == '/':
+		l.pos++
+		for l.pos < len(l.data) && !isWhitespace(l.data[l.pos]) && !isDelimiter(l.data[l.pos]) {
+			l.pos++
+		}
+	case c == '(':
+		depth := 0
+		for l.pos < len(l.data) {
+			switch l.data[l.pos] {
+			case '\\':
+				l.pos++
+			case '(':
+				depth++
+			case ')':
+				depth--
+			}
+			l.pos++
+			if depth == 0 {
+				break
+			}
+		}
+	case c == '<' && l.pos+1 < len(l.data) && l.data[l.pos+1] == '<':
+		if _, err := l.dict(); err != nil {
+			return "", err
+		}
+	case c == '<':
+		end := bytes.IndexByte(l.data[l.pos:], '>')
+		if end < 0 {
+			return "", fmt.Errorf("%w: unterminated hex string", ErrUnsupportedPDF)
+		}
+		l.pos += end + 1
+	case c == '[':
+		l.pos++
+		for !l.peek("]") {
+			if _, err := l.value(); err != nil {
+				return "", err
+			}
+		}
+		l.pos++
+	default:
+		tok := l.token()
+		if tok == "" {
+			return "", fmt.Errorf("%w: unexpected character %q", ErrUnsupportedPDF, c)
+		}
+		// An integer may start an indirect reference "num gen R"
+		if _, err := strconv.Atoi(tok); err == nil {
+			save := l.pos
+			gen := l.token()
+			if _, err := strconv.Atoi(gen); err == nil && l.token() == "R" {
+				return string(l.data[start:l.pos]), nil
+			}
+			l.pos = save
+		}
+	}
+
+	return string(l.data[start:l.pos]), nil
+}
+
+// dict reads a dictionary and returns its entries
+func (l *lexer) dict() (pdfDict, error) {
+	if !l.peek("<<") {
+		return nil, fmt.Errorf("%w: expected dictionary", ErrUnsupportedPDF)
+	}
+	l.pos += 2
+
+	var d pdfDict
+	for !l.peek(">>") {
+		key, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+		if !strings.HasPrefix(key, "/") {
+			return nil, fmt.Errorf("%w: invalid dictionary key %q", ErrUnsupportedPDF, key)
+		}
+		val, err := l.value()
+		if err != nil {
+			return nil, err
+		}
+		d = append(d, dictEntry{key: key, value: val})
+	}
+	l.pos += 2
+	return d, nil
+}
+
+func parseRef(value string) (objRef, bool) {
+	fields := strings.Fields(value)
+	if len(fields) != 3 || fields[2] != "R" {
+		return objRef{}, false
+	}
+	num, err1 := strconv.Atoi(fields[0])
+	gen, err2 := strconv.Atoi(fields[1])
+	if err1 != nil || err2 != nil {
+		return objRef{}, false
+	}
+	return objRef{num: num, gen: gen}, true
+}
+
+// pdfDocument holds the parts of an existing PDF needed for an incremental update
+type pdfDocument struct {
+	data      []byte
+	xrefStart int64
+	trailer   pdfDict
+	size      int
+	offsets   map[int]int64
+}
+
+func parsePDF(data []byte) (*pdfDocument, error) {
+	idx := bytes.LastIndex(data, []byte("startxref"))
+	if idx < 0 {
+		return nil, fmt.Errorf("%w: startxref not found", ErrUnsupportedPDF)
+	}
+
+	l := &lexer{data: data, pos: idx + len("startxref")}
+	xrefStart, err := l.integer()
+	if err != nil {
+		return nil, err
+	}
+
+	doc := &pdfDocument{data: data, xrefStart: xrefStart, offsets: make(map[int]int64)}
+
+	offset := xrefStart
+	seen := make(map[int64]bool)
+	for {
+		if seen[offset] {
+			return nil, fmt.Errorf("%w: cross-reference loop", ErrUnsupportedPDF)
+		}
+		seen[offset] = true
+
+		trailer, err := doc.readXref(offset)
+		if err != nil {
+			return nil, err
+		}
+		if doc.trailer == nil {
+			doc.trailer = trailer
+		}
+
+		prev, ok := trailer.get("/Prev")
+		if !ok {
+			break
+		}
+		offset, err = strconv.ParseInt(prev, 10, 64)
+		if err != nil {
+			return nil, fmt.Errorf("%w: invalid /Prev", ErrUnsupportedPDF)
+		}
+	}
+
+	if _, encrypted := doc.trailer.get("/Encrypt"); encrypted {
+		return nil, fmt.Errorf("%w: encrypted documents cannot be signed", ErrUnsupportedPDF)
+	}
+	size, ok := doc.trailer.get("/Size")
+	if !ok {
+		return nil, fmt.Errorf("%w: trailer has no /Size", ErrUnsupportedPDF)
+	}
+	if doc.size, err = strconv.Atoi(size); err != nil {
+		return nil, fmt.Errorf("%w: invalid /Size", ErrUnsupportedPDF)
+	}
+
+	return doc, nil
+}
+
+// readXref parses a classic cross-reference table and returns its trailer
+func (doc *pdfDocument) readXref(offset int64) (pdfDict, error) {
+	if offset < 0 || offset >= int64(len(doc.data)) {
+		return nil, fmt.Errorf("%w: xref offset out of range", ErrUnsupportedPDF)
+	}
+
+	l := &lexer{data: doc.data, pos: int(offset)}
+	if l.token() != "xref" {
+		// Cross-reference streams (PDF 1.5+) are not supported
+		return nil, fmt.Errorf("%w: cross-reference streams are not supported", ErrUnsupportedPDF)
+	}
+
+	for !l.peek("trailer") {
+		start, err := l.integer()
+		if err != nil {
+			return nil, err
+		}
+		count, err := l.integer()
+		if err != nil {
+			return nil, err
+		}
+		for i := int64(0); i < count; i++ {
+			objOffset, err := l.integer()
+			if err != nil {
+				return nil, err
+			}
+			if _, err := l.integer(); err != nil {
+				return nil, err
+			}
+			kind := l.token()
+
+			num := int(start + i)
+			if _, known := doc.offsets[num]; known {
+				// Newer revisions are read first
+				continue
+			}
+			if kind == "n" {
+				doc.offsets[num] = objOffset
+			} else {
+				doc.offsets[num] = -1
+			}
+		}
+	}
+	l.token() // trailer
+
+	return l.dict()
+}
+
+// readObject returns the raw value of an indirect object
+func (doc *pdfDocument) readObject(ref objRef) (string, error) {
+	offset, ok := doc.offsets[ref.num]
+	if !ok || offset < 0 {
+		return "", fmt.Errorf("%w: object %d not found", ErrUnsupportedPDF, ref.num)
+	}
+
+	l := &lexer{data: doc.data, pos: int(offset)}
+	num, err := l.integer()
+	if err != nil {
+		return "", err
+	}
+	if _, err := l.integer(); err != nil {
+		return "", err
+	}
+	if int(num) != ref.num || l.token() != "obj" {
+		return "", fmt.Errorf("%w: object %d has an invalid header", ErrUnsupportedPDF, ref.num)
+	}
+	return l.value()
+}
+
+func (doc *pdfDocument) readDict(ref objRef) (pdfDict, error) {
+	raw, err := doc.readObject(ref)
+	if err != nil {
+		return nil, err
+	}
+	l := &lexer{data: []byte(raw)}
+	return l.dict()
+}
+
+// firstPage walks the page tree to the first leaf page
+func (doc *pdfDocument) firstPage(catalog pdfDict) (objRef, pdfDict, error) {
+	value, _ := catalog.get("/Pages")
+	ref, ok := parseRef(value)
+	if !ok {
+		return objRef{}, nil, fmt.Errorf("%w: catalog has no page tree", ErrUnsupportedPDF)
+	}
+
+	for depth := 0; depth < 32; depth++ {
+		node, err := doc.readDict(ref)
+		if err != nil {
+			return objRef{}, nil, err
+		}
+		if typ, _ := node.get("/Type"); typ != "/Pages" {
+			return ref, node, nil
+		}
+
+		kids, _ := node.get("/Kids")
+		if kidsRef, ok := parseRef(kids); ok {
+			if kids, err = doc.readObject(kidsRef); err != nil {
+				return objRef{}, nil, err
+			}
+		}
+		l := &lexer{data: []byte(kids)}
+		if !l.peek("[") {
+			return objRef{}, nil, fmt.Errorf("%w: invalid /Kids", ErrUnsupportedPDF)
+		}
+		l.pos++
+		first, err := l.value()
+		if err != nil {
+			return objRef{}, nil, err
+		}
+		if ref, ok = parseRef(first); !ok {
+			return objRef{}, nil, fmt.Errorf("%w: invalid page reference", ErrUnsupportedPDF)
+		}
+	}
+	return objRef{}, nil, fmt.Errorf("%w: page tree too deep", ErrUnsupportedPDF)
+}
+
+// incrementalUpdate collects objects to be appended to a document
+type incrementalUpdate struct {
+	doc     *pdfDocument
+	nextNum int
+	objects map[objRef]string
+}
+
+func newIncrementalUpdate(doc *pdfDocument) *incrementalUpdate {
+	return &incrementalUpdate{doc: doc, nextNum: doc.size, objects: make(map[objRef]string)}
+}
+
+func (u *incrementalUpdate) allocate() objRef {
+	ref := objRef{num: u.nextNum}
+	u.nextNum++
+	return ref
+}
+
+// appendToArray adds item to an array value that is either inline or an indirect object
+func (u *incrementalUpdate) appendToArray(value string, item objRef) (string, error) {
+	if ref, ok := parseRef(value); ok {
+		arr, err := u.doc.readObject(ref)
+		if err != nil {
+			return "", err
+		}
+		if !strings.HasPrefix(arr, "[") {
+			return "", fmt.Errorf("%w: object %d is not an array", ErrUnsupportedPDF, ref.num)
+		}
+		u.objects[ref] = strings.TrimSuffix(arr, "]") + " " + item.String() + "]"
+		return value, nil
+	}
+	if value == "" {
+		return "[" + item.String() + "]", nil
+	}
+	if !strings.HasPrefix(value, "[") {
+		return "", fmt.Errorf("%w: expected array, got %q", ErrUnsupportedPDF, value)
+	}
+	return strings.TrimSuffix(value, "]") + " " + item.String() + "]", nil
+}
+
+// pdfString encodes s as a PDF literal string
+func pdfString(s string) string {
+	r := strings.NewReplacer(`\`, `\\`, `(`, `\(`, `)`, `\)`, "\r", `\r`, "\n", `\n`)
+	return "(" + r.Replace(s) + ")"
+}
+
+// sortedRefs returns the update's objects in object number order
+func (u *incrementalUpdate) sortedRefs() []objRef {
+	refs := make([]objRef, 0, len(u.objects))
+	for ref := range u.objects {
+		refs = append(refs, ref)
+	}
+	sort.Slice(refs, func(i, j int) bool { return refs[i].num < refs[j].num })
+	return refs
+}
@@ -0,0 +1,174 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// signatureSize is the space reserved for the CMS structure (in bytes).
+// It must fit the certificate chain and an optional timestamp token.
+const signatureSize = 16384
+
+// Sign appends a PAdES-B signature to a PDF as an incremental update
+func (s *Signer) Sign(pdf []byte) ([]byte, error) {
+	doc, err := parsePDF(pdf)
+	if err != nil {
+		return nil, err
+	}
+
+	rootValue, _ := doc.trailer.get("/Root")
+	rootRef, ok := parseRef(rootValue)
+	if !ok {
+		return nil, fmt.Errorf("%w: trailer has no /Root", ErrUnsupportedPDF)
+	}
+	catalog, err := doc.readDict(rootRef)
+	if err != nil {
+		return nil, err
+	}
+
+	update := newIncrementalUpdate(doc)
+	sigRef := update.allocate()
+	fieldRef := update.allocate()
+
+	// Attach the (invisible) signature widget to the first page
+	pageRef, page, err := doc.firstPage(catalog)
+	if err != nil {
+		return nil, err
+	}
+	annots, _ := page.get("/Annots")
+	if annots, err = update.appendToArray(annots, fieldRef); err != nil {
+		return nil, err
+	}
+	update.objects[pageRef] = page.set("/Annots", annots).String()
+
+	// Register the signature field in the document's AcroForm
+	acroForm := pdfDict{}
+	acroFormRef, indirectForm := objRef{}, false
+	if value, ok := catalog.get("/AcroForm"); ok {
+		if acroFormRef, indirectForm = parseRef(value); indirectForm {
+			acroForm, err = doc.readDict(acroFormRef)
+		} else {
+			acroForm, err = (&lexer{data: []byte(value)}).dict()
+		}
+		if err != nil {
+			return nil, err
+		}
+	}
+	fields, _ := acroForm.get("/Fields")
+	if fields, err = update.appendToArray(fields, fieldRef); err != nil {
+		return nil, err
+	}
+	acroForm = acroForm.set("/Fields", fields).set("/SigFlags", "3")
+	if indirectForm {
+		update.objects[acroFormRef] = acroForm.String()
+	} else {
+		catalog = catalog.set("/AcroForm", acroForm.String())
+	}
+	update.objects[rootRef] = catalog.String()
+
+	update.objects[fieldRef] = pdfDict{
+		{"/Type", "/Annot"},
+		{"/Subtype", "/Widget"},
+		{"/FT", "/Sig"},
+		{"/T", pdfString(fmt.Sprintf("Zikzi Signature %d", sigRef.num))},
+		{"/V", sigRef.String()},
+		{"/F", "132"},
+		{"/Rect", "[0 0 0 0]"},
+		{"/P", pageRef.String()},
+	}.String()
+
+	// The signature dictionary gets placeholders that are filled in once offsets are known
+	byteRangePlaceholder := "[0 0000000000 0000000000 0000000000]"
+	contentsPlaceholder := "<" + strings.Repeat("0", signatureSize*2) + ">"
+	sigDict := pdfDict{
+		{"/Type", "/Sig"},
+		{"/Filter", "/Adobe.PPKLite"},
+		{"/SubFilter", "/ETSI.CAdES.detached"},
+		{"/ByteRange", byteRangePlaceholder},
+		{"/Contents", contentsPlaceholder},
+		{"/M", pdfString(pdfDate(time.Now()))},
+	}
+	if s.config.Reason != "" {
+		sigDict = append(sigDict, dictEntry{"/Reason", pdfString(s.config.Reason)})
+	}
+	if s.config.Location != "" {
+		sigDict = append(sigDict, dictEntry{"/Location", pdfString(s.config.Location)})
+	}
+	update.objects[sigRef] = sigDict.String()
+
+	// Write the incremental update
+	var buf bytes.Buffer
+	buf.Write(pdf)
+	if !bytes.HasSuffix(pdf, []byte("\n")) {
+		buf.WriteByte('\n')
+	}
+
+	offsets := make(map[objRef]int, len(update.objects))
+	byteRangePos, contentsPos := -1, -1
+	for _, ref := range update.sortedRefs() {
+		offsets[ref] = buf.Len()
+		body := update.objects[ref]
+		if ref == sigRef {
+			byteRangePos = buf.Len() + len(fmt.Sprintf("%d %d obj\n", ref.num, ref.gen)) + strings.Index(body, byteRangePlaceholder)
+			contentsPos = buf.Len() + len(fmt.Sprintf("%d %d obj\n", ref.num, ref.gen)) + strings.Index(body, contentsPlaceholder)
+		}
+		fmt.Fprintf(&buf, "%d %d obj\n%s\nendobj\n", ref.num, ref.gen, body)
+	}
+
+	xrefOffset := buf.Len()
+	buf.WriteString("xref\n")
+	for _, ref := range update.sortedRefs() {
+		// One subsection per object keeps the table valid for non-contiguous numbers
+		fmt.Fprintf(&buf, "%d 1\n%010d %05d n\r\n", ref.num, offsets[ref], ref.gen)
+	}
+
+	trailer := pdfDict{
+		{"/Size", fmt.Sprintf("%d", update.nextNum)},
+		{"/Root", rootRef.String()},
+		{"/Prev", fmt.Sprintf("%d", doc.xrefStart)},
+	}
+	if info, ok := doc.trailer.get("/Info"); ok {
+		trailer = append(trailer, dictEntry{"/Info", info})
+	}
+	if id, ok := doc.trailer.get("/ID"); ok {
+		trailer = append(trailer, dictEntry{"/ID", id})
+	}
+	fmt.Fprintf(&buf, "trailer\n%s\nstartxref\n%d\n%%%%EOF\n", trailer.String(), xrefOffset)
+
+	out := buf.Bytes()
+
+	// Fill in the byte range: everything except the /Contents hex string
+	contentsEnd := contentsPos + len(contentsPlaceholder)
+	byteRange := fmt.Sprintf("[0 %010d %010d %010d]", contentsPos, contentsEnd, len(out)-contentsEnd)
+	copy(out[byteRangePos:], byteRange)
+
+	digest := sha256.New()
+	digest.Write(out[:contentsPos])
+	digest.Write(out[contentsEnd:])
+
+	cms, err := s.buildSignedData(digest.Sum(nil))
+	if err != nil {
+		return nil, err
+	}
+	if len(cms) > signatureSize {
+		return nil, fmt.Errorf("signature too large (%d bytes, %d reserved)", len(cms), signatureSize)
+	}
+	copy(out[contentsPos+1:], strings.ToUpper(hex.EncodeToString(cms)))
+
+	return out, nil
+}
+
+// pdfDate formats a time as a PDF date string
+func pdfDate(t time.Time) string {
+	_, offset := t.Zone()
+	sign := '+'
+	if offset < 0 {
+		sign = '-'
+		offset = -offset
+	}
+	return fmt.Sprintf("D:%s%c%02d'%02d'", t.Format("20060102150405"), sign, offset/3600, (offset%3600)/60)
+}
@@ -0,0 +1,176 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+)
+
+// newTestSigner creates a signer with a fresh self-signed ECDSA certificate
+func newTestSigner(t *testing.T, commonName string) *Signer {
+	t.Helper()
+
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		t.Fatal(err)
+	}
+	template := &x509.Certificate{
+		SerialNumber: big.NewInt(1),
+		Subject:      pkix.Name{CommonName: commonName},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().Add(time.Hour),
+		KeyUsage:     x509.KeyUsageDigitalSignature,
+	}
+	certDER, err := x509.CreateCertificate(rand.Reader, template, template, key.Public(), key)
+	if err != nil {
+		t.Fatal(err)
+	}
+	keyDER, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var buf bytes.Buffer
+	pem.Encode(&buf, &pem.Block{Type: "PRIVATE KEY", Bytes: keyDER})
+	pem.Encode(&buf, &pem.Block{Type: "CERTIFICATE", Bytes: certDER})
+	path := filepath.Join(t.TempDir(), "signing.pem")
+	if err := os.WriteFile(path, buf.Bytes(), 0600); err != nil {
+		t.Fatal(err)
+	}
+
+	signer, err := NewSigner(config.SigningConfig{Enabled: true, KeyFile: path})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return signer
+}
+
+// testPDF builds a minimal one-page PDF with a classic cross-reference table
+func testPDF() []byte {
+	objects := []string{
+		"<< /Type /Catalog /Pages 2 0 R >>",
+		"<< /Type /Pages /Kids [3 0 R] /Count 1 >>",
+		"<< /Type /Page /Parent 2 0 R /MediaBox [0 0 612 792] /Contents 4 0 R >>",
+		"<< /Length 20 >>\nstream\nBT /F1 12 Tf (Hi) ET\nendstream",
+	}
+
+	var buf bytes.Buffer
+	buf.WriteString("%PDF-1.4\n")
+	offsets := make([]int, len(objects))
+	for i, obj := range objects {
+		offsets[i] = buf.Len()
+		fmt.Fprintf(&buf, "%d 0 obj\n%s\nendobj\n", i+1, obj)
+	}
+	xref := buf.Len()
+	fmt.Fprintf(&buf, "xref\n0 %d\n0000000000 65535 f \n", len(objects)+1)
+	for _, offset := range offsets {
+		fmt.Fprintf(&buf, "%010d 00000 n \n", offset)
+	}
+	fmt.Fprintf(&buf, "trailer\n<< /Size %d /Root 1 0 R >>\nstartxref\n%d\n%%%%EOF\n", len(objects)+1, xref)
+	return buf.Bytes()
+}
+
+func TestSignAndVerify(t *testing.T) {
+	signer := newTestSigner(t, "Zikzi Test")
+	pdf := testPDF()
+
+	if _, err := Verify(pdf); !errors.Is(err, ErrNotSigned) {
+		t.Fatalf("Verify(unsigned) error = %v, want ErrNotSigned", err)
+	}
+
+	signed, err := signer.Sign(pdf)
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	if !bytes.HasPrefix(signed, pdf) {
+		t.Fatal("signature is not an incremental update of the original")
+	}
+
+	v, err := Verify(signed)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	if !v.CoversDocument {
+		t.Error("CoversDocument = false, want true")
+	}
+	if want := CertificateFingerprint(signer.Certificate()); v.Fingerprint != want {
+		t.Errorf("Fingerprint = %s, want %s", v.Fingerprint, want)
+	}
+	if v.Signer != "CN=Zikzi Test" {
+		t.Errorf("Signer = %q, want CN=Zikzi Test", v.Signer)
+	}
+	if v.Timestamped {
+		t.Error("Timestamped = true without a TSA")
+	}
+}
+
+func TestVerifyTampered(t *testing.T) {
+	signer := newTestSigner(t, "Zikzi Test")
+	signed, err := signer.Sign(testPDF())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	content := bytes.Index(signed, []byte("(Hi)"))
+	trailer := bytes.LastIndex(signed, []byte("startxref"))
+
+	tests := []struct {
+		name   string
+		tamper func([]byte) []byte
+	}{
+		{"page content", func(b []byte) []byte { b[content+1] = 'X'; return b }},
+		{"signed trailer", func(b []byte) []byte { b[trailer] = 'S'; return b }},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			data := tt.tamper(bytes.Clone(signed))
+			if _, err := Verify(data); err == nil {
+				t.Fatal("Verify succeeded on a tampered PDF")
+			}
+		})
+	}
+
+	t.Run("appended update", func(t *testing.T) {
+		data := append(bytes.Clone(signed), []byte("1 0 obj\n<< /Type /Catalog >>\nendobj\n")...)
+		v, err := Verify(data)
+		if err != nil {
+			t.Fatalf("Verify: %v", err)
+		}
+		if v.CoversDocument {
+			t.Error("CoversDocument = true for a PDF modified after signing")
+		}
+	})
+}
+
+func TestVerifyOtherSigner(t *testing.T) {
+	zikzi := newTestSigner(t, "Zikzi Test")
+	other := newTestSigner(t, "Someone Else")
+
+	signed, err := other.Sign(testPDF())
+	if err != nil {
+		t.Fatalf("Sign: %v", err)
+	}
+	v, err := Verify(signed)
+	if err != nil {
+		t.Fatalf("Verify: %v", err)
+	}
+	// The signature is intact, only the certificate tells it apart
+	if !v.CoversDocument {
+		t.Error("CoversDocument = false, want true")
+	}
+	if v.Fingerprint == CertificateFingerprint(zikzi.Certificate()) {
+		t.Error("Fingerprint matches a certificate that didn't sign the PDF")
+	}
+}
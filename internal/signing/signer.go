@@ -0,0 +1,192 @@
+package signing
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/hex"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"software.sslmate.com/src/go-pkcs12"
+)
+
+// Signer applies PAdES-B signatures to generated PDFs
+type Signer struct {
+	config      config.SigningConfig
+	key         crypto.Signer
+	certificate *x509.Certificate
+	chain       []*x509.Certificate
+}
+
+// NewSigner loads the configured signing key and certificate.
+// Returns nil without error when signing is disabled.
+func NewSigner(cfg config.SigningConfig) (*Signer, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+	if cfg.KeyFile == "" {
+		return nil, errors.New("signing enabled but no key_file configured")
+	}
+
+	data, err := os.ReadFile(cfg.KeyFile)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read signing key: %w", err)
+	}
+
+	var key crypto.Signer
+	var certs []*x509.Certificate
+
+	switch strings.ToLower(filepath.Ext(cfg.KeyFile)) {
+	case ".p12", ".pfx":
+		privateKey, cert, caCerts, err := pkcs12.DecodeChain(data, cfg.Password)
+		if err != nil {
+			return nil, fmt.Errorf("failed to decode PKCS#12 file: %w", err)
+		}
+		signer, ok := privateKey.(crypto.Signer)
+		if !ok {
+			return nil, fmt.Errorf("unsupported private key type %T", privateKey)
+		}
+		key = signer
+		certs = append([]*x509.Certificate{cert}, caCerts...)
+	default:
+		blocks := decodePEMBlocks(data)
+		for _, block := range blocks {
+			switch {
+			case block.Type == "CERTIFICATE":
+				cert, err := x509.ParseCertificate(block.Bytes)
+				if err != nil {
+					return nil, fmt.Errorf("failed to parse certificate: %w", err)
+				}
+				certs = append(certs, cert)
+			case strings.HasSuffix(block.Type, "PRIVATE KEY") && key == nil:
+				key, err = parsePrivateKey(block.Bytes)
+				if err != nil {
+					return nil, err
+				}
+			}
+		}
+	}
+
+	if cfg.CertFile != "" {
+		certData, err := os.ReadFile(cfg.CertFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read signing certificate: %w", err)
+		}
+		for _, block := range decodePEMBlocks(certData) {
+			if block.Type != "CERTIFICATE" {
+				continue
+			}
+			cert, err := x509.ParseCertificate(block.Bytes)
+			if err != nil {
+				return nil, fmt.Errorf("failed to parse certificate: %w", err)
+			}
+			certs = append(certs, cert)
+		}
+	}
+
+	if key == nil {
+		return nil, errors.New("no private key found in signing key file")
+	}
+	switch key.(type) {
+	case *rsa.PrivateKey, *ecdsa.PrivateKey:
+	default:
+		return nil, fmt.Errorf("unsupported signing key type %T (RSA or ECDSA required)", key)
+	}
+
+	s := &Signer{config: cfg, key: key}
+	for _, cert := range certs {
+		if s.certificate == nil && publicKeysEqual(cert.PublicKey, key.Public()) {
+			s.certificate = cert
+			continue
+		}
+		s.chain = append(s.chain, cert)
+	}
+	if s.certificate == nil {
+		return nil, errors.New("no certificate matching the signing key was found")
+	}
+
+	return s, nil
+}
+
+// Certificate returns the signing certificate
+func (s *Signer) Certificate() *x509.Certificate {
+	return s.certificate
+}
+
+// SignFile signs the PDF at path in place
+func (s *Signer) SignFile(path string) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+
+	signed, err := s.Sign(data)
+	if err != nil {
+		return err
+	}
+
+	tmpPath := path + ".signing"
+	if err := os.WriteFile(tmpPath, signed, 0644); err != nil {
+		return err
+	}
+	if err := os.Rename(tmpPath, path); err != nil {
+		os.Remove(tmpPath)
+		return err
+	}
+	return nil
+}
+
+// CertificateFingerprint returns the hex SHA-256 fingerprint of a certificate
+func CertificateFingerprint(cert *x509.Certificate) string {
+	sum := sha256.Sum256(cert.Raw)
+	return hex.EncodeToString(sum[:])
+}
+
+func decodePEMBlocks(data []byte) []*pem.Block {
+	var blocks []*pem.Block
+	for {
+		var block *pem.Block
+		block, data = pem.Decode(data)
+		if block == nil {
+			return blocks
+		}
+		blocks = append(blocks, block)
+	}
+}
+
+func parsePrivateKey(der []byte) (crypto.Signer, error) {
+	if key, err := x509.ParsePKCS8PrivateKey(der); err == nil {
+		if signer, ok := key.(crypto.Signer); ok {
+			return signer, nil
+		}
+		return nil, fmt.Errorf("unsupported private key type %T", key)
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(der); err == nil {
+		return key, nil
+	}
+	if key, err := x509.ParseECPrivateKey(der); err == nil {
+		return key, nil
+	}
+	return nil, errors.New("failed to parse private key")
+}
+
+func publicKeysEqual(a, b crypto.PublicKey) bool {
+	aDER, err := x509.MarshalPKIXPublicKey(a)
+	if err != nil {
+		return false
+	}
+	bDER, err := x509.MarshalPKIXPublicKey(b)
+	if err != nil {
+		return false
+	}
+	return bytes.Equal(aDER, bDER)
+}
@@ -0,0 +1,132 @@
+package signing
+
+import (
+	"bytes"
+	"crypto/rand"
+	"crypto/sha256"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"time"
+)
+
+// RFC 3161 structures
+type messageImprint struct {
+	HashAlgorithm pkix.AlgorithmIdentifier
+	HashedMessage []byte
+}
+
+type timeStampReq struct {
+	Version        int
+	MessageImprint messageImprint
+	Nonce          *big.Int `asn1:"optional"`
+	CertReq        bool     `asn1:"optional"`
+}
+
+type pkiStatusInfo struct {
+	Status int
+}
+
+type timeStampResp struct {
+	Status         pkiStatusInfo
+	TimeStampToken asn1.RawValue `asn1:"optional"`
+}
+
+type tstInfo struct {
+	Version        int
+	Policy         asn1.ObjectIdentifier
+	MessageImprint messageImprint
+	SerialNumber   *big.Int
+	GenTime        time.Time `asn1:"generalized"`
+}
+
+var tsaClient = &http.Client{Timeout: 30 * time.Second}
+
+// requestTimestamp obtains an RFC 3161 timestamp token over the given signature value
+func requestTimestamp(url string, signature []byte) ([]byte, error) {
+	digest := sha256.Sum256(signature)
+
+	nonce, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 64))
+	if err != nil {
+		return nil, err
+	}
+
+	req, err := asn1.Marshal(timeStampReq{
+		Version: 1,
+		MessageImprint: messageImprint{
+			HashAlgorithm: pkix.AlgorithmIdentifier{Algorithm: oidSHA256},
+			HashedMessage: digest[:],
+		},
+		Nonce:   nonce,
+		CertReq: true,
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	httpResp, err := tsaClient.Post(url, "application/timestamp-query", bytes.NewReader(req))
+	if err != nil {
+		return nil, err
+	}
+	defer httpResp.Body.Close()
+
+	if httpResp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("TSA returned HTTP %d", httpResp.StatusCode)
+	}
+
+	body, err := io.ReadAll(io.LimitReader(httpResp.Body, 1<<20))
+	if err != nil {
+		return nil, err
+	}
+
+	var resp timeStampResp
+	if _, err := asn1.Unmarshal(body, &resp); err != nil {
+		return nil, fmt.Errorf("invalid TSA response: %w", err)
+	}
+	// 0 = granted, 1 = grantedWithMods
+	if resp.Status.Status > 1 {
+		return nil, fmt.Errorf("TSA rejected request with status %d", resp.Status.Status)
+	}
+	if len(resp.TimeStampToken.FullBytes) == 0 {
+		return nil, errors.New("TSA response contains no token")
+	}
+
+	// Make sure the token actually covers our signature
+	if _, err := verifyTimestamp(resp.TimeStampToken.FullBytes, signature); err != nil {
+		return nil, err
+	}
+
+	return resp.TimeStampToken.FullBytes, nil
+}
+
+// verifyTimestamp validates a timestamp token against the signature value it should cover
+func verifyTimestamp(token []byte, signature []byte) (time.Time, error) {
+	result, err := verifySignedData(token, nil)
+	if err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp token: %w", err)
+	}
+	if !result.contentType.Equal(oidTSTInfo) {
+		return time.Time{}, errors.New("timestamp token does not contain TSTInfo")
+	}
+
+	var info tstInfo
+	if _, err := asn1.Unmarshal(result.content, &info); err != nil {
+		return time.Time{}, fmt.Errorf("invalid timestamp info: %w", err)
+	}
+
+	hash, err := hashForOID(info.MessageImprint.HashAlgorithm.Algorithm)
+	if err != nil {
+		return time.Time{}, err
+	}
+	h := hash.New()
+	h.Write(signature)
+	if !bytes.Equal(h.Sum(nil), info.MessageImprint.HashedMessage) {
+		return time.Time{}, errors.New("timestamp does not cover the signature")
+	}
+
+	return info.GenTime, nil
+}
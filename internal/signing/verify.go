@@ -0,0 +1,97 @@
+package signing
+
+import (
+	"bytes"
+	"encoding/asn1"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// ErrNotSigned is returned when a PDF carries no signature
+var ErrNotSigned = errors.New("document is not signed")
+
+// Verification describes the outcome of validating a PDF signature
+type Verification struct {
+	Signer         string     `json:"signer" example:"CN=Zikzi"`
+	Issuer         string     `json:"issuer" example:"CN=Zikzi CA"`
+	Fingerprint    string     `json:"fingerprint" example:"3f2a..."`
+	Timestamped    bool       `json:"timestamped" example:"true"`
+	TimestampTime  *time.Time `json:"timestamp_time,omitempty"`
+	CoversDocument bool       `json:"covers_document" example:"true"` // false if the file was modified after signing
+}
+
+// Verify validates the last signature of a PDF document
+func Verify(pdf []byte) (*Verification, error) {
+	idx := bytes.LastIndex(pdf, []byte("/ByteRange"))
+	if idx < 0 {
+		return nil, ErrNotSigned
+	}
+
+	l := &lexer{data: pdf, pos: idx + len("/ByteRange")}
+	if !l.peek("[") {
+		return nil, errors.New("invalid /ByteRange")
+	}
+	l.pos++
+	var byteRange [4]int64
+	for i := range byteRange {
+		n, err := l.integer()
+		if err != nil {
+			return nil, errors.New("invalid /ByteRange")
+		}
+		byteRange[i] = n
+	}
+
+	size := int64(len(pdf))
+	if byteRange[0] != 0 || byteRange[1] <= 0 || byteRange[2] <= byteRange[1] || byteRange[2]+byteRange[3] > size {
+		return nil, errors.New("/ByteRange is out of bounds")
+	}
+
+	contents := strings.TrimSpace(string(pdf[byteRange[1]:byteRange[2]]))
+	if !strings.HasPrefix(contents, "<") || !strings.HasSuffix(contents, ">") {
+		return nil, errors.New("invalid signature /Contents")
+	}
+	der, err := hex.DecodeString(contents[1 : len(contents)-1])
+	if err != nil {
+		return nil, fmt.Errorf("invalid signature /Contents: %w", err)
+	}
+	// Strip the zero padding that follows the DER structure
+	var raw asn1.RawValue
+	if _, err := asn1.Unmarshal(der, &raw); err != nil {
+		return nil, fmt.Errorf("invalid signature: %w", err)
+	}
+	der = raw.FullBytes
+
+	signedContent := make([]byte, 0, byteRange[1]+byteRange[3])
+	signedContent = append(signedContent, pdf[:byteRange[1]]...)
+	signedContent = append(signedContent, pdf[byteRange[2]:byteRange[2]+byteRange[3]]...)
+
+	result, err := verifySignedData(der, signedContent)
+	if err != nil {
+		return nil, err
+	}
+
+	v := &Verification{
+		Signer:         result.certificate.Subject.String(),
+		Issuer:         result.certificate.Issuer.String(),
+		Fingerprint:    CertificateFingerprint(result.certificate),
+		CoversDocument: byteRange[2]+byteRange[3] == size,
+	}
+
+	if token, ok := findAttribute(result.unsignedAttrs, oidTimestampAttr); ok {
+		signature, err := signerValue(der)
+		if err != nil {
+			return nil, err
+		}
+		genTime, err := verifyTimestamp(token, signature)
+		if err != nil {
+			return nil, err
+		}
+		v.Timestamped = true
+		v.TimestampTime = &genTime
+	}
+
+	return v, nil
+}
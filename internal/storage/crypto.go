@@ -0,0 +1,362 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strings"
+
+	"github.com/alex4386/zikzi/internal/config"
+)
+
+// Encrypted files are a header followed by AES-GCM sealed chunks:
+//
+//	magic "ZKE1" | 7-byte random nonce prefix | chunk...
+//
+// Every chunk but the last holds exactly encChunkSize bytes of plaintext. The
+// last chunk holds fewer (possibly zero) and is sealed with a different nonce
+// flag, so truncating the file at a chunk boundary is detected.
+const (
+	encMagic      = "ZKE1"
+	encPrefixSize = 7
+	encHeaderSize = len(encMagic) + encPrefixSize
+	encChunkSize  = 64 * 1024
+	encOverhead   = 16 // GCM tag
+	dataKeySize   = 32
+	wrappedKeyAAD = "zikzi-data-key"
+)
+
+var (
+	// ErrNoMasterKey is returned when an encrypted job is read without a configured master key
+	ErrNoMasterKey = errors.New("job is encrypted but no master key is configured")
+	// ErrKeyMismatch is returned when a data key was wrapped by a master key that isn't configured
+	ErrKeyMismatch = errors.New("data key was wrapped by a different master key")
+)
+
+// Keyring wraps new data keys with the current master key and unwraps data
+// keys wrapped by the current or any previous master key, so the master key
+// can be rotated while jobs wrapped by the old one are still being re-wrapped.
+type Keyring struct {
+	id   string                 // ID of the current master key
+	keys map[string]cipher.AEAD // Current and previous master keys by ID
+}
+
+// NewKeyring loads the master key and previous master keys from the
+// configuration. Returns nil without error when encryption is disabled.
+func NewKeyring(cfg config.EncryptionConfig) (*Keyring, error) {
+	if !cfg.Enabled {
+		return nil, nil
+	}
+
+	key := cfg.Key
+	if cfg.KeyFile != "" {
+		data, err := os.ReadFile(cfg.KeyFile)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read master key file: %w", err)
+		}
+		key = string(data)
+	}
+	if key == "" {
+		return nil, errors.New("encryption enabled but no master key configured")
+	}
+
+	master, err := ParseMasterKey(key)
+	if err != nil {
+		return nil, err
+	}
+
+	var previous [][]byte
+	for _, key := range cfg.PreviousKeys {
+		prev, err := ParseMasterKey(key)
+		if err != nil {
+			return nil, fmt.Errorf("previous key: %w", err)
+		}
+		previous = append(previous, prev)
+	}
+	for _, path := range cfg.PreviousKeyFiles {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read previous master key file: %w", err)
+		}
+		prev, err := ParseMasterKey(string(data))
+		if err != nil {
+			return nil, fmt.Errorf("previous key %s: %w", path, err)
+		}
+		previous = append(previous, prev)
+	}
+
+	return NewKeyringFromKey(master, previous...)
+}
+
+// NewKeyringFromKey creates a keyring from a raw 32-byte master key and
+// optional previous master keys that are only used to unwrap data keys
+func NewKeyringFromKey(master []byte, previous ...[]byte) (*Keyring, error) {
+	k := &Keyring{keys: make(map[string]cipher.AEAD)}
+	for i, key := range append([][]byte{master}, previous...) {
+		aead, err := newGCM(key)
+		if err != nil {
+			return nil, err
+		}
+		id := masterKeyID(key)
+		if i == 0 {
+			k.id = id
+		}
+		k.keys[id] = aead
+	}
+	return k, nil
+}
+
+func masterKeyID(master []byte) string {
+	sum := sha256.Sum256(master)
+	return hex.EncodeToString(sum[:8])
+}
+
+// ParseMasterKey decodes a base64-encoded 256-bit master key
+func ParseMasterKey(s string) ([]byte, error) {
+	key, err := base64.StdEncoding.DecodeString(strings.TrimSpace(s))
+	if err != nil {
+		return nil, fmt.Errorf("invalid master key: %w", err)
+	}
+	if len(key) != dataKeySize {
+		return nil, fmt.Errorf("invalid master key: expected %d bytes, got %d", dataKeySize, len(key))
+	}
+	return key, nil
+}
+
+// GenerateMasterKey returns a new random base64-encoded master key
+func GenerateMasterKey() (string, error) {
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		return "", err
+	}
+	return base64.StdEncoding.EncodeToString(key), nil
+}
+
+// ID identifies the current master key without revealing it
+func (k *Keyring) ID() string {
+	return k.id
+}
+
+// Has reports whether the master key with the given ID is in the keyring
+func (k *Keyring) Has(id string) bool {
+	_, ok := k.keys[id]
+	return ok
+}
+
+// PreviousKeys returns how many previous master keys the keyring holds
+func (k *Keyring) PreviousKeys() int {
+	return len(k.keys) - 1
+}
+
+// NewDataKey generates a data key and returns it along with its wrapped form
+func (k *Keyring) NewDataKey() ([]byte, string, error) {
+	dek := make([]byte, dataKeySize)
+	if _, err := rand.Read(dek); err != nil {
+		return nil, "", err
+	}
+	wrapped, err := k.Wrap(dek)
+	if err != nil {
+		return nil, "", err
+	}
+	return dek, wrapped, nil
+}
+
+// Wrap encrypts a data key with the current master key
+func (k *Keyring) Wrap(dek []byte) (string, error) {
+	aead := k.keys[k.id]
+	nonce := make([]byte, aead.NonceSize())
+	if _, err := rand.Read(nonce); err != nil {
+		return "", err
+	}
+	sealed := aead.Seal(nonce, nonce, dek, []byte(wrappedKeyAAD))
+	return base64.StdEncoding.EncodeToString(sealed), nil
+}
+
+// Unwrap decrypts a data key wrapped by Wrap with the master key keyID. An
+// empty keyID tries every key in the keyring.
+func (k *Keyring) Unwrap(keyID, wrapped string) ([]byte, error) {
+	sealed, err := base64.StdEncoding.DecodeString(wrapped)
+	if err != nil {
+		return nil, errors.New("invalid wrapped data key")
+	}
+
+	candidates := k.keys
+	if keyID != "" {
+		aead, ok := k.keys[keyID]
+		if !ok {
+			return nil, fmt.Errorf("%w: master key %s is not configured", ErrKeyMismatch, keyID)
+		}
+		candidates = map[string]cipher.AEAD{keyID: aead}
+	}
+	for _, aead := range candidates {
+		if len(sealed) < aead.NonceSize() {
+			return nil, errors.New("invalid wrapped data key")
+		}
+		nonce, ciphertext := sealed[:aead.NonceSize()], sealed[aead.NonceSize():]
+		if dek, err := aead.Open(nil, nonce, ciphertext, []byte(wrappedKeyAAD)); err == nil {
+			return dek, nil
+		}
+	}
+	return nil, ErrKeyMismatch
+}
+
+// Rewrap re-wraps a data key wrapped by any master key in the keyring with
+// the current one
+func (k *Keyring) Rewrap(keyID, wrapped string) (string, error) {
+	dek, err := k.Unwrap(keyID, wrapped)
+	if err != nil {
+		return "", err
+	}
+	return k.Wrap(dek)
+}
+
+func newGCM(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// EncryptedSize returns the size of the ciphertext for a plaintext of n bytes
+func EncryptedSize(n int64) int64 {
+	return int64(encHeaderSize) + n + encOverhead*(n/encChunkSize+1)
+}
+
+// DecryptedSize returns the plaintext size for a ciphertext of n bytes
+func DecryptedSize(n int64) int64 {
+	n -= int64(encHeaderSize)
+	chunks := n/(encChunkSize+encOverhead) + 1
+	return n - encOverhead*chunks
+}
+
+func chunkNonce(prefix []byte, counter uint32, last bool) []byte {
+	nonce := make([]byte, 12)
+	copy(nonce, prefix)
+	binary.BigEndian.PutUint32(nonce[encPrefixSize:], counter)
+	if last {
+		nonce[11] = 1
+	}
+	return nonce
+}
+
+// encryptReader produces the encrypted form of a plaintext stream
+type encryptReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	buf     bytes.Buffer
+	plain   []byte
+	done    bool
+}
+
+// NewEncryptReader returns a reader yielding the encrypted form of r
+func NewEncryptReader(r io.Reader, dek []byte) (io.Reader, error) {
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+	prefix := make([]byte, encPrefixSize)
+	if _, err := rand.Read(prefix); err != nil {
+		return nil, err
+	}
+
+	e := &encryptReader{src: r, aead: aead, prefix: prefix, plain: make([]byte, encChunkSize)}
+	e.buf.WriteString(encMagic)
+	e.buf.Write(prefix)
+	return e, nil
+}
+
+func (e *encryptReader) Read(p []byte) (int, error) {
+	for e.buf.Len() == 0 {
+		if e.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(e.src, e.plain)
+		last := false
+		switch err {
+		case nil:
+		case io.EOF, io.ErrUnexpectedEOF:
+			last = true
+		default:
+			return 0, err
+		}
+		if e.counter == ^uint32(0) {
+			return 0, errors.New("file too large to encrypt")
+		}
+		e.buf.Write(e.aead.Seal(nil, chunkNonce(e.prefix, e.counter, last), e.plain[:n], nil))
+		e.counter++
+		e.done = last
+	}
+	return e.buf.Read(p)
+}
+
+// decryptReader decrypts a stream written by encryptReader
+type decryptReader struct {
+	src     io.Reader
+	aead    cipher.AEAD
+	prefix  []byte
+	counter uint32
+	chunk   []byte
+	buf     []byte
+	done    bool
+}
+
+// NewDecryptReader returns a reader yielding the plaintext of an encrypted stream
+func NewDecryptReader(r io.Reader, dek []byte) (io.Reader, error) {
+	aead, err := newGCM(dek)
+	if err != nil {
+		return nil, err
+	}
+
+	header := make([]byte, encHeaderSize)
+	if _, err := io.ReadFull(r, header); err != nil || string(header[:len(encMagic)]) != encMagic {
+		return nil, errors.New("not an encrypted job file")
+	}
+
+	return &decryptReader{
+		src:    r,
+		aead:   aead,
+		prefix: header[len(encMagic):],
+		chunk:  make([]byte, encChunkSize+encOverhead),
+	}, nil
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for len(d.buf) == 0 {
+		if d.done {
+			return 0, io.EOF
+		}
+		n, err := io.ReadFull(d.src, d.chunk)
+		last := false
+		switch err {
+		case nil:
+		case io.ErrUnexpectedEOF:
+			last = true
+		case io.EOF:
+			return 0, errors.New("encrypted job file is truncated")
+		default:
+			return 0, err
+		}
+		plain, err := d.aead.Open(d.chunk[:0], chunkNonce(d.prefix, d.counter, last), d.chunk[:n], nil)
+		if err != nil {
+			return 0, errors.New("encrypted job file is corrupted")
+		}
+		d.counter++
+		d.buf = plain
+		d.done = last
+	}
+	n := copy(p, d.buf)
+	d.buf = d.buf[n:]
+	return n, nil
+}
@@ -0,0 +1,205 @@
+package storage
+
+import (
+	"bytes"
+	"crypto/rand"
+	"errors"
+	"io"
+	"strings"
+	"testing"
+)
+
+func testKey(t *testing.T) []byte {
+	t.Helper()
+
+	key := make([]byte, dataKeySize)
+	if _, err := rand.Read(key); err != nil {
+		t.Fatal(err)
+	}
+	return key
+}
+
+func encrypt(t *testing.T, plain, dek []byte) []byte {
+	t.Helper()
+
+	r, err := NewEncryptReader(bytes.NewReader(plain), dek)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := io.ReadAll(r)
+	if err != nil {
+		t.Fatalf("encrypt: %v", err)
+	}
+	return data
+}
+
+func decrypt(data, dek []byte) ([]byte, error) {
+	r, err := NewDecryptReader(bytes.NewReader(data), dek)
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
+
+func TestEncryptRoundTrip(t *testing.T) {
+	dek := testKey(t)
+
+	tests := []struct {
+		name string
+		size int
+	}{
+		{"empty", 0},
+		{"one byte", 1},
+		{"one chunk minus one", encChunkSize - 1},
+		{"exactly one chunk", encChunkSize},
+		{"one chunk plus one", encChunkSize + 1},
+		{"multi chunk", 3*encChunkSize + 123},
+		{"exactly three chunks", 3 * encChunkSize},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			plain := make([]byte, tt.size)
+			rand.Read(plain)
+
+			data := encrypt(t, plain, dek)
+			if got, want := int64(len(data)), EncryptedSize(int64(tt.size)); got != want {
+				t.Errorf("len(ciphertext) = %d, EncryptedSize = %d", got, want)
+			}
+			if got := DecryptedSize(int64(len(data))); got != int64(tt.size) {
+				t.Errorf("DecryptedSize = %d, want %d", got, tt.size)
+			}
+
+			got, err := decrypt(data, dek)
+			if err != nil {
+				t.Fatalf("decrypt: %v", err)
+			}
+			if !bytes.Equal(got, plain) {
+				t.Fatal("decrypted plaintext differs from the original")
+			}
+		})
+	}
+}
+
+func TestDecryptTampered(t *testing.T) {
+	dek := testKey(t)
+	plain := make([]byte, 2*encChunkSize+100)
+	rand.Read(plain)
+	data := encrypt(t, plain, dek)
+
+	sealedChunk := encChunkSize + encOverhead
+	chunk := func(i int) []byte {
+		start := encHeaderSize + i*sealedChunk
+		return data[start : start+sealedChunk]
+	}
+
+	tests := []struct {
+		name    string
+		data    func() []byte
+		dek     []byte
+		wantErr string
+	}{
+		{"truncated at chunk boundary", func() []byte {
+			return bytes.Clone(data[:encHeaderSize+2*sealedChunk])
+		}, dek, "truncated"},
+		{"truncated mid chunk", func() []byte {
+			return bytes.Clone(data[:encHeaderSize+sealedChunk+100])
+		}, dek, "corrupted"},
+		{"last chunk dropped", func() []byte {
+			return bytes.Clone(data[:encHeaderSize+sealedChunk])
+		}, dek, "truncated"},
+		{"chunks reordered", func() []byte {
+			out := bytes.Clone(data[:encHeaderSize])
+			out = append(out, chunk(1)...)
+			out = append(out, chunk(0)...)
+			return append(out, data[encHeaderSize+2*sealedChunk:]...)
+		}, dek, "corrupted"},
+		{"flipped byte", func() []byte {
+			out := bytes.Clone(data)
+			out[encHeaderSize+sealedChunk+10] ^= 1
+			return out
+		}, dek, "corrupted"},
+		{"wrong key", func() []byte {
+			return bytes.Clone(data)
+		}, testKey(t), "corrupted"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			_, err := decrypt(tt.data(), tt.dek)
+			if err == nil {
+				t.Fatal("decrypt succeeded on a tampered file")
+			}
+			if !strings.Contains(err.Error(), tt.wantErr) {
+				t.Errorf("error = %q, want it to mention %q", err, tt.wantErr)
+			}
+		})
+	}
+
+	t.Run("not encrypted", func(t *testing.T) {
+		if _, err := decrypt([]byte("%PDF-1.4"), dek); err == nil {
+			t.Fatal("decrypt succeeded on a plaintext file")
+		}
+	})
+}
+
+func TestKeyringRotation(t *testing.T) {
+	oldKey, newKey := testKey(t), testKey(t)
+
+	before, err := NewKeyringFromKey(oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dek, wrapped, err := before.NewDataKey()
+	if err != nil {
+		t.Fatal(err)
+	}
+	oldID := before.ID()
+
+	after, err := NewKeyringFromKey(newKey, oldKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if after.ID() == oldID {
+		t.Fatal("new master key has the same ID as the old one")
+	}
+	if !after.Has(oldID) || after.PreviousKeys() != 1 {
+		t.Fatalf("keyring doesn't hold the previous key")
+	}
+
+	// Jobs wrapped before the rotation stay readable by key ID or by trying every key
+	for _, keyID := range []string{oldID, ""} {
+		got, err := after.Unwrap(keyID, wrapped)
+		if err != nil {
+			t.Fatalf("Unwrap(%q): %v", keyID, err)
+		}
+		if !bytes.Equal(got, dek) {
+			t.Fatalf("Unwrap(%q) returned a different data key", keyID)
+		}
+	}
+
+	rewrapped, err := after.Rewrap(oldID, wrapped)
+	if err != nil {
+		t.Fatalf("Rewrap: %v", err)
+	}
+	if _, err := after.Unwrap(oldID, rewrapped); err == nil {
+		t.Error("re-wrapped data key still opens with the old master key")
+	}
+
+	// Once the old key is dropped, only the re-wrapped data key opens
+	current, err := NewKeyringFromKey(newKey)
+	if err != nil {
+		t.Fatal(err)
+	}
+	got, err := current.Unwrap(current.ID(), rewrapped)
+	if err != nil {
+		t.Fatalf("Unwrap(rewrapped): %v", err)
+	}
+	if !bytes.Equal(got, dek) {
+		t.Fatal("re-wrapped data key differs from the original")
+	}
+	if _, err := current.Unwrap(oldID, wrapped); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("Unwrap with a removed key error = %v, want ErrKeyMismatch", err)
+	}
+	if _, err := current.Unwrap("", wrapped); !errors.Is(err, ErrKeyMismatch) {
+		t.Errorf("Unwrap without key ID error = %v, want ErrKeyMismatch", err)
+	}
+}
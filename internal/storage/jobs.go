@@ -0,0 +1,190 @@
+package storage
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
+	"os"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/models"
+)
+
+// JobStore reads and writes the files of print jobs. When a keyring is
+// configured, files are encrypted with a per-job data key that is stored
+// (wrapped by the master key) on the job record.
+type JobStore struct {
+	backend Backend
+	keyring *Keyring
+}
+
+// NewJobStore creates a job store. keyring may be nil when encryption is disabled.
+func NewJobStore(backend Backend, keyring *Keyring) *JobStore {
+	return &JobStore{backend: backend, keyring: keyring}
+}
+
+// Backend returns the underlying storage backend
+func (s *JobStore) Backend() Backend {
+	return s.backend
+}
+
+// dataKey returns the job's data key, creating one if the job has none yet.
+// Returns nil for jobs that are stored unencrypted.
+func (s *JobStore) dataKey(job *models.PrintJob, create bool) ([]byte, error) {
+	if job.DataKey == "" {
+		if !create || s.keyring == nil {
+			return nil, nil
+		}
+		dek, wrapped, err := s.keyring.NewDataKey()
+		if err != nil {
+			return nil, err
+		}
+		job.DataKey = wrapped
+		job.KeyID = s.keyring.ID()
+		return dek, nil
+	}
+
+	if s.keyring == nil {
+		return nil, ErrNoMasterKey
+	}
+	return s.keyring.Unwrap(job.KeyID, job.DataKey)
+}
+
+// Put stores a job file. New files of a job without a data key are encrypted
+// with a fresh key, so the caller must save the job afterwards.
+func (s *JobStore) Put(ctx context.Context, job *models.PrintJob, key string, r io.Reader, size int64) error {
+	dek, err := s.dataKey(job, true)
+	if err != nil {
+		return err
+	}
+
+	if dek != nil {
+		if r, err = NewEncryptReader(r, dek); err != nil {
+			return err
+		}
+		if size >= 0 {
+			size = EncryptedSize(size)
+		}
+	}
+
+	return s.backend.Put(ctx, key, r, size)
+}
+
+// PutFile stores a local file as a job file
+func (s *JobStore) PutFile(ctx context.Context, job *models.PrintJob, key, localPath string) error {
+	file, err := os.Open(localPath)
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+
+	stat, err := file.Stat()
+	if err != nil {
+		return err
+	}
+
+	return s.Put(ctx, job, key, file, stat.Size())
+}
+
+// Open opens a job file for reading, decrypting it if needed
+func (s *JobStore) Open(ctx context.Context, job *models.PrintJob, key string) (io.ReadCloser, error) {
+	dek, err := s.dataKey(job, false)
+	if err != nil {
+		return nil, err
+	}
+
+	reader, err := s.backend.Get(ctx, key)
+	if err != nil || dek == nil {
+		return reader, err
+	}
+
+	plain, err := NewDecryptReader(reader, dek)
+	if err != nil {
+		reader.Close()
+		return nil, err
+	}
+	return struct {
+		io.Reader
+		io.Closer
+	}{plain, reader}, nil
+}
+
+// Size returns the plaintext size of a job file
+func (s *JobStore) Size(ctx context.Context, job *models.PrintJob, key string) (int64, error) {
+	info, err := s.backend.Stat(ctx, key)
+	if err != nil {
+		return 0, err
+	}
+	if job.DataKey != "" {
+		return DecryptedSize(info.Size), nil
+	}
+	return info.Size, nil
+}
+
+// LocalPath returns the filesystem path of a job file when it is stored
+// unencrypted on the local backend
+func (s *JobStore) LocalPath(job *models.PrintJob, key string) (string, bool) {
+	local, ok := s.backend.(*Local)
+	if !ok || job.DataKey != "" {
+		return "", false
+	}
+	return local.path(key), true
+}
+
+// Presign returns a direct download URL. Encrypted files cannot be presigned.
+func (s *JobStore) Presign(ctx context.Context, job *models.PrintJob, key string, expires time.Duration) (string, error) {
+	if job.DataKey != "" {
+		return "", ErrPresignNotSupported
+	}
+	return s.backend.Presign(ctx, key, expires)
+}
+
+// Download copies a job file into a local file
+func (s *JobStore) Download(ctx context.Context, job *models.PrintJob, key, localPath string) error {
+	reader, err := s.Open(ctx, job, key)
+	if err != nil {
+		return err
+	}
+	defer reader.Close()
+
+	file, err := os.Create(localPath)
+	if err != nil {
+		return err
+	}
+
+	if _, err := io.Copy(file, reader); err != nil {
+		file.Close()
+		return err
+	}
+	return file.Close()
+}
+
+// Hash returns the hex-encoded SHA-256 checksum of a job file's content
+func (s *JobStore) Hash(ctx context.Context, job *models.PrintJob, key string) (string, error) {
+	reader, err := s.Open(ctx, job, key)
+	if err != nil {
+		return "", err
+	}
+	defer reader.Close()
+
+	h := sha256.New()
+	if _, err := io.Copy(h, reader); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
+}
+
+// Delete removes all files associated with a print job
+func (s *JobStore) Delete(ctx context.Context, job *models.PrintJob) []error {
+	var errs []error
+	for _, key := range []string{job.OriginalFile, job.PDFFile, job.ThumbnailFile} {
+		if key == "" {
+			continue
+		}
+		if err := s.backend.Delete(ctx, key); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	return errs
+}
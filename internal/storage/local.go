@@ -0,0 +1,149 @@
+package storage
+
+import (
+	"context"
+	"io"
+	"io/fs"
+	"os"
+	"path/filepath"
+	"strings"
+	"time"
+)
+
+// Local stores objects on the local filesystem below a root directory
+type Local struct {
+	root string
+}
+
+// NewLocal creates a filesystem backend rooted at root
+func NewLocal(root string) *Local {
+	return &Local{root: filepath.Clean(root)}
+}
+
+// path maps a key to a filesystem path
+func (l *Local) path(key string) string {
+	// Jobs stored before storage backends existed hold full paths instead of keys
+	clean := filepath.Clean(filepath.FromSlash(key))
+	if filepath.IsAbs(clean) || strings.HasPrefix(clean, l.root+string(filepath.Separator)) {
+		return clean
+	}
+
+	// Anchor relative keys so they cannot escape the root
+	return filepath.Join(l.root, filepath.Clean(string(filepath.Separator)+clean))
+}
+
+// canonical returns the root-relative, slash-separated key of a stored path
+func (l *Local) canonical(key string) string {
+	root, err := filepath.Abs(l.root)
+	if err != nil {
+		return key
+	}
+	target, err := filepath.Abs(l.path(key))
+	if err != nil {
+		return key
+	}
+	rel, err := filepath.Rel(root, target)
+	if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+		return key
+	}
+	return filepath.ToSlash(rel)
+}
+
+func (l *Local) Put(ctx context.Context, key string, r io.Reader, size int64) error {
+	target := l.path(key)
+	dir := filepath.Dir(target)
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return err
+	}
+
+	// Write to a temporary file first so readers never see partial content
+	tmp, err := os.CreateTemp(dir, ".upload-*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(tmp.Name())
+
+	if _, err := io.Copy(tmp, r); err != nil {
+		tmp.Close()
+		return err
+	}
+	if err := tmp.Close(); err != nil {
+		return err
+	}
+	if err := os.Chmod(tmp.Name(), 0644); err != nil {
+		return err
+	}
+
+	return os.Rename(tmp.Name(), target)
+}
+
+func (l *Local) Get(ctx context.Context, key string) (io.ReadCloser, error) {
+	file, err := os.Open(l.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	return file, err
+}
+
+func (l *Local) Stat(ctx context.Context, key string) (*ObjectInfo, error) {
+	stat, err := os.Stat(l.path(key))
+	if os.IsNotExist(err) {
+		return nil, ErrNotFound
+	}
+	if err != nil {
+		return nil, err
+	}
+	return &ObjectInfo{Key: key, Size: stat.Size(), ModTime: stat.ModTime()}, nil
+}
+
+func (l *Local) Delete(ctx context.Context, key string) error {
+	if err := os.Remove(l.path(key)); err != nil && !os.IsNotExist(err) {
+		return err
+	}
+	return nil
+}
+
+func (l *Local) List(ctx context.Context, prefix string) ([]ObjectInfo, error) {
+	// Only walk the directory the prefix points into
+	start := l.root
+	if i := strings.LastIndex(prefix, "/"); i >= 0 {
+		start = filepath.Join(l.root, filepath.Clean(string(filepath.Separator)+filepath.FromSlash(prefix[:i])))
+	}
+
+	var objects []ObjectInfo
+	err := filepath.WalkDir(start, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		if d.IsDir() {
+			return ctx.Err()
+		}
+
+		rel, err := filepath.Rel(l.root, p)
+		if err != nil {
+			return err
+		}
+		key := filepath.ToSlash(rel)
+		if !strings.HasPrefix(key, prefix) {
+			return nil
+		}
+
+		info, err := d.Info()
+		if err != nil {
+			if os.IsNotExist(err) {
+				return nil
+			}
+			return err
+		}
+		objects = append(objects, ObjectInfo{Key: key, Size: info.Size(), ModTime: info.ModTime()})
+		return nil
+	})
+	return objects, err
+}
+
+func (l *Local) Presign(ctx context.Context, key string, expires time.Duration) (string, error) {
+	return "", ErrPresignNotSupported
+}
@@ -0,0 +1,68 @@
+package storage
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+)
+
+var (
+	// ErrNotFound is returned when an object does not exist
+	ErrNotFound = errors.New("object not found")
+	// ErrPresignNotSupported is returned by backends that cannot hand out direct URLs
+	ErrPresignNotSupported = errors.New("presigned URLs are not supported by this backend")
+)
+
+// ObjectInfo describes a stored object
+type ObjectInfo struct {
+	Key     string
+	Size    int64
+	ModTime time.Time
+}
+
+// Backend stores job files addressed by slash-separated keys (e.g. "jobs/abc123.pdf")
+type Backend interface {
+	// Put stores the content of r under key. size may be -1 if unknown.
+	Put(ctx context.Context, key string, r io.Reader, size int64) error
+	// Get opens the object for reading. The caller must close it.
+	Get(ctx context.Context, key string) (io.ReadCloser, error)
+	// Stat returns object metadata
+	Stat(ctx context.Context, key string) (*ObjectInfo, error)
+	// Delete removes the object. Deleting a missing object is not an error.
+	Delete(ctx context.Context, key string) error
+	// List returns all objects whose keys start with prefix
+	List(ctx context.Context, prefix string) ([]ObjectInfo, error)
+	// Presign returns a time-limited URL for downloading the object directly
+	Presign(ctx context.Context, key string, expires time.Duration) (string, error)
+}
+
+// New creates the storage backend selected in the configuration
+func New(cfg config.StorageConfig) (Backend, error) {
+	switch cfg.Backend {
+	case "", "local":
+		return NewLocal(cfg.Path), nil
+	case "s3":
+		return NewS3(cfg.S3)
+	default:
+		return nil, fmt.Errorf("unknown storage backend: %s", cfg.Backend)
+	}
+}
+
+// JobKey returns the key for a job file
+func JobKey(filename string) string {
+	return path.Join("jobs", filename)
+}
+
+// CanonicalKey maps a key stored on a job to the key List reports for the same object
+func CanonicalKey(b Backend, key string) string {
+	if l, ok := b.(*Local); ok {
+		return l.canonical(key)
+	}
+	return strings.TrimPrefix(path.Clean("/"+key), "/")
+}
@@ -0,0 +1,55 @@
+package tagging
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/alex4386/zikzi/internal/models"
+	"gorm.io/gorm"
+)
+
+// ValidateRule checks that a rule's field is known and its pattern compiles
+func ValidateRule(field, pattern string) error {
+	if _, ok := (&models.PrintJob{}).FieldValue(field); !ok {
+		return fmt.Errorf("unknown field: %s", field)
+	}
+	if _, err := regexp.Compile(pattern); err != nil {
+		return fmt.Errorf("invalid pattern: %w", err)
+	}
+	return nil
+}
+
+// Apply attaches the tags of all enabled rules of the job's owner that match the job
+func Apply(db *gorm.DB, job *models.PrintJob) error {
+	if job.UserID == "" {
+		return nil
+	}
+
+	var rules []models.TagRule
+	if err := db.Preload("Tag").Where("user_id = ? AND enabled = ?", job.UserID, true).Find(&rules).Error; err != nil {
+		return err
+	}
+
+	var tags []models.Tag
+	seen := make(map[string]bool)
+	for _, rule := range rules {
+		if rule.Tag == nil || seen[rule.TagID] {
+			continue
+		}
+		value, ok := job.FieldValue(rule.Field)
+		if !ok {
+			continue
+		}
+		re, err := regexp.Compile(rule.Pattern)
+		if err != nil || !re.MatchString(value) {
+			continue
+		}
+		seen[rule.TagID] = true
+		tags = append(tags, *rule.Tag)
+	}
+
+	if len(tags) == 0 {
+		return nil
+	}
+	return db.Model(job).Association("Tags").Append(&tags)
+}
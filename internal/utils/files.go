@@ -1,27 +1,27 @@
 package utils
 
 import (
+	"crypto/sha256"
+	"encoding/hex"
+	"io"
 	"os"
 	"path/filepath"
+	"strings"
 )
 
-// DeleteJobFiles removes all files associated with a print job
-func DeleteJobFiles(originalFile, pdfFile, thumbnailFile string) []error {
-	var errors []error
-
-	files := []string{originalFile, pdfFile, thumbnailFile}
-
-	for _, file := range files {
-		if file == "" {
-			continue
-		}
-
-		if err := os.Remove(file); err != nil && !os.IsNotExist(err) {
-			errors = append(errors, err)
-		}
+// HashFile returns the hex-encoded SHA-256 checksum of a file
+func HashFile(path string) (string, error) {
+	file, err := os.Open(path)
+	if err != nil {
+		return "", err
 	}
+	defer file.Close()
 
-	return errors
+	h := sha256.New()
+	if _, err := io.Copy(h, file); err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(h.Sum(nil)), nil
 }
 
 // EnsureDir creates a directory if it doesn't exist
@@ -52,3 +52,17 @@ func SanitizeFilename(name string) string {
 
 	return string(sanitized)
 }
+
+// IsFileExtension reports whether ext looks like a file type suffix ("Report.docx")
+// rather than part of the name ("Minutes 2026.01")
+func IsFileExtension(ext string) bool {
+	if len(ext) < 2 || len(ext) > 6 {
+		return false
+	}
+	for _, r := range ext[1:] {
+		if (r < 'a' || r > 'z') && (r < 'A' || r > 'Z') && (r < '0' || r > '9') {
+			return false
+		}
+	}
+	return strings.IndexFunc(ext[1:], func(r rune) bool { return r < '0' || r > '9' }) >= 0
+}
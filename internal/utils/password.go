@@ -2,13 +2,21 @@ package utils
 
 import (
 	"crypto/md5"
+	"crypto/sha256"
+	"crypto/sha512"
 	"encoding/hex"
+	"hash"
+	"sync"
 
 	"golang.org/x/crypto/bcrypt"
 )
 
 const bcryptCost = 12
 
+// tokenBcryptCost is lower than bcryptCost because generated tokens carry far
+// more entropy than passwords and are checked on every IPP request
+const tokenBcryptCost = 8
+
 // HashPassword generates a bcrypt hash of the password
 func HashPassword(password string) (string, error) {
 	bytes, err := bcrypt.GenerateFromPassword([]byte(password), bcryptCost)
@@ -18,14 +26,62 @@ func HashPassword(password string) (string, error) {
 	return string(bytes), nil
 }
 
+// HashToken generates a bcrypt hash of a generated token
+func HashToken(token string) (string, error) {
+	bytes, err := bcrypt.GenerateFromPassword([]byte(token), tokenBcryptCost)
+	if err != nil {
+		return "", err
+	}
+	return string(bytes), nil
+}
+
 // VerifyPassword checks if the provided password matches the hash
 func VerifyPassword(hash, password string) bool {
 	err := bcrypt.CompareHashAndPassword([]byte(hash), []byte(password))
 	return err == nil
 }
 
-// ComputeDigestHA1 computes MD5(username:realm:password) for HTTP Digest authentication
-func ComputeDigestHA1(username, realm, password string) string {
-	h := md5.Sum([]byte(username + ":" + realm + ":" + password))
-	return hex.EncodeToString(h[:])
+// dummyPasswordHash is a password hash that no login is checked against
+var dummyPasswordHash = sync.OnceValue(func() []byte {
+	hash, _ := bcrypt.GenerateFromPassword([]byte("zikzi"), bcryptCost)
+	return hash
+})
+
+// VerifyDummyPassword takes as long as VerifyPassword without accepting
+// anything, so logins to unknown accounts can't be told apart by timing
+func VerifyDummyPassword(password string) {
+	bcrypt.CompareHashAndPassword(dummyPasswordHash(), []byte(password))
+}
+
+// HTTP Digest authentication algorithms (RFC 7616)
+const (
+	DigestMD5       = "MD5"
+	DigestSHA256    = "SHA-256"
+	DigestSHA512256 = "SHA-512-256"
+)
+
+// DigestAlgorithms lists the supported Digest algorithms
+var DigestAlgorithms = []string{DigestSHA256, DigestSHA512256, DigestMD5}
+
+// DigestHash hashes data with a Digest algorithm, returning it hex encoded.
+// Unknown algorithms return an empty string.
+func DigestHash(algorithm string, data []byte) string {
+	var h hash.Hash
+	switch algorithm {
+	case DigestMD5:
+		h = md5.New()
+	case DigestSHA256:
+		h = sha256.New()
+	case DigestSHA512256:
+		h = sha512.New512_256()
+	default:
+		return ""
+	}
+	h.Write(data)
+	return hex.EncodeToString(h.Sum(nil))
+}
+
+// ComputeDigestHA1With computes H(username:realm:password) with the given Digest algorithm
+func ComputeDigestHA1With(algorithm, username, realm, password string) string {
+	return DigestHash(algorithm, []byte(username+":"+realm+":"+password))
 }
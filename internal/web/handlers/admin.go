@@ -264,6 +264,11 @@ type AdminUpdateUserRequest struct {
 	Email       string `json:"email" binding:"omitempty,email" example:"john@example.com"`
 	DisplayName string `json:"display_name" example:"John Doe"`
 	IsAdmin     *bool  `json:"is_admin" example:"false"`
+
+	// Quota overrides: 0 uses the configured default, -1 is unlimited
+	QuotaBytes         *int64 `json:"quota_bytes" example:"1073741824"`
+	QuotaJobsPerDay    *int   `json:"quota_jobs_per_day" example:"50"`
+	QuotaPagesPerMonth *int   `json:"quota_pages_per_month" example:"-1"`
 }
 
 // ChangePasswordRequest represents the request to change a user's password
@@ -380,6 +385,15 @@ func (h *AdminHandler) UpdateUser(c *gin.Context) {
 	if req.IsAdmin != nil {
 		user.IsAdmin = *req.IsAdmin
 	}
+	if req.QuotaBytes != nil {
+		user.QuotaBytes = *req.QuotaBytes
+	}
+	if req.QuotaJobsPerDay != nil {
+		user.QuotaJobsPerDay = *req.QuotaJobsPerDay
+	}
+	if req.QuotaPagesPerMonth != nil {
+		user.QuotaPagesPerMonth = *req.QuotaPagesPerMonth
+	}
 
 	if err := h.db.Save(&user).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update user"})
@@ -2,6 +2,7 @@ package handlers
 
 import (
 	"net/http"
+	"strconv"
 	"strings"
 	"time"
 
@@ -19,14 +20,16 @@ type AuthHandler struct {
 	config       config.AuthConfig
 	db           *gorm.DB
 	oidcProvider *auth.OIDCProvider
+	limiter      *auth.Limiter // nil when lockouts are disabled
 }
 
-func NewAuthHandler(cfg config.AuthConfig, db *gorm.DB) *AuthHandler {
+func NewAuthHandler(cfg config.AuthConfig, db *gorm.DB, limiter *auth.Limiter) *AuthHandler {
 	oidcProvider, _ := auth.NewOIDCProvider(cfg.OIDC)
 	return &AuthHandler{
 		config:       cfg,
 		db:           db,
 		oidcProvider: oidcProvider,
+		limiter:      limiter,
 	}
 }
 
@@ -72,6 +75,7 @@ type ErrorResponse struct {
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
 // @Failure 403 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
 // @Router /auth/login [post]
 func (h *AuthHandler) Login(c *gin.Context) {
 	if !h.config.AllowLocal {
@@ -85,16 +89,27 @@ func (h *AuthHandler) Login(c *gin.Context) {
 		return
 	}
 
+	ip := c.ClientIP()
+	if until, locked := h.limiter.Check(req.Username, ip); locked {
+		c.Header("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+		c.JSON(http.StatusTooManyRequests, gin.H{"error": "too many failed login attempts"})
+		return
+	}
+
 	var user models.User
 	if err := h.db.Where("username = ?", req.Username).First(&user).Error; err != nil {
+		utils.VerifyDummyPassword(req.Password)
+		h.limiter.Fail("web", req.Username, ip, "unknown_user")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
 
 	if !utils.VerifyPassword(user.PasswordHash, req.Password) {
+		h.limiter.Fail("web", req.Username, ip, "bad_password")
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid credentials"})
 		return
 	}
+	h.limiter.Succeed(req.Username)
 
 	token, err := h.generateToken(&user)
 	if err != nil {
@@ -299,7 +314,7 @@ func (h *AuthHandler) RefreshToken(c *gin.Context) {
 		return []byte(h.config.JWTSecret), nil
 	})
 
-	if err != nil || !token.Valid {
+	if err != nil || !token.Valid || claims.Scope != "" {
 		c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid refresh token"})
 		return
 	}
@@ -0,0 +1,125 @@
+package handlers
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/web/middleware"
+	"github.com/gin-gonic/gin"
+	"github.com/golang-jwt/jwt/v5"
+)
+
+// eventKeepAlive is how often a comment is sent on idle streams so proxies keep them open
+const eventKeepAlive = 30 * time.Second
+
+// streamTokenLifetime is how long a stream token can be used to open a stream.
+// Open streams aren't closed when it expires.
+const streamTokenLifetime = time.Minute
+
+type EventHandler struct {
+	bus    *events.Bus
+	config config.AuthConfig
+}
+
+func NewEventHandler(bus *events.Bus, cfg config.AuthConfig) *EventHandler {
+	return &EventHandler{bus: bus, config: cfg}
+}
+
+// StreamTokenResponse is a token for opening an event stream
+type StreamTokenResponse struct {
+	Token     string `json:"token" example:"eyJhbGciOiJIUzI1NiIs..."`
+	ExpiresIn int64  `json:"expires_in" example:"60"`
+}
+
+// CreateStreamToken issues a short-lived token for the access_token query
+// parameter of the event stream
+// @Summary Create stream token
+// @Description Create a token that can only open the event stream, and only within a minute. EventSource clients pass it in the access_token query parameter instead of their login token, which would end up in access logs. Get a new one before reconnecting.
+// @Tags events
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} StreamTokenResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /events/token [post]
+func (h *EventHandler) CreateStreamToken(c *gin.Context) {
+	now := time.Now()
+	claims := middleware.Claims{
+		UserID:  middleware.GetUserID(c),
+		IsAdmin: middleware.IsAdmin(c),
+		Scope:   middleware.ScopeStream,
+		RegisteredClaims: jwt.RegisteredClaims{
+			ExpiresAt: jwt.NewNumericDate(now.Add(streamTokenLifetime)),
+			IssuedAt:  jwt.NewNumericDate(now),
+		},
+	}
+
+	token, err := jwt.NewWithClaims(jwt.SigningMethodHS256, claims).SignedString([]byte(h.config.JWTSecret))
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, StreamTokenResponse{
+		Token:     token,
+		ExpiresIn: int64(streamTokenLifetime / time.Second),
+	})
+}
+
+// StreamEvents streams job lifecycle events as Server-Sent Events
+// @Summary Stream job events
+// @Description Stream job.created, job.processing, job.completed, job.failed and job.deleted events as Server-Sent Events. Users receive events for their own jobs, admins for all jobs. EventSource clients can pass a token from POST /events/token in the access_token query parameter. Recent events missed while disconnected are replayed after Last-Event-ID.
+// @Tags events
+// @Produce text/event-stream
+// @Security BearerAuth
+// @Param access_token query string false "Stream token, for clients that cannot set the Authorization header"
+// @Param Last-Event-ID header int false "ID of the last event received"
+// @Success 200 {object} events.Event
+// @Failure 401 {object} ErrorResponse
+// @Router /events [get]
+func (h *EventHandler) StreamEvents(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	isAdmin := middleware.IsAdmin(c)
+
+	lastID, _ := strconv.ParseUint(c.GetHeader("Last-Event-ID"), 10, 64)
+	sub := h.bus.Subscribe(lastID)
+	defer sub.Close()
+
+	c.Header("Content-Type", "text/event-stream")
+	c.Header("Cache-Control", "no-cache")
+	c.Header("Connection", "keep-alive")
+	c.Header("X-Accel-Buffering", "no") // Disable nginx response buffering
+	c.Status(http.StatusOK)
+	fmt.Fprint(c.Writer, ": connected\n\n")
+	c.Writer.Flush()
+
+	ticker := time.NewTicker(eventKeepAlive)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-c.Request.Context().Done():
+			return
+		case <-ticker.C:
+			fmt.Fprint(c.Writer, ": keep-alive\n\n")
+			c.Writer.Flush()
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			if !isAdmin && event.UserID != userID {
+				continue
+			}
+			data, err := json.Marshal(event)
+			if err != nil {
+				continue
+			}
+			fmt.Fprintf(c.Writer, "id: %d\nevent: %s\ndata: %s\n\n", event.ID, event.Type, data)
+			c.Writer.Flush()
+		}
+	}
+}
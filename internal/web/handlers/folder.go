@@ -0,0 +1,172 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/web/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type FolderHandler struct {
+	db *gorm.DB
+}
+
+func NewFolderHandler(db *gorm.DB) *FolderHandler {
+	return &FolderHandler{db: db}
+}
+
+// FolderRequest represents folder data
+type FolderRequest struct {
+	Name string `json:"name" binding:"required,max=100" example:"Invoices"`
+}
+
+// FolderResponse represents a folder with the number of jobs in it
+type FolderResponse struct {
+	models.Folder
+	JobCount int64 `json:"job_count" example:"12"`
+}
+
+// ListFolders returns the authenticated user's folders
+// @Summary List folders
+// @Description Get all folders of the authenticated user with their job counts
+// @Tags folders
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} FolderResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /folders [get]
+func (h *FolderHandler) ListFolders(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var folders []models.Folder
+	if err := h.db.Where("user_id = ?", userID).Order("name").Find(&folders).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch folders"})
+		return
+	}
+
+	var counts []struct {
+		FolderID string
+		Count    int64
+	}
+	h.db.Model(&models.PrintJob{}).Select("folder_id, COUNT(*) AS count").
+		Where("user_id = ? AND folder_id IS NOT NULL", userID).Group("folder_id").Scan(&counts)
+	countByFolder := make(map[string]int64, len(counts))
+	for _, row := range counts {
+		countByFolder[row.FolderID] = row.Count
+	}
+
+	resp := make([]FolderResponse, len(folders))
+	for i, folder := range folders {
+		resp[i] = FolderResponse{Folder: folder, JobCount: countByFolder[folder.ID]}
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// CreateFolder creates a folder
+// @Summary Create folder
+// @Description Create a folder for organizing print jobs
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body FolderRequest true "Folder data"
+// @Success 201 {object} models.Folder
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /folders [post]
+func (h *FolderHandler) CreateFolder(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	folder := models.Folder{UserID: userID, Name: strings.TrimSpace(req.Name)}
+	if err := h.db.Create(&folder).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "folder already exists"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, folder)
+}
+
+// UpdateFolder renames a folder
+// @Summary Rename folder
+// @Description Rename a folder of the authenticated user
+// @Tags folders
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Folder ID"
+// @Param request body FolderRequest true "Folder data"
+// @Success 200 {object} models.Folder
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /folders/{id} [put]
+func (h *FolderHandler) UpdateFolder(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req FolderRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var folder models.Folder
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&folder).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "folder not found"})
+		return
+	}
+
+	folder.Name = strings.TrimSpace(req.Name)
+	if err := h.db.Save(&folder).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "folder already exists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, folder)
+}
+
+// DeleteFolder removes a folder. Its jobs are kept and become unfiled.
+// @Summary Delete folder
+// @Description Delete a folder of the authenticated user. Jobs in the folder are not deleted.
+// @Tags folders
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Folder ID"
+// @Success 200 {object} MessageResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /folders/{id} [delete]
+func (h *FolderHandler) DeleteFolder(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var folder models.Folder
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&folder).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "folder not found"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Unscoped().Model(&models.PrintJob{}).Where("folder_id = ?", folder.ID).
+			Update("folder_id", nil).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&folder).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete folder"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "folder deleted"})
+}
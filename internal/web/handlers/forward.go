@@ -0,0 +1,137 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alex4386/zikzi/internal/forward"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/web/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ForwardHandler struct {
+	db        *gorm.DB
+	forwarder *forward.Forwarder
+}
+
+func NewForwardHandler(db *gorm.DB, forwarder *forward.Forwarder) *ForwardHandler {
+	return &ForwardHandler{db: db, forwarder: forwarder}
+}
+
+// DownstreamPrinterResponse describes a downstream printer (without credentials)
+type DownstreamPrinterResponse struct {
+	Name     string `json:"name" example:"office"`
+	Protocol string `json:"protocol" example:"ipp"` // ipp, raw or lpr
+	Document string `json:"document" example:"pdf"` // Document sent by default: pdf or original
+}
+
+// ForwardJobRequest represents a request to print a job on a downstream printer
+type ForwardJobRequest struct {
+	Printer  string `json:"printer" binding:"required" example:"office"`
+	Document string `json:"document" binding:"omitempty,oneof=pdf original" example:"pdf"` // Defaults to the printer's setting
+}
+
+// forwardableJob loads a job the authenticated user may forward
+func (h *ForwardHandler) forwardableJob(c *gin.Context) (*models.PrintJob, bool) {
+	query := h.db.Preload("User").Where("id = ?", c.Param("id"))
+	if !middleware.IsAdmin(c) {
+		query = query.Where("user_id = ?", middleware.GetUserID(c))
+	}
+
+	var job models.PrintJob
+	if err := query.First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return nil, false
+	}
+	return &job, true
+}
+
+// ListPrinters returns the configured downstream printers
+// @Summary List downstream printers
+// @Description List the printers jobs can be forwarded to
+// @Tags forwarding
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} DownstreamPrinterResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /forwarding/printers [get]
+func (h *ForwardHandler) ListPrinters(c *gin.Context) {
+	printers := []DownstreamPrinterResponse{}
+	for _, p := range h.forwarder.Printers() {
+		printers = append(printers, DownstreamPrinterResponse{Name: p.Name, Protocol: p.Protocol, Document: p.Document})
+	}
+
+	c.JSON(http.StatusOK, printers)
+}
+
+// ForwardJob prints a job on a downstream printer
+// @Summary Forward job
+// @Description Send a job's PDF or original document to a downstream printer now and return the recorded outcome
+// @Tags forwarding
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Param request body ForwardJobRequest true "Forward options"
+// @Success 200 {object} models.JobForward
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /jobs/{id}/forward [post]
+func (h *ForwardHandler) ForwardJob(c *gin.Context) {
+	var req ForwardJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, ok := h.forwardableJob(c)
+	if !ok {
+		return
+	}
+	if job.Status != models.JobStatusCompleted && req.Document != models.ForwardDocumentOriginal {
+		c.JSON(http.StatusConflict, gin.H{"error": "job has not been converted yet"})
+		return
+	}
+
+	result, err := h.forwarder.Forward(c.Request.Context(), job, req.Printer, req.Document)
+	if errors.Is(err, forward.ErrUnknownPrinter) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to forward job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
+
+// ListForwards returns the downstream deliveries of a job
+// @Summary List job forwards
+// @Description List the downstream printers a job was sent to, most recent first
+// @Tags forwarding
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Success 200 {array} models.JobForward
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /jobs/{id}/forwards [get]
+func (h *ForwardHandler) ListForwards(c *gin.Context) {
+	job, ok := h.forwardableJob(c)
+	if !ok {
+		return
+	}
+
+	var forwards []models.JobForward
+	if err := h.db.Where("job_id = ?", job.ID).Order("created_at DESC").Find(&forwards).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch forwards"})
+		return
+	}
+
+	c.JSON(http.StatusOK, forwards)
+}
@@ -0,0 +1,130 @@
+package handlers
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/alex4386/zikzi/internal/hotfolder"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type HotFolderHandler struct {
+	db       *gorm.DB
+	exporter *hotfolder.Exporter
+}
+
+func NewHotFolderHandler(db *gorm.DB, exporter *hotfolder.Exporter) *HotFolderHandler {
+	return &HotFolderHandler{db: db, exporter: exporter}
+}
+
+// ListExportsQuery represents query parameters for listing hot folder exports
+type ListExportsQuery struct {
+	Page   int    `form:"page,default=1" example:"1"`
+	Limit  int    `form:"limit,default=50" example:"50"`
+	Status string `form:"status" example:"failed"` // pending, exported or failed
+	Rule   string `form:"rule" example:"archive"`
+	JobID  string `form:"job_id" example:"1YLG9iyJYOdE"`
+}
+
+// ListExportsResponse represents a paginated list of hot folder exports
+type ListExportsResponse struct {
+	Exports []models.HotFolderExport `json:"exports"`
+	Total   int64                    `json:"total" example:"100"`
+	Page    int                      `json:"page" example:"1"`
+	Limit   int                      `json:"limit" example:"50"`
+}
+
+// ListExports returns the hot folder exports
+// @Summary List hot folder exports
+// @Description List exports of completed PDFs into hot folders, most recently updated first (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(50)
+// @Param status query string false "Filter by status (pending, exported, failed)"
+// @Param rule query string false "Filter by rule name"
+// @Param job_id query string false "Filter by job"
+// @Success 200 {object} ListExportsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /admin/exports [get]
+func (h *HotFolderHandler) ListExports(c *gin.Context) {
+	var q ListExportsQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.Limit < 1 || q.Limit > 200 {
+		q.Limit = 50
+	}
+
+	query := h.db.Model(&models.HotFolderExport{})
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+	if q.Rule != "" {
+		query = query.Where("rule = ?", q.Rule)
+	}
+	if q.JobID != "" {
+		query = query.Where("job_id = ?", q.JobID)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var exports []models.HotFolderExport
+	if err := query.Order("updated_at DESC").Offset((q.Page - 1) * q.Limit).Limit(q.Limit).Find(&exports).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch exports"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ListExportsResponse{
+		Exports: exports,
+		Total:   total,
+		Page:    q.Page,
+		Limit:   q.Limit,
+	})
+}
+
+// RetryExport exports a job into a hot folder again
+// @Summary Retry hot folder export
+// @Description Write the job's PDF for the export's rule again right away and return the outcome (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Export ID"
+// @Success 200 {object} models.HotFolderExport
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /admin/exports/{id}/retry [post]
+func (h *HotFolderHandler) RetryExport(c *gin.Context) {
+	var export models.HotFolderExport
+	if err := h.db.First(&export, "id = ?", c.Param("id")).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "export not found"})
+		return
+	}
+
+	result, err := h.exporter.Retry(c.Request.Context(), &export)
+	switch {
+	case errors.Is(err, hotfolder.ErrUnknownRule):
+		c.JSON(http.StatusConflict, gin.H{"error": err.Error()})
+		return
+	case errors.Is(err, gorm.ErrRecordNotFound):
+		c.JSON(http.StatusConflict, gin.H{"error": "job is deleted or in the trash"})
+		return
+	case err != nil:
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to retry export"})
+		return
+	}
+
+	c.JSON(http.StatusOK, result)
+}
@@ -1,9 +1,14 @@
 package handlers
 
 import (
+	"errors"
+	"fmt"
+	"io"
 	"net/http"
 	"strings"
+	"time"
 
+	"github.com/alex4386/zikzi/internal/config"
 	"github.com/alex4386/zikzi/internal/models"
 	"github.com/alex4386/zikzi/internal/web/middleware"
 	"github.com/gin-gonic/gin"
@@ -11,18 +16,20 @@ import (
 )
 
 type IPHandler struct {
-	db *gorm.DB
+	db     *gorm.DB
+	config config.IPRegistrationsConfig
 }
 
-func NewIPHandler(db *gorm.DB) *IPHandler {
-	return &IPHandler{db: db}
+func NewIPHandler(db *gorm.DB, cfg config.IPRegistrationsConfig) *IPHandler {
+	return &IPHandler{db: db, config: cfg}
 }
 
 // RegisterIPRequest represents IP registration data
 type RegisterIPRequest struct {
-	IPAddress   string `json:"ip_address" binding:"required" example:"192.168.1.100"`
+	IPAddress   string `json:"ip_address" binding:"required" example:"192.168.1.100"` // IP address or CIDR range, e.g. 2001:db8:1:2::/64
 	Description string `json:"description" example:"Office Desktop"`
 	UserID      string `json:"user_id" example:"abc123"` // Admin only: register on behalf of user
+	ExpiresInDays int  `json:"expires_in_days" example:"90"` // Expire after N days (0 = never)
 }
 
 // UpdateIPRequest represents IP update data
@@ -30,6 +37,17 @@ type UpdateIPRequest struct {
 	Description string `json:"description" example:"Office Desktop"`
 }
 
+// RenewIPRequest represents IP renewal data
+type RenewIPRequest struct {
+	Days int `json:"days" example:"30"` // Admin only: renew for N days instead of the configured renew period
+}
+
+// CreateClaimCodeRequest represents claim code data
+type CreateClaimCodeRequest struct {
+	Description string `json:"description" example:"Lab PC"` // Description of the registration created by the code
+	UserID      string `json:"user_id" example:"abc123"`     // Admin only: pair a machine for another user
+}
+
 // DetectIPResponse represents the detected IP response
 type DetectIPResponse struct {
 	IPAddress string `json:"ip_address" example:"192.168.1.100"`
@@ -121,7 +139,8 @@ func (h *IPHandler) ListIPs(c *gin.Context) {
 
 // RegisterIP registers a new IP address for the authenticated user
 // @Summary Register IP address
-// @Description Register a new IP address to associate with the user's account (admin can register on behalf of user)
+// @Description Register a new IP address or CIDR range to associate with the user's account (admin can register on behalf of user).
+// @Description Where ranges overlap, the most specific one wins. Users may register ranges up to /24 (IPv4) or /64 (IPv6) that don't overlap other users' registrations in either direction.
 // @Tags ips
 // @Accept json
 // @Produce json
@@ -148,15 +167,60 @@ func (h *IPHandler) RegisterIP(c *gin.Context) {
 		targetUserID = req.UserID
 	}
 
+	ip := models.IPRegistration{
+		UserID:      targetUserID,
+		Description: req.Description,
+		IsActive:    true,
+	}
+	if err := ip.SetAddress(req.IPAddress); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.ExpiresInDays < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "expires_in_days must not be negative"})
+		return
+	}
+	if req.ExpiresInDays > 0 {
+		expires := time.Now().AddDate(0, 0, req.ExpiresInDays)
+		ip.ExpiresAt = &expires
+	}
+	if !isAdmin && ip.TooBroadForUsers() {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("ranges larger than /%d (IPv4) or /%d (IPv6) can only be registered by admins",
+			models.MinUserPrefixIPv4, models.MinUserPrefixIPv6)})
+		return
+	}
+
+	// Users can't overlap someone else's registration in either direction. The
+	// most specific registration wins, so a nested one would take over the
+	// other user's jobs from those addresses.
+	overlapping, err := ip.Overlapping(h.db)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check overlapping registrations"})
+		return
+	}
+	for _, other := range overlapping {
+		if other.IPAddress == ip.IPAddress {
+			c.JSON(http.StatusConflict, gin.H{"error": "IP address already registered"})
+			return
+		}
+		if !isAdmin && other.UserID != targetUserID {
+			c.JSON(http.StatusConflict, gin.H{"error": fmt.Sprintf("overlaps %s registered by another user", other.IPAddress)})
+			return
+		}
+	}
+
 	// Check if IP is already registered (including soft-deleted records)
 	var existing models.IPRegistration
-	if err := h.db.Unscoped().Where("ip_address = ?", req.IPAddress).First(&existing).Error; err == nil {
+	if err := h.db.Unscoped().Where("ip_address = ?", ip.IPAddress).First(&existing).Error; err == nil {
 		if existing.DeletedAt.Valid {
 			// Reactivate the soft-deleted record
 			existing.DeletedAt = gorm.DeletedAt{}
 			existing.UserID = targetUserID
 			existing.Description = req.Description
 			existing.IsActive = true
+			existing.ExpiresAt = ip.ExpiresAt
+			existing.Expired = false
+			existing.ExpiryWarnedAt = nil
 			if err := h.db.Unscoped().Save(&existing).Error; err != nil {
 				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register IP"})
 				return
@@ -169,13 +233,6 @@ func (h *IPHandler) RegisterIP(c *gin.Context) {
 		return
 	}
 
-	ip := models.IPRegistration{
-		UserID:      targetUserID,
-		IPAddress:   req.IPAddress,
-		Description: req.Description,
-		IsActive:    true,
-	}
-
 	if err := h.db.Create(&ip).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to register IP"})
 		return
@@ -234,6 +291,135 @@ func (h *IPHandler) UpdateIP(c *gin.Context) {
 	c.JSON(http.StatusOK, ip)
 }
 
+// RenewIP extends an IP registration's expiry
+// @Summary Renew IP registration
+// @Description Move the expiry of an IP registration to the configured renew period from now, reactivating it if it had expired (admin can renew any, and for a custom number of days)
+// @Tags ips
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "IP Registration ID"
+// @Param request body RenewIPRequest false "Renewal data"
+// @Success 200 {object} models.IPRegistration
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /ips/{id}/renew [post]
+func (h *IPHandler) RenewIP(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	isAdmin := middleware.IsAdmin(c)
+	ipID := c.Param("id")
+
+	var req RenewIPRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if req.Days != 0 && !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "only admins can choose the renewal period"})
+		return
+	}
+	if req.Days < 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "days must not be negative"})
+		return
+	}
+
+	var ip models.IPRegistration
+	query := h.db.Where("id = ?", ipID)
+	if !isAdmin {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&ip).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "IP registration not found"})
+		return
+	}
+	if ip.ExpiresAt == nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "IP registration does not expire"})
+		return
+	}
+
+	until := time.Now().Add(h.config.RenewPeriod)
+	if req.Days > 0 {
+		until = time.Now().AddDate(0, 0, req.Days)
+	}
+	ip.Renew(until)
+	if err := h.db.Model(&ip).Select("expires_at", "expiry_warned_at", "is_active", "expired").Updates(&ip).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to renew IP"})
+		return
+	}
+
+	// Preload user for response
+	h.db.Preload("User").First(&ip, "id = ?", ip.ID)
+
+	c.JSON(http.StatusOK, ip)
+}
+
+// CreateClaimCode creates a code for pairing a printing machine
+// @Summary Create claim code
+// @Description Create a short-lived code. Printing a page containing it from any machine registers that machine's IP to the user, and the job is discarded.
+// @Description Creating a code replaces the user's earlier unused codes.
+// @Tags ips
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateClaimCodeRequest false "Claim code data"
+// @Success 201 {object} models.IPClaimCode
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /ips/claims [post]
+func (h *IPHandler) CreateClaimCode(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	isAdmin := middleware.IsAdmin(c)
+
+	var req CreateClaimCodeRequest
+	if err := c.ShouldBindJSON(&req); err != nil && !errors.Is(err, io.EOF) {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	targetUserID := userID
+	if req.UserID != "" && isAdmin {
+		targetUserID = req.UserID
+	}
+
+	claim, err := models.CreateClaimCode(h.db, targetUserID, req.Description, h.config.ClaimCodeTTL)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create claim code"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, claim)
+}
+
+// GetClaimCode returns a claim code and whether it was used
+// @Summary Get claim code
+// @Description Get a claim code. Once a machine has printed it, claimed_ip and registration_id are set.
+// @Tags ips
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Claim code ID"
+// @Success 200 {object} models.IPClaimCode
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /ips/claims/{id} [get]
+func (h *IPHandler) GetClaimCode(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	isAdmin := middleware.IsAdmin(c)
+
+	var claim models.IPClaimCode
+	query := h.db.Where("id = ?", c.Param("id"))
+	if !isAdmin {
+		query = query.Where("user_id = ?", userID)
+	}
+	if err := query.First(&claim).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "claim code not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, claim)
+}
+
 // DeleteIP removes an IP registration
 // @Summary Delete IP registration
 // @Description Remove an IP address registration (admin can delete any)
@@ -0,0 +1,66 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/database"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/gin-gonic/gin"
+)
+
+func TestRegisterIPOverlap(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := database.Connect(config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "zikzi.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, address := range []string{"10.0.1.0/24", "10.0.2.5"} {
+		registration := models.IPRegistration{UserID: "alice", IsActive: true}
+		if err := registration.SetAddress(address); err != nil {
+			t.Fatal(err)
+		}
+		if err := db.Create(&registration).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	handler := NewIPHandler(db, config.IPRegistrationsConfig{})
+
+	tests := []struct {
+		name    string
+		userID  string
+		admin   bool
+		address string
+		want    int
+	}{
+		{"nested in another user's range", "bob", false, "10.0.1.7", http.StatusConflict},
+		{"narrower range in another user's range", "bob", false, "10.0.1.0/28", http.StatusConflict},
+		{"containing another user's address", "bob", false, "10.0.2.0/24", http.StatusConflict},
+		{"nested in own range", "alice", false, "10.0.1.8", http.StatusCreated},
+		{"admin nested in another user's range", "admin", true, "10.0.1.9", http.StatusCreated},
+		{"same address", "admin", true, "10.0.2.5", http.StatusConflict},
+		{"free range", "bob", false, "10.0.3.0/24", http.StatusCreated},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("user_id", tt.userID)
+			c.Set("is_admin", tt.admin)
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/ips", strings.NewReader(`{"ip_address":"`+tt.address+`"}`))
+			c.Request.Header.Set("Content-Type", "application/json")
+
+			handler.RegisterIP(c)
+			if w.Code != tt.want {
+				t.Errorf("RegisterIP(%s) as %s = %d, want %d: %s", tt.address, tt.userID, w.Code, tt.want, w.Body.String())
+			}
+		})
+	}
+}
@@ -1,32 +1,91 @@
 package handlers
 
 import (
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"mime"
 	"net/http"
+	"path"
+	"time"
 
 	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/export"
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/maintenance"
 	"github.com/alex4386/zikzi/internal/models"
-	"github.com/alex4386/zikzi/internal/utils"
+	"github.com/alex4386/zikzi/internal/quota"
+	"github.com/alex4386/zikzi/internal/signing"
+	"github.com/alex4386/zikzi/internal/storage"
+	"github.com/alex4386/zikzi/internal/tagging"
 	"github.com/alex4386/zikzi/internal/web/middleware"
 	"github.com/gin-gonic/gin"
 	"gorm.io/gorm"
 )
 
+// maxExportJobs limits how many jobs a single ZIP export may contain
+const maxExportJobs = 1000
+
 type JobHandler struct {
 	db      *gorm.DB
 	storage config.StorageConfig
+	store   *storage.JobStore
+	signer  *signing.Signer
+	trash   *maintenance.Trash
+	quotas  *quota.Checker
+	events  *events.Bus
 }
 
-func NewJobHandler(db *gorm.DB, storage config.StorageConfig) *JobHandler {
-	return &JobHandler{db: db, storage: storage}
+func NewJobHandler(db *gorm.DB, storageCfg config.StorageConfig, store *storage.JobStore, signer *signing.Signer, trash *maintenance.Trash, quotas *quota.Checker, bus *events.Bus) *JobHandler {
+	return &JobHandler{db: db, storage: storageCfg, store: store, signer: signer, trash: trash, quotas: quotas, events: bus}
 }
 
 // ListJobsQuery represents query parameters for listing jobs
 type ListJobsQuery struct {
-	Page   int    `form:"page,default=1" example:"1"`
-	Limit  int    `form:"limit,default=20" example:"20"`
-	Status string `form:"status" example:"completed"`
-	UserID string `form:"user_id" example:"abc123"` // Admin only: filter by user
-	Full   bool   `form:"full" example:"false"`     // Admin only: show all jobs
+	Page     int    `form:"page,default=1" example:"1"`
+	Limit    int    `form:"limit,default=20" example:"20"`
+	Status   string `form:"status" example:"completed"`
+	UserID   string `form:"user_id" example:"abc123"`   // Admin only: filter by user
+	Full     bool   `form:"full" example:"false"`       // Admin only: show all jobs
+	TagID    string `form:"tag_id" example:"abc123"`    // Filter by tag
+	FolderID string `form:"folder_id" example:"abc123"` // Filter by folder, "none" for unfiled jobs
+}
+
+// UpdateJobRequest represents changes to a job's organization. Omitted fields are left unchanged.
+type UpdateJobRequest struct {
+	Note     *string   `json:"note" example:"Signed copy for accounting"`
+	FolderID *string   `json:"folder_id" example:"abc123def456"` // Empty string removes the job from its folder
+	TagIDs   *[]string `json:"tag_ids" example:"abc123def456"`   // Replaces all tags of the job
+}
+
+// BulkJobsRequest represents an operation on several jobs at once
+type BulkJobsRequest struct {
+	JobIDs   []string `json:"job_ids" binding:"required,min=1,max=500" example:"abc123def456"`
+	Action   string   `json:"action" binding:"required,oneof=move tag untag delete" example:"tag"` // move, tag, untag, delete
+	FolderID string   `json:"folder_id" example:"abc123def456"`                                    // For move; empty removes jobs from their folder
+	TagIDs   []string `json:"tag_ids" example:"abc123def456"`                                      // For tag and untag
+}
+
+// BulkJobsResponse represents the result of a bulk operation
+type BulkJobsResponse struct {
+	Updated int `json:"updated" example:"5"`
+}
+
+// ExportJobsRequest selects the jobs to export. Job IDs and filters can be combined.
+type ExportJobsRequest struct {
+	JobIDs           []string   `json:"job_ids" example:"abc123,def456"`
+	From             *time.Time `json:"from" example:"2026-01-01T00:00:00Z"` // Created at or after
+	To               *time.Time `json:"to" example:"2026-02-01T00:00:00Z"`   // Created before
+	Status           string     `json:"status" example:"completed"`
+	TagID            string     `json:"tag_id" example:"abc123"`
+	Full             bool       `json:"full" example:"false"`     // Admin only: export all users' jobs
+	UserID           string     `json:"user_id" example:"abc123"` // Admin only: export a user's jobs
+	IncludeOriginals bool       `json:"include_originals" example:"false"`
+	IncludeManifest  bool       `json:"include_manifest" example:"true"`
 }
 
 // ListJobsResponse represents the paginated jobs response
@@ -37,6 +96,27 @@ type ListJobsResponse struct {
 	Limit int               `json:"limit" example:"20"`
 }
 
+// TrashedJob represents a job in the trash
+type TrashedJob struct {
+	models.PrintJob
+	DeletedAt time.Time `json:"deleted_at"`
+	ExpiresAt time.Time `json:"expires_at"` // When the job and its files are purged
+}
+
+// ListTrashResponse represents the paginated trash response
+type ListTrashResponse struct {
+	Jobs  []TrashedJob `json:"jobs"`
+	Total int64        `json:"total" example:"3"`
+	Page  int          `json:"page" example:"1"`
+	Limit int          `json:"limit" example:"20"`
+}
+
+// EmptyTrashResponse represents the result of emptying the trash
+type EmptyTrashResponse struct {
+	Message string `json:"message" example:"trash emptied"`
+	Purged  int    `json:"purged" example:"3"`
+}
+
 // MessageResponse represents a simple message response
 type MessageResponse struct {
 	Message string `json:"message" example:"job deleted"`
@@ -53,6 +133,8 @@ type MessageResponse struct {
 // @Param status query string false "Filter by status (received, processing, completed, failed)"
 // @Param user_id query string false "Filter by user ID (admin only, requires full=true)"
 // @Param full query bool false "Show all jobs (admin only)"
+// @Param tag_id query string false "Filter by tag ID"
+// @Param folder_id query string false "Filter by folder ID, or none for jobs without a folder"
 // @Success 200 {object} ListJobsResponse
 // @Failure 400 {object} ErrorResponse
 // @Failure 401 {object} ErrorResponse
@@ -97,6 +179,16 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 	if query.Status != "" {
 		q = q.Where("status = ?", query.Status)
 	}
+	if query.TagID != "" {
+		q = q.Where("id IN (?)", h.db.Table("print_job_tags").Select("print_job_id").Where("tag_id = ?", query.TagID))
+	}
+	switch query.FolderID {
+	case "":
+	case "none":
+		q = q.Where("folder_id IS NULL")
+	default:
+		q = q.Where("folder_id = ?", query.FolderID)
+	}
 
 	var total int64
 	q.Model(&models.PrintJob{}).Count(&total)
@@ -105,6 +197,7 @@ func (h *JobHandler) ListJobs(c *gin.Context) {
 	if query.Full && isAdmin {
 		q = q.Preload("User")
 	}
+	q = q.Preload("Tags")
 
 	if err := q.Order("created_at DESC").Offset(offset).Limit(query.Limit).Find(&jobs).Error; err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch jobs"})
@@ -136,7 +229,7 @@ func (h *JobHandler) GetJob(c *gin.Context) {
 	jobID := c.Param("id")
 
 	var job models.PrintJob
-	query := h.db.Preload("User")
+	query := h.db.Preload("User").Preload("Tags").Preload("Folder").Preload("Forwards")
 	if isAdmin {
 		// Admin can access any job
 		query = query.Where("id = ?", jobID)
@@ -187,7 +280,7 @@ func (h *JobHandler) DownloadJob(c *gin.Context) {
 		return
 	}
 
-	c.File(job.OriginalFile)
+	h.serveFile(c, &job, job.OriginalFile, job.OriginalSHA256)
 }
 
 // DownloadPDF downloads the converted PDF file
@@ -224,7 +317,7 @@ func (h *JobHandler) DownloadPDF(c *gin.Context) {
 		return
 	}
 
-	c.File(job.PDFFile)
+	h.serveFile(c, &job, job.PDFFile, job.PDFSHA256)
 }
 
 // GetThumbnail returns the thumbnail image for a print job
@@ -261,12 +354,364 @@ func (h *JobHandler) GetThumbnail(c *gin.Context) {
 		return
 	}
 
-	c.File(job.ThumbnailFile)
+	h.serveFile(c, &job, job.ThumbnailFile, job.ThumbnailSHA256)
+}
+
+// VerifyJobResponse represents the result of re-validating a job's PDF signature
+type VerifyJobResponse struct {
+	Valid   bool   `json:"valid" example:"true"`   // Signed by Zikzi and unmodified since, i.e. both trusted and covering the whole document
+	Trusted bool   `json:"trusted" example:"true"` // Signed by the currently configured certificate
+	Error   string `json:"error,omitempty"`
+	*signing.Verification
+}
+
+// VerifyJob re-validates the signature of a job's PDF
+// @Summary Verify PDF signature
+// @Description Re-validate the PAdES signature of the converted PDF. valid is only true when the PDF was signed with the configured certificate and not modified since (admins can access any job)
+// @Tags jobs
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Success 200 {object} VerifyJobResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /jobs/{id}/verify [get]
+func (h *JobHandler) VerifyJob(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	isAdmin := middleware.IsAdmin(c)
+	jobID := c.Param("id")
+
+	var job models.PrintJob
+	query := h.db
+	if isAdmin {
+		query = query.Where("id = ?", jobID)
+	} else {
+		query = query.Where("id = ? AND user_id = ?", jobID, userID)
+	}
+
+	if err := query.First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	if job.PDFFile == "" {
+		c.JSON(http.StatusNotFound, gin.H{"error": "PDF not available"})
+		return
+	}
+
+	reader, err := h.store.Open(c.Request.Context(), &job, job.PDFFile)
+	if err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "PDF not available"})
+		return
+	}
+	data, err := io.ReadAll(reader)
+	reader.Close()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read PDF"})
+		return
+	}
+
+	verification, err := signing.Verify(data)
+	if errors.Is(err, signing.ErrNotSigned) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "PDF is not signed"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusOK, VerifyJobResponse{Valid: false, Error: err.Error()})
+		return
+	}
+
+	resp := VerifyJobResponse{Verification: verification}
+	if h.signer != nil {
+		resp.Trusted = verification.Fingerprint == signing.CertificateFingerprint(h.signer.Certificate())
+	}
+	resp.Valid = verification.CoversDocument && resp.Trusted
+	switch {
+	case !verification.CoversDocument:
+		resp.Error = "PDF was modified after signing"
+	case h.signer == nil:
+		resp.Error = "signing is disabled, so the signer can't be checked"
+	case !resp.Trusted:
+		resp.Error = "PDF was signed by another certificate"
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// UpdateJob changes a job's note, folder or tags
+// @Summary Update print job
+// @Description Set the note, folder or tags of a print job (admins can update any job). Folders and tags must belong to the job's owner.
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Param request body UpdateJobRequest true "Job changes"
+// @Success 200 {object} models.PrintJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /jobs/{id} [put]
+func (h *JobHandler) UpdateJob(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	isAdmin := middleware.IsAdmin(c)
+	jobID := c.Param("id")
+
+	var req UpdateJobRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var job models.PrintJob
+	query := h.db
+	if isAdmin {
+		query = query.Where("id = ?", jobID)
+	} else {
+		query = query.Where("id = ? AND user_id = ?", jobID, userID)
+	}
+	if err := query.First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return
+	}
+
+	updates := map[string]interface{}{}
+	if req.Note != nil {
+		updates["note"] = *req.Note
+	}
+	if req.FolderID != nil {
+		folderID, err := h.ownedFolder(job.UserID, *req.FolderID)
+		if err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+		updates["folder_id"] = folderID
+	}
+
+	var tags []models.Tag
+	if req.TagIDs != nil {
+		var err error
+		if tags, err = h.ownedTags(job.UserID, *req.TagIDs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if len(updates) > 0 {
+			if err := tx.Model(&job).Updates(updates).Error; err != nil {
+				return err
+			}
+		}
+		if req.TagIDs != nil {
+			return tx.Model(&job).Association("Tags").Replace(tags)
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update job"})
+		return
+	}
+
+	h.db.Preload("Tags").Preload("Folder").First(&job, "id = ?", job.ID)
+	c.JSON(http.StatusOK, job)
+}
+
+// BulkJobs moves, tags, untags or deletes several of the user's jobs at once
+// @Summary Bulk job operation
+// @Description Apply an operation to several of the authenticated user's jobs: move to a folder, add or remove tags, or move to the trash
+// @Tags jobs
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body BulkJobsRequest true "Bulk operation"
+// @Success 200 {object} BulkJobsResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /jobs/bulk [post]
+func (h *JobHandler) BulkJobs(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req BulkJobsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var jobs []models.PrintJob
+	if err := h.db.Where("id IN ? AND user_id = ?", req.JobIDs, userID).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch jobs"})
+		return
+	}
+
+	var tags []models.Tag
+	if req.Action == "tag" || req.Action == "untag" {
+		if len(req.TagIDs) == 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "tag_ids is required"})
+			return
+		}
+		var err error
+		if tags, err = h.ownedTags(userID, req.TagIDs); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	var folderID *string
+	if req.Action == "move" {
+		var err error
+		if folderID, err = h.ownedFolder(userID, req.FolderID); err != nil {
+			c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+			return
+		}
+	}
+
+	if req.Action == "delete" {
+		for i := range jobs {
+			if err := h.trash.Delete(c.Request.Context(), &jobs[i]); err != nil {
+				c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete job " + jobs[i].ID})
+				return
+			}
+			h.events.Publish(events.JobDeleted, &jobs[i])
+		}
+		c.JSON(http.StatusOK, BulkJobsResponse{Updated: len(jobs)})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		for i := range jobs {
+			job := &jobs[i]
+			var err error
+			switch req.Action {
+			case "move":
+				err = tx.Model(job).Update("folder_id", folderID).Error
+			case "tag":
+				err = tx.Model(job).Association("Tags").Append(tags)
+			case "untag":
+				err = tx.Model(job).Association("Tags").Delete(tags)
+			}
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update jobs"})
+		return
+	}
+
+	c.JSON(http.StatusOK, BulkJobsResponse{Updated: len(jobs)})
+}
+
+// ownedFolder validates that a folder belongs to a user. An empty ID means no folder.
+func (h *JobHandler) ownedFolder(userID, folderID string) (*string, error) {
+	if folderID == "" {
+		return nil, nil
+	}
+	var folder models.Folder
+	if err := h.db.Where("id = ? AND user_id = ?", folderID, userID).First(&folder).Error; err != nil {
+		return nil, errors.New("folder not found")
+	}
+	return &folder.ID, nil
+}
+
+// ownedTags loads tags and validates that they all belong to a user
+func (h *JobHandler) ownedTags(userID string, tagIDs []string) ([]models.Tag, error) {
+	tags := []models.Tag{}
+	if len(tagIDs) == 0 {
+		return tags, nil
+	}
+	if err := h.db.Where("id IN ? AND user_id = ?", tagIDs, userID).Find(&tags).Error; err != nil {
+		return nil, err
+	}
+
+	found := make(map[string]bool, len(tags))
+	for _, tag := range tags {
+		found[tag.ID] = true
+	}
+	for _, id := range tagIDs {
+		if !found[id] {
+			return nil, errors.New("tag not found: " + id)
+		}
+	}
+	return tags, nil
+}
+
+// ExportJobs streams a ZIP archive of the selected jobs' PDFs
+// @Summary Export jobs as ZIP
+// @Description Download the PDFs of the selected jobs as a ZIP archive, optionally with the original files and a manifest.json. Files are named after the document name.
+// @Tags jobs
+// @Accept json
+// @Produce application/zip
+// @Security BearerAuth
+// @Param request body ExportJobsRequest true "Jobs to export"
+// @Success 200 {file} binary
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /jobs/export [post]
+func (h *JobHandler) ExportJobs(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	isAdmin := middleware.IsAdmin(c)
+
+	var req ExportJobsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if (req.Full || req.UserID != "") && !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+		return
+	}
+
+	filter := export.Filter{
+		JobIDs: req.JobIDs,
+		UserID: req.UserID,
+		Status: req.Status,
+		TagID:  req.TagID,
+	}
+	if !req.Full && req.UserID == "" {
+		filter.UserID = userID
+	}
+	if req.From != nil {
+		filter.From = *req.From
+	}
+	if req.To != nil {
+		filter.To = *req.To
+	}
+
+	var jobs []models.PrintJob
+	if err := filter.Query(h.db).Limit(maxExportJobs + 1).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch jobs"})
+		return
+	}
+	if len(jobs) == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no jobs matched"})
+		return
+	}
+	if len(jobs) > maxExportJobs {
+		c.JSON(http.StatusBadRequest, gin.H{"error": fmt.Sprintf("too many jobs, export at most %d at a time", maxExportJobs)})
+		return
+	}
+
+	filename := fmt.Sprintf("zikzi-export-%s.zip", time.Now().Format("20060102-150405"))
+	c.Header("Content-Type", "application/zip")
+	c.Header("Content-Disposition", fmt.Sprintf(`attachment; filename="%s"`, filename))
+	c.Status(http.StatusOK)
+
+	// Headers are already sent, so a failure can only cut the archive short
+	opts := export.Options{Originals: req.IncludeOriginals, Manifest: req.IncludeManifest}
+	if _, err := export.Write(c.Request.Context(), c.Writer, h.store, jobs, opts); err != nil {
+		logger.Warn("Export: archive for user %s aborted: %v", userID, err)
+	}
 }
 
-// DeleteJob deletes a print job and its associated files
+// DeleteJob moves a print job to the trash
 // @Summary Delete print job
-// @Description Delete a print job and its associated files (admins can delete any job)
+// @Description Move a print job to the trash. Its files are purged when the trash period expires (admins can delete any job)
 // @Tags jobs
 // @Produce json
 // @Security BearerAuth
@@ -293,18 +738,191 @@ func (h *JobHandler) DeleteJob(c *gin.Context) {
 		return
 	}
 
-	// Delete associated files
-	utils.DeleteJobFiles(job.OriginalFile, job.PDFFile, job.ThumbnailFile)
-
-	// Delete database record
-	if err := h.db.Delete(&job).Error; err != nil {
+	if err := h.trash.Delete(c.Request.Context(), &job); err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete job"})
 		return
 	}
+	h.events.Publish(events.JobDeleted, &job)
 
 	c.JSON(http.StatusOK, gin.H{"message": "job deleted"})
 }
 
+// trashQuery returns a query over trashed jobs visible to the caller.
+// Admins may pass full=true to see every user's trash.
+func (h *JobHandler) trashQuery(c *gin.Context, query *ListJobsQuery) (*gorm.DB, bool) {
+	isAdmin := middleware.IsAdmin(c)
+	if query.Full && !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+		return nil, false
+	}
+
+	q := h.db.Unscoped().Model(&models.PrintJob{}).Where("deleted_at IS NOT NULL")
+	if query.Full {
+		if query.UserID != "" {
+			q = q.Where("user_id = ?", query.UserID)
+		}
+	} else {
+		q = q.Where("user_id = ?", middleware.GetUserID(c))
+	}
+	return q, true
+}
+
+// ListTrash returns the deleted print jobs of the authenticated user
+// @Summary List trash
+// @Description Get paginated list of deleted print jobs that can still be restored. Admins can use full=true to see all users' trash.
+// @Tags jobs
+// @Produce json
+// @Security BearerAuth
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(20)
+// @Param user_id query string false "Filter by user ID (admin only, requires full=true)"
+// @Param full query bool false "Show all users' trash (admin only)"
+// @Success 200 {object} ListTrashResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /jobs/trash [get]
+func (h *JobHandler) ListTrash(c *gin.Context) {
+	var query ListJobsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if query.Limit > 100 {
+		query.Limit = 100
+	}
+
+	q, ok := h.trashQuery(c, &query)
+	if !ok {
+		return
+	}
+
+	var total int64
+	q.Count(&total)
+
+	var jobs []models.PrintJob
+	offset := (query.Page - 1) * query.Limit
+	if err := q.Order("deleted_at DESC").Offset(offset).Limit(query.Limit).Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch trash"})
+		return
+	}
+
+	trashed := make([]TrashedJob, len(jobs))
+	for i, job := range jobs {
+		trashed[i] = TrashedJob{
+			PrintJob:  job,
+			DeletedAt: job.DeletedAt.Time,
+			ExpiresAt: h.trash.ExpiresAt(job.DeletedAt.Time),
+		}
+	}
+
+	c.JSON(http.StatusOK, ListTrashResponse{
+		Jobs:  trashed,
+		Total: total,
+		Page:  query.Page,
+		Limit: query.Limit,
+	})
+}
+
+// RestoreJob moves a print job out of the trash
+// @Summary Restore print job
+// @Description Restore a deleted print job from the trash (admins can restore any job). Jobs past the trash period and jobs of users over their storage quota can't be restored.
+// @Tags jobs
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Success 200 {object} models.PrintJob
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Router /jobs/{id}/restore [post]
+func (h *JobHandler) RestoreJob(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	isAdmin := middleware.IsAdmin(c)
+	jobID := c.Param("id")
+
+	var job models.PrintJob
+	query := h.db.Unscoped().Where("deleted_at IS NOT NULL")
+	if isAdmin {
+		query = query.Where("id = ?", jobID)
+	} else {
+		query = query.Where("id = ? AND user_id = ?", jobID, userID)
+	}
+
+	if err := query.First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found in trash"})
+		return
+	}
+
+	// Expired jobs may already have lost files to a purge in progress
+	if h.trash.ExpiresAt(job.DeletedAt.Time).Before(time.Now()) {
+		c.JSON(http.StatusGone, gin.H{"error": "job has expired from the trash"})
+		return
+	}
+
+	if err := h.quotas.CheckRestore(c.Request.Context(), &job); err != nil {
+		var exceeded *quota.ExceededError
+		if errors.As(err, &exceeded) {
+			c.JSON(http.StatusForbidden, gin.H{"error": exceeded.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check quota"})
+		return
+	}
+
+	if err := h.db.Unscoped().Model(&job).Update("deleted_at", nil).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to restore job"})
+		return
+	}
+
+	c.JSON(http.StatusOK, job)
+}
+
+// EmptyTrash permanently deletes the jobs in the authenticated user's trash
+// @Summary Empty trash
+// @Description Permanently delete all jobs in the trash along with their files. Admins can use full=true to empty all users' trash.
+// @Tags jobs
+// @Produce json
+// @Security BearerAuth
+// @Param user_id query string false "Filter by user ID (admin only, requires full=true)"
+// @Param full query bool false "Empty all users' trash (admin only)"
+// @Success 200 {object} EmptyTrashResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /jobs/trash [delete]
+func (h *JobHandler) EmptyTrash(c *gin.Context) {
+	var query ListJobsQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	q, ok := h.trashQuery(c, &query)
+	if !ok {
+		return
+	}
+
+	var jobs []models.PrintJob
+	if err := q.Find(&jobs).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch trash"})
+		return
+	}
+
+	purged := 0
+	for i := range jobs {
+		if err := h.trash.Purge(c.Request.Context(), &jobs[i]); err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to purge job " + jobs[i].ID})
+			return
+		}
+		purged++
+	}
+
+	c.JSON(http.StatusOK, EmptyTrashResponse{Message: "trash emptied", Purged: purged})
+}
+
 // ListOrphanedJobs returns all orphaned print jobs (jobs without a user) - admin only
 // @Summary List orphaned print jobs
 // @Description Get a list of all print jobs without an assigned user (admin only)
@@ -404,14 +1022,79 @@ func (h *JobHandler) AssignJob(c *gin.Context) {
 		return
 	}
 
+	// Folders and tags belong to the previous owner
 	job.UserID = req.UserID
-	if err := h.db.Save(&job).Error; err != nil {
+	job.FolderID = nil
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Model(&job).Association("Tags").Clear(); err != nil {
+			return err
+		}
+		return tx.Save(&job).Error
+	})
+	if err != nil {
 		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to assign job"})
 		return
 	}
+	tagging.Apply(h.db, &job)
 
 	// Reload with user data
 	h.db.Preload("User").First(&job, "id = ?", jobID)
 
 	c.JSON(http.StatusOK, job)
 }
+
+// setDigestHeader advertises the recorded SHA-256 checksum of a file (RFC 3230)
+func setDigestHeader(c *gin.Context, checksum string) {
+	sum, err := hex.DecodeString(checksum)
+	if err != nil || len(sum) != sha256.Size {
+		return
+	}
+	c.Header("Digest", "sha-256="+base64.StdEncoding.EncodeToString(sum))
+}
+
+// serveFile sends a stored job file, either directly or via a presigned redirect
+func (h *JobHandler) serveFile(c *gin.Context, job *models.PrintJob, key, checksum string) {
+	serveJobFile(c, h.store, h.storage.S3.PresignDownloads, job, key, checksum)
+}
+
+// serveJobFile streams a stored job file, or redirects to a presigned URL if presign is set
+func serveJobFile(c *gin.Context, store *storage.JobStore, presign bool, job *models.PrintJob, key, checksum string) {
+	ctx := c.Request.Context()
+
+	if presign {
+		if url, err := store.Presign(ctx, job, key, 15*time.Minute); err == nil {
+			c.Redirect(http.StatusFound, url)
+			return
+		}
+	}
+
+	reader, err := store.Open(ctx, job, key)
+	if errors.Is(err, storage.ErrNotFound) {
+		c.JSON(http.StatusNotFound, gin.H{"error": "file not found"})
+		return
+	}
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer reader.Close()
+
+	setDigestHeader(c, checksum)
+
+	// Unencrypted local files support range requests
+	if seeker, ok := reader.(io.ReadSeeker); ok {
+		http.ServeContent(c.Writer, c.Request, path.Base(key), time.Time{}, seeker)
+		return
+	}
+
+	size, err := store.Size(ctx, job, key)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+		return
+	}
+	contentType := mime.TypeByExtension(path.Ext(key))
+	if contentType == "" {
+		contentType = "application/octet-stream"
+	}
+	c.DataFromReader(http.StatusOK, size, contentType, reader, nil)
+}
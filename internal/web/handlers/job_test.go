@@ -0,0 +1,85 @@
+package handlers
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/database"
+	"github.com/alex4386/zikzi/internal/maintenance"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/quota"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+func TestRestoreJob(t *testing.T) {
+	gin.SetMode(gin.TestMode)
+	db, err := database.Connect(config.DatabaseConfig{Driver: "sqlite", DSN: filepath.Join(t.TempDir(), "zikzi.db")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := database.Migrate(db); err != nil {
+		t.Fatal(err)
+	}
+	for _, user := range []models.User{
+		{ID: "alice", Username: "alice", Email: "alice@example.com", QuotaBytes: 1000},
+		{ID: "bob", Username: "bob", Email: "bob@example.com", QuotaBytes: 1000},
+	} {
+		if err := db.Create(&user).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// addJob stores a job of size bytes for userID, in the trash since deletedAgo unless it is 0
+	addJob := func(id, userID string, size int64, deletedAgo time.Duration) {
+		job := models.PrintJob{ID: id, UserID: userID, OriginalFile: id + ".ps", FileSize: size}
+		if deletedAgo > 0 {
+			job.DeletedAt = gorm.DeletedAt{Time: time.Now().Add(-deletedAgo), Valid: true}
+		}
+		if err := db.Create(&job).Error; err != nil {
+			t.Fatal(err)
+		}
+	}
+	addJob("recent", "alice", 400, time.Hour)
+	addJob("expired", "alice", 400, 8*24*time.Hour)
+	addJob("overquota", "bob", 400, time.Hour)
+	addJob("live", "bob", 800, 0)
+
+	trash := maintenance.NewTrash(config.TrashConfig{Period: 7 * 24 * time.Hour}, db, nil)
+	handler := NewJobHandler(db, config.StorageConfig{}, nil, nil, trash, quota.NewChecker(config.QuotaConfig{}, db), nil)
+
+	tests := []struct {
+		jobID  string
+		userID string
+		want   int
+	}{
+		{"recent", "alice", http.StatusOK},
+		{"expired", "alice", http.StatusGone},
+		{"overquota", "bob", http.StatusForbidden},
+	}
+	for _, tt := range tests {
+		t.Run(tt.jobID, func(t *testing.T) {
+			w := httptest.NewRecorder()
+			c, _ := gin.CreateTestContext(w)
+			c.Set("user_id", tt.userID)
+			c.Set("is_admin", false)
+			c.Params = gin.Params{{Key: "id", Value: tt.jobID}}
+			c.Request = httptest.NewRequest(http.MethodPost, "/api/v1/jobs/"+tt.jobID+"/restore", nil)
+
+			handler.RestoreJob(c)
+			if w.Code != tt.want {
+				t.Fatalf("RestoreJob(%s) = %d, want %d: %s", tt.jobID, w.Code, tt.want, w.Body.String())
+			}
+
+			var restored int64
+			db.Model(&models.PrintJob{}).Where("id = ?", tt.jobID).Count(&restored)
+			if wantRestored := tt.want == http.StatusOK; (restored == 1) != wantRestored {
+				t.Errorf("job %s restored = %v, want %v", tt.jobID, restored == 1, wantRestored)
+			}
+		})
+	}
+}
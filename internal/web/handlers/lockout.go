@@ -0,0 +1,86 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alex4386/zikzi/internal/auth"
+	"github.com/gin-gonic/gin"
+)
+
+type LockoutHandler struct {
+	limiter *auth.Limiter // nil when lockouts are disabled
+}
+
+func NewLockoutHandler(limiter *auth.Limiter) *LockoutHandler {
+	return &LockoutHandler{limiter: limiter}
+}
+
+// LockoutListResponse represents the accounts and IP addresses with failed logins
+type LockoutListResponse struct {
+	Enabled  bool           `json:"enabled" example:"true"` // Whether lockouts are enabled
+	Lockouts []auth.Lockout `json:"lockouts"`
+}
+
+// ClearLockoutQuery selects the lockout to clear
+type ClearLockoutQuery struct {
+	User  string `form:"user" example:"alice"`
+	Share string `form:"share" example:"abc123def456"` // Share link ID
+	IP    string `form:"ip" example:"192.168.1.100"`
+}
+
+// ListLockouts lists accounts and IP addresses with recent failed logins
+// @Summary List login lockouts
+// @Description List accounts and IP addresses with recent failed logins and whether they're locked out. The state is kept in memory and resets when the server restarts (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} LockoutListResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /admin/lockouts [get]
+func (h *LockoutHandler) ListLockouts(c *gin.Context) {
+	c.JSON(http.StatusOK, LockoutListResponse{Enabled: h.limiter != nil, Lockouts: h.limiter.List()})
+}
+
+// ClearLockout unlocks an account, share link or IP address
+// @Summary Clear login lockout
+// @Description Forget the failed logins and lockout of an account, share link password or IP address (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Param user query string false "Username"
+// @Param share query string false "Share link ID"
+// @Param ip query string false "IP address"
+// @Success 200 {object} map[string]string
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /admin/lockouts [delete]
+func (h *LockoutHandler) ClearLockout(c *gin.Context) {
+	var query ClearLockoutQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	var kind, value string
+	given := 0
+	for k, v := range map[string]string{auth.LockoutUser: query.User, auth.LockoutShare: query.Share, auth.LockoutIP: query.IP} {
+		if v != "" {
+			kind, value = k, v
+			given++
+		}
+	}
+	if given != 1 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "specify one of user, share or ip"})
+		return
+	}
+
+	cleared := h.limiter.Clear(kind, value)
+	if !cleared {
+		c.JSON(http.StatusNotFound, gin.H{"error": "no failed logins recorded"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "lockout cleared"})
+}
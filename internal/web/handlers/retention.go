@@ -0,0 +1,44 @@
+package handlers
+
+import (
+	"net/http"
+
+	"github.com/alex4386/zikzi/internal/maintenance"
+	"github.com/gin-gonic/gin"
+)
+
+type RetentionHandler struct {
+	enabled   bool
+	retention *maintenance.Retention
+}
+
+func NewRetentionHandler(enabled bool, retention *maintenance.Retention) *RetentionHandler {
+	return &RetentionHandler{enabled: enabled, retention: retention}
+}
+
+// RetentionPreviewResponse represents a dry-run of the retention policies
+type RetentionPreviewResponse struct {
+	Enabled bool `json:"enabled" example:"true"` // Whether the retention task runs in the background
+	*maintenance.RetentionReport
+}
+
+// PreviewRetention reports what the retention policies would delete (admin only)
+// @Summary Preview retention
+// @Description Dry-run the configured retention policies and list the jobs and files they would delete (admin only)
+// @Tags admin
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} RetentionPreviewResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /admin/retention/preview [get]
+func (h *RetentionHandler) PreviewRetention(c *gin.Context) {
+	report, err := h.retention.Plan(c.Request.Context())
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to evaluate retention policies"})
+		return
+	}
+
+	c.JSON(http.StatusOK, RetentionPreviewResponse{Enabled: h.enabled, RetentionReport: report})
+}
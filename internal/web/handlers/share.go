@@ -0,0 +1,450 @@
+package handlers
+
+import (
+	"net/http"
+	"strconv"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/auth"
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"github.com/alex4386/zikzi/internal/utils"
+	"github.com/alex4386/zikzi/internal/web/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type ShareHandler struct {
+	db      *gorm.DB
+	config  config.SharesConfig
+	store   *storage.JobStore
+	limiter *auth.Limiter // nil when lockouts are disabled
+}
+
+// shareRefusalWindow is how long repeated refusals from one IP address are
+// folded into the same access record
+const shareRefusalWindow = time.Hour
+
+func NewShareHandler(db *gorm.DB, cfg config.SharesConfig, store *storage.JobStore, limiter *auth.Limiter) *ShareHandler {
+	return &ShareHandler{db: db, config: cfg, store: store, limiter: limiter}
+}
+
+// CreateShareRequest represents share link creation data
+type CreateShareRequest struct {
+	ExpireHours  int    `json:"expire_hours" binding:"min=0" example:"72"` // 0 = server default
+	Password     string `json:"password" example:"s3cret"`                 // Optional
+	MaxDownloads int    `json:"max_downloads" binding:"min=0" example:"5"` // 0 = unlimited
+}
+
+// ShareResponse represents a share link in API responses (without the token)
+type ShareResponse struct {
+	models.JobShare
+	HasPassword bool `json:"has_password" example:"true"`
+	Active      bool `json:"active" example:"true"`
+}
+
+// CreateShareResponse includes the link token (only shown once at creation)
+type CreateShareResponse struct {
+	ShareResponse
+	Token string `json:"token"`
+	URL   string `json:"url" example:"/api/v1/shares/3xAmpLeT0k3n/pdf"` // Public download path
+}
+
+// ShareInfoResponse describes a shared job to anonymous visitors
+type ShareInfoResponse struct {
+	DocumentName       string    `json:"document_name" example:"Report.pdf"`
+	PageCount          int       `json:"page_count" example:"3"`
+	ExpiresAt          time.Time `json:"expires_at"`
+	DownloadsRemaining *int      `json:"downloads_remaining"` // null = unlimited
+}
+
+// ListShareAccessesQuery represents query parameters for listing share accesses
+type ListShareAccessesQuery struct {
+	Page  int `form:"page,default=1" example:"1"`
+	Limit int `form:"limit,default=50" example:"50"`
+}
+
+// ListShareAccessesResponse represents the paginated access log of a share link
+type ListShareAccessesResponse struct {
+	Accesses []models.ShareAccess `json:"accesses"`
+	Total    int64                `json:"total" example:"100"`
+	Page     int                  `json:"page" example:"1"`
+	Limit    int                  `json:"limit" example:"50"`
+}
+
+func newShareResponse(share models.JobShare) ShareResponse {
+	return ShareResponse{JobShare: share, HasPassword: share.HasPassword(), Active: share.IsActive()}
+}
+
+// ownedJob loads a job the authenticated user may manage shares for
+func (h *ShareHandler) ownedJob(c *gin.Context) (*models.PrintJob, bool) {
+	query := h.db.Where("id = ?", c.Param("id"))
+	if !middleware.IsAdmin(c) {
+		query = query.Where("user_id = ?", middleware.GetUserID(c))
+	}
+
+	var job models.PrintJob
+	if err := query.First(&job).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "job not found"})
+		return nil, false
+	}
+	return &job, true
+}
+
+// jobShare loads a share link of a job the authenticated user may manage
+func (h *ShareHandler) jobShare(c *gin.Context) (*models.JobShare, bool) {
+	job, ok := h.ownedJob(c)
+	if !ok {
+		return nil, false
+	}
+
+	var share models.JobShare
+	if err := h.db.Where("id = ? AND job_id = ?", c.Param("shareId"), job.ID).First(&share).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "share not found"})
+		return nil, false
+	}
+	return &share, true
+}
+
+// CreateShare creates a public download link for a job's PDF
+// @Summary Create share link
+// @Description Create an unguessable public link to download the job's PDF without logging in. The token is only returned once.
+// @Tags shares
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Param request body CreateShareRequest true "Share options"
+// @Success 201 {object} CreateShareResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /jobs/{id}/shares [post]
+func (h *ShareHandler) CreateShare(c *gin.Context) {
+	if !h.config.Enabled {
+		c.JSON(http.StatusForbidden, gin.H{"error": "sharing is disabled"})
+		return
+	}
+
+	var req CreateShareRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	job, ok := h.ownedJob(c)
+	if !ok {
+		return
+	}
+	if job.PDFFile == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "PDF not available"})
+		return
+	}
+
+	expiry := h.config.DefaultExpiry
+	if req.ExpireHours > 0 {
+		expiry = time.Duration(req.ExpireHours) * time.Hour
+	}
+	if h.config.MaxExpiry > 0 && expiry > h.config.MaxExpiry {
+		if req.ExpireHours > 0 {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "expiry exceeds the maximum of " + h.config.MaxExpiry.String()})
+			return
+		}
+		expiry = h.config.MaxExpiry
+	}
+
+	token, err := models.GenerateShareToken()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate token"})
+		return
+	}
+
+	share := models.JobShare{
+		JobID:        job.ID,
+		UserID:       middleware.GetUserID(c),
+		TokenHash:    models.HashShareToken(token),
+		ExpiresAt:    time.Now().Add(expiry),
+		MaxDownloads: req.MaxDownloads,
+	}
+	if req.Password != "" {
+		hash, err := utils.HashPassword(req.Password)
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash password"})
+			return
+		}
+		share.PasswordHash = hash
+	}
+
+	if err := h.db.Create(&share).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create share"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, CreateShareResponse{
+		ShareResponse: newShareResponse(share),
+		Token:         token,
+		URL:           "/api/v1/shares/" + token + "/pdf",
+	})
+}
+
+// ListShares returns the share links of a job
+// @Summary List share links
+// @Description Get all share links of a job, including revoked and expired ones
+// @Tags shares
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Success 200 {array} ShareResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /jobs/{id}/shares [get]
+func (h *ShareHandler) ListShares(c *gin.Context) {
+	job, ok := h.ownedJob(c)
+	if !ok {
+		return
+	}
+
+	var shares []models.JobShare
+	if err := h.db.Where("job_id = ?", job.ID).Order("created_at DESC").Find(&shares).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch shares"})
+		return
+	}
+
+	resp := make([]ShareResponse, len(shares))
+	for i, share := range shares {
+		resp[i] = newShareResponse(share)
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// RevokeShare revokes a share link
+// @Summary Revoke share link
+// @Description Revoke a share link so it can no longer be used. Its access log is kept.
+// @Tags shares
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Param shareId path string true "Share ID"
+// @Success 200 {object} ShareResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /jobs/{id}/shares/{shareId} [delete]
+func (h *ShareHandler) RevokeShare(c *gin.Context) {
+	share, ok := h.jobShare(c)
+	if !ok {
+		return
+	}
+
+	if share.RevokedAt == nil {
+		now := time.Now()
+		share.RevokedAt = &now
+		if err := h.db.Model(share).Update("revoked_at", now).Error; err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to revoke share"})
+			return
+		}
+	}
+
+	c.JSON(http.StatusOK, newShareResponse(*share))
+}
+
+// ListShareAccesses returns the access log of a share link
+// @Summary List share link accesses
+// @Description Get the paginated log of every request made with a share link, newest first
+// @Tags shares
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Job ID"
+// @Param shareId path string true "Share ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(50)
+// @Success 200 {object} ListShareAccessesResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /jobs/{id}/shares/{shareId}/accesses [get]
+func (h *ShareHandler) ListShareAccesses(c *gin.Context) {
+	share, ok := h.jobShare(c)
+	if !ok {
+		return
+	}
+
+	var query ListShareAccessesQuery
+	if err := c.ShouldBindQuery(&query); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if query.Limit <= 0 || query.Limit > 500 {
+		query.Limit = 50
+	}
+	if query.Page <= 0 {
+		query.Page = 1
+	}
+
+	var total int64
+	accesses := []models.ShareAccess{}
+	base := h.db.Model(&models.ShareAccess{}).Where("share_id = ?", share.ID)
+	base.Count(&total)
+	if err := base.Order("id DESC").Offset((query.Page - 1) * query.Limit).Limit(query.Limit).Find(&accesses).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch accesses"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ListShareAccessesResponse{
+		Accesses: accesses,
+		Total:    total,
+		Page:     query.Page,
+		Limit:    query.Limit,
+	})
+}
+
+// logAccess records a request made with a share link. Refusals are folded
+// into a recent record of the same refusal, so repeated requests can't fill
+// the access log.
+func (h *ShareHandler) logAccess(c *gin.Context, share *models.JobShare, action string, success bool, reason string) {
+	if !success {
+		now := time.Now()
+		result := h.db.Model(&models.ShareAccess{}).
+			Where("share_id = ? AND action = ? AND ip = ? AND success = ? AND reason = ? AND created_at >= ?",
+				share.ID, action, c.ClientIP(), false, reason, now.Add(-shareRefusalWindow)).
+			UpdateColumns(map[string]interface{}{"count": gorm.Expr("count + 1"), "last_at": now})
+		if result.Error == nil && result.RowsAffected > 0 {
+			return
+		}
+	}
+
+	h.db.Create(&models.ShareAccess{
+		ShareID:   share.ID,
+		Action:    action,
+		IP:        c.ClientIP(),
+		UserAgent: c.Request.UserAgent(),
+		Success:   success,
+		Reason:    reason,
+	})
+}
+
+// openShare resolves a share token and checks that the link may be used, logging refusals
+func (h *ShareHandler) openShare(c *gin.Context, action string) (*models.JobShare, *models.PrintJob, bool) {
+	c.Header("Cache-Control", "no-store")
+	c.Header("Referrer-Policy", "no-referrer")
+
+	var share models.JobShare
+	if !h.config.Enabled ||
+		h.db.Where("token_hash = ?", models.HashShareToken(c.Param("token"))).First(&share).Error != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "share not found"})
+		return nil, nil, false
+	}
+
+	refuse := func(status int, reason, message string) (*models.JobShare, *models.PrintJob, bool) {
+		h.logAccess(c, &share, action, false, reason)
+		c.JSON(status, gin.H{"error": message})
+		return nil, nil, false
+	}
+
+	switch {
+	case share.RevokedAt != nil:
+		return refuse(http.StatusGone, "revoked", "share link has been revoked")
+	case share.IsExpired():
+		return refuse(http.StatusGone, "expired", "share link has expired")
+	case share.IsExhausted():
+		return refuse(http.StatusGone, "limit_reached", "download limit reached")
+	}
+
+	if share.HasPassword() {
+		ip := c.ClientIP()
+		if until, locked := h.limiter.CheckShare(share.ID, ip); locked {
+			c.Header("Retry-After", strconv.Itoa(int(time.Until(until).Seconds())+1))
+			return refuse(http.StatusTooManyRequests, "locked_out", "too many wrong passwords")
+		}
+
+		// Only accept the header, so passwords don't end up in access logs
+		password := c.GetHeader("X-Share-Password")
+		if password == "" {
+			return refuse(http.StatusUnauthorized, "password_required", "password required")
+		}
+		if !utils.VerifyPassword(share.PasswordHash, password) {
+			h.limiter.FailShare(share.ID, ip, "bad_password")
+			return refuse(http.StatusUnauthorized, "invalid_password", "invalid password")
+		}
+		h.limiter.SucceedShare(share.ID)
+	}
+
+	var job models.PrintJob
+	if err := h.db.Where("id = ?", share.JobID).First(&job).Error; err != nil || job.PDFFile == "" {
+		return refuse(http.StatusNotFound, "job_unavailable", "PDF not available")
+	}
+
+	return &share, &job, true
+}
+
+// GetSharedJob describes the job behind a share link
+// @Summary Get shared job
+// @Description Get details of the job behind a share link. No login required. Password-protected links need the X-Share-Password header.
+// @Tags shares
+// @Produce json
+// @Param token path string true "Share token"
+// @Param X-Share-Password header string false "Link password"
+// @Success 200 {object} ShareInfoResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /shares/{token} [get]
+func (h *ShareHandler) GetSharedJob(c *gin.Context) {
+	share, job, ok := h.openShare(c, models.ShareActionView)
+	if !ok {
+		return
+	}
+	h.logAccess(c, share, models.ShareActionView, true, "")
+
+	resp := ShareInfoResponse{
+		DocumentName: job.DocumentName,
+		PageCount:    job.PageCount,
+		ExpiresAt:    share.ExpiresAt,
+	}
+	if share.MaxDownloads > 0 {
+		remaining := share.MaxDownloads - share.DownloadCount
+		resp.DownloadsRemaining = &remaining
+	}
+
+	c.JSON(http.StatusOK, resp)
+}
+
+// DownloadSharedPDF downloads the PDF behind a share link
+// @Summary Download shared PDF
+// @Description Download the PDF behind a share link. No login required. Every successful download counts towards the link's download limit.
+// @Tags shares
+// @Produce application/pdf
+// @Param token path string true "Share token"
+// @Param X-Share-Password header string false "Link password"
+// @Success 200 {file} binary
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 410 {object} ErrorResponse
+// @Failure 429 {object} ErrorResponse
+// @Router /shares/{token}/pdf [get]
+func (h *ShareHandler) DownloadSharedPDF(c *gin.Context) {
+	share, job, ok := h.openShare(c, models.ShareActionDownload)
+	if !ok {
+		return
+	}
+
+	// Count the download atomically so concurrent requests can't exceed the limit
+	result := h.db.Model(&models.JobShare{}).
+		Where("id = ? AND (max_downloads = 0 OR download_count < max_downloads)", share.ID).
+		UpdateColumn("download_count", gorm.Expr("download_count + 1"))
+	if result.Error != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to record download"})
+		return
+	}
+	if result.RowsAffected == 0 {
+		h.logAccess(c, share, models.ShareActionDownload, false, "limit_reached")
+		c.JSON(http.StatusGone, gin.H{"error": "download limit reached"})
+		return
+	}
+	h.logAccess(c, share, models.ShareActionDownload, true, "")
+
+	// Always streamed: a presigned URL would outlive revocation, expiry and the download limit
+	serveJobFile(c, h.store, false, job, job.PDFFile, job.PDFSHA256)
+}
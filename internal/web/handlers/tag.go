@@ -0,0 +1,292 @@
+package handlers
+
+import (
+	"net/http"
+	"strings"
+
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/tagging"
+	"github.com/alex4386/zikzi/internal/web/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+type TagHandler struct {
+	db *gorm.DB
+}
+
+func NewTagHandler(db *gorm.DB) *TagHandler {
+	return &TagHandler{db: db}
+}
+
+// TagRequest represents tag data
+type TagRequest struct {
+	Name  string `json:"name" binding:"required,max=50" example:"receipts"`
+	Color string `json:"color" binding:"omitempty,hexcolor" example:"#ff8800"`
+}
+
+// TagRuleRequest represents tag rule data
+type TagRuleRequest struct {
+	TagID   string `json:"tag_id" binding:"required" example:"abc123def456"`
+	Field   string `json:"field" binding:"required" example:"app_name"` // document_name, app_name, hostname, source_ip, queue
+	Pattern string `json:"pattern" binding:"required" example:"(?i)excel"`
+	Enabled *bool  `json:"enabled" example:"true"`
+}
+
+// ListTags returns the authenticated user's tags
+// @Summary List tags
+// @Description Get all tags of the authenticated user
+// @Tags tags
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.Tag
+// @Failure 401 {object} ErrorResponse
+// @Router /tags [get]
+func (h *TagHandler) ListTags(c *gin.Context) {
+	var tags []models.Tag
+	if err := h.db.Where("user_id = ?", middleware.GetUserID(c)).Order("name").Find(&tags).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch tags"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tags)
+}
+
+// CreateTag creates a tag
+// @Summary Create tag
+// @Description Create a tag for labelling print jobs
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TagRequest true "Tag data"
+// @Success 201 {object} models.Tag
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /tags [post]
+func (h *TagHandler) CreateTag(c *gin.Context) {
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	tag := models.Tag{UserID: middleware.GetUserID(c), Name: strings.TrimSpace(req.Name), Color: req.Color}
+	if err := h.db.Create(&tag).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "tag already exists"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, tag)
+}
+
+// UpdateTag renames or recolors a tag
+// @Summary Update tag
+// @Description Update a tag of the authenticated user
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Tag ID"
+// @Param request body TagRequest true "Tag data"
+// @Success 200 {object} models.Tag
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 409 {object} ErrorResponse
+// @Router /tags/{id} [put]
+func (h *TagHandler) UpdateTag(c *gin.Context) {
+	var req TagRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var tag models.Tag
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("id"), middleware.GetUserID(c)).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return
+	}
+
+	tag.Name = strings.TrimSpace(req.Name)
+	tag.Color = req.Color
+	if err := h.db.Save(&tag).Error; err != nil {
+		c.JSON(http.StatusConflict, gin.H{"error": "tag already exists"})
+		return
+	}
+
+	c.JSON(http.StatusOK, tag)
+}
+
+// DeleteTag removes a tag from all jobs and deletes it along with its rules
+// @Summary Delete tag
+// @Description Delete a tag of the authenticated user. Jobs keep existing but lose the tag.
+// @Tags tags
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Tag ID"
+// @Success 200 {object} MessageResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /tags/{id} [delete]
+func (h *TagHandler) DeleteTag(c *gin.Context) {
+	var tag models.Tag
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("id"), middleware.GetUserID(c)).First(&tag).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag not found"})
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Table("print_job_tags").Where("tag_id = ?", tag.ID).Delete(nil).Error; err != nil {
+			return err
+		}
+		if err := tx.Where("tag_id = ?", tag.ID).Delete(&models.TagRule{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(&tag).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete tag"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tag deleted"})
+}
+
+// ListTagRules returns the authenticated user's auto-tagging rules
+// @Summary List tag rules
+// @Description Get all auto-tagging rules of the authenticated user
+// @Tags tags
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {array} models.TagRule
+// @Failure 401 {object} ErrorResponse
+// @Router /tag-rules [get]
+func (h *TagHandler) ListTagRules(c *gin.Context) {
+	var rules []models.TagRule
+	if err := h.db.Preload("Tag").Where("user_id = ?", middleware.GetUserID(c)).Order("created_at").Find(&rules).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch tag rules"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rules)
+}
+
+// CreateTagRule creates an auto-tagging rule
+// @Summary Create tag rule
+// @Description Create a rule that tags new jobs whose field matches a regular expression
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body TagRuleRequest true "Tag rule data"
+// @Success 201 {object} models.TagRule
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Router /tag-rules [post]
+func (h *TagHandler) CreateTagRule(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req TagRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	rule := models.TagRule{UserID: userID, Enabled: true}
+	if !h.bindRule(c, userID, &req, &rule) {
+		return
+	}
+
+	if err := h.db.Create(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create tag rule"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, rule)
+}
+
+// UpdateTagRule updates an auto-tagging rule
+// @Summary Update tag rule
+// @Description Update an auto-tagging rule of the authenticated user
+// @Tags tags
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Tag rule ID"
+// @Param request body TagRuleRequest true "Tag rule data"
+// @Success 200 {object} models.TagRule
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /tag-rules/{id} [put]
+func (h *TagHandler) UpdateTagRule(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req TagRuleRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var rule models.TagRule
+	if err := h.db.Where("id = ? AND user_id = ?", c.Param("id"), userID).First(&rule).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag rule not found"})
+		return
+	}
+	if !h.bindRule(c, userID, &req, &rule) {
+		return
+	}
+
+	if err := h.db.Save(&rule).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update tag rule"})
+		return
+	}
+
+	c.JSON(http.StatusOK, rule)
+}
+
+// DeleteTagRule deletes an auto-tagging rule
+// @Summary Delete tag rule
+// @Description Delete an auto-tagging rule of the authenticated user. Tags already applied are kept.
+// @Tags tags
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Tag rule ID"
+// @Success 200 {object} MessageResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /tag-rules/{id} [delete]
+func (h *TagHandler) DeleteTagRule(c *gin.Context) {
+	result := h.db.Where("id = ? AND user_id = ?", c.Param("id"), middleware.GetUserID(c)).Delete(&models.TagRule{})
+	if result.RowsAffected == 0 {
+		c.JSON(http.StatusNotFound, gin.H{"error": "tag rule not found"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "tag rule deleted"})
+}
+
+// bindRule validates a rule request and copies it into rule
+func (h *TagHandler) bindRule(c *gin.Context, userID string, req *TagRuleRequest, rule *models.TagRule) bool {
+	if err := tagging.ValidateRule(req.Field, req.Pattern); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return false
+	}
+
+	var tag models.Tag
+	if err := h.db.Where("id = ? AND user_id = ?", req.TagID, userID).First(&tag).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "tag not found"})
+		return false
+	}
+
+	rule.TagID = tag.ID
+	rule.Tag = &tag
+	rule.Field = req.Field
+	rule.Pattern = req.Pattern
+	if req.Enabled != nil {
+		rule.Enabled = *req.Enabled
+	}
+	return true
+}
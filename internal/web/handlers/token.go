@@ -11,11 +11,19 @@ import (
 )
 
 type TokenHandler struct {
-	db *gorm.DB
+	db    *gorm.DB
+	realm string // IPP Digest auth realm the tokens are hashed for
 }
 
-func NewTokenHandler(db *gorm.DB) *TokenHandler {
-	return &TokenHandler{db: db}
+func NewTokenHandler(db *gorm.DB, realm string) *TokenHandler {
+	return &TokenHandler{db: db, realm: realm}
+}
+
+// TokenScope represents the optional restrictions of an IPP token
+type TokenScope struct {
+	Queues      []string `json:"queues" example:"print"`                // IPP printer names the token may print to (empty = all)
+	SourceCIDRs []string `json:"source_cidrs" example:"192.168.1.0/24"` // Networks the token may be used from (empty = anywhere)
+	SubmitOnly  bool     `json:"submit_only" example:"false"`           // Only submit jobs, no listing or cancelling
 }
 
 // CreateTokenRequest represents token creation data
@@ -23,6 +31,21 @@ type CreateTokenRequest struct {
 	Name       string `json:"name" binding:"required" example:"My Laptop"`
 	ExpireDays int    `json:"expire_days" example:"90"` // 0 = never expires
 	UserID     string `json:"user_id" example:"abc123"` // Admin only: create on behalf of user
+	TokenScope
+}
+
+// ListTokenUsageQuery represents query parameters for listing token usage
+type ListTokenUsageQuery struct {
+	Page  int `form:"page,default=1" example:"1"`
+	Limit int `form:"limit,default=50" example:"50"`
+}
+
+// ListTokenUsageResponse represents the paginated usage history of an IPP token
+type ListTokenUsageResponse struct {
+	Usage []models.IPPTokenUsage `json:"usage"`
+	Total int64                  `json:"total" example:"100"`
+	Page  int                    `json:"page" example:"1"`
+	Limit int                    `json:"limit" example:"50"`
 }
 
 // IPPTokenResponse represents an IPP token in API responses (without the actual token value)
@@ -32,9 +55,19 @@ type IPPTokenResponse struct {
 	Name       string     `json:"name"`
 	LastUsedAt *time.Time `json:"last_used_at"`
 	LastUsedIP string     `json:"last_used_ip"`
+	Realm      string     `json:"digest_realm"` // Realm the token works with for Digest auth
 	ExpiresAt  *time.Time `json:"expires_at"`
 	IsActive   bool       `json:"is_active"`
 	CreatedAt  time.Time  `json:"created_at"`
+	TokenScope
+}
+
+func newTokenScope(token models.IPPToken) TokenScope {
+	return TokenScope{
+		Queues:      append([]string{}, token.QueueList()...),
+		SourceCIDRs: append([]string{}, token.SourceCIDRList()...),
+		SubmitOnly:  token.SubmitOnly,
+	}
 }
 
 // CreateIPPTokenResponse includes the token value (only shown once at creation)
@@ -128,9 +161,11 @@ func (h *TokenHandler) ListTokens(c *gin.Context) {
 			Name:       t.Name,
 			LastUsedAt: t.LastUsedAt,
 			LastUsedIP: t.LastUsedIP,
+			Realm:      t.DigestRealm,
 			ExpiresAt:  t.ExpiresAt,
 			IsActive:   t.IsActive,
 			CreatedAt:  t.CreatedAt,
+			TokenScope: newTokenScope(t),
 		}
 	}
 
@@ -167,15 +202,16 @@ func (h *TokenHandler) CreateToken(c *gin.Context) {
 	// Admin can create on behalf of another user
 	targetUserID := userID
 	if req.UserID != "" && isAdmin {
-		// Verify the target user exists
-		var targetUser models.User
-		if err := h.db.Where("id = ?", req.UserID).First(&targetUser).Error; err != nil {
-			c.JSON(http.StatusBadRequest, gin.H{"error": "target user not found"})
-			return
-		}
 		targetUserID = req.UserID
 	}
 
+	// Verify the target user exists
+	var targetUser models.User
+	if err := h.db.Where("id = ?", targetUserID).First(&targetUser).Error; err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "target user not found"})
+		return
+	}
+
 	// Generate token
 	tokenValue, err := models.GenerateIPPToken()
 	if err != nil {
@@ -186,9 +222,16 @@ func (h *TokenHandler) CreateToken(c *gin.Context) {
 	token := models.IPPToken{
 		UserID:   targetUserID,
 		Name:     req.Name,
-		Token:    tokenValue,
 		IsActive: true,
 	}
+	if err := token.SetSecret(targetUser.Username, h.realm, tokenValue); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to hash token"})
+		return
+	}
+	if err := token.SetScope(req.Queues, req.SourceCIDRs, req.SubmitOnly); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
 
 	// Set expiration if specified
 	if req.ExpireDays > 0 {
@@ -209,9 +252,11 @@ func (h *TokenHandler) CreateToken(c *gin.Context) {
 			Name:       token.Name,
 			LastUsedAt: token.LastUsedAt,
 			LastUsedIP: token.LastUsedIP,
+			Realm:      token.DigestRealm,
 			ExpiresAt:  token.ExpiresAt,
 			IsActive:   token.IsActive,
 			CreatedAt:  token.CreatedAt,
+			TokenScope: newTokenScope(token),
 		},
 		Token: tokenValue,
 	})
@@ -249,9 +294,65 @@ func (h *TokenHandler) GetToken(c *gin.Context) {
 		Name:       token.Name,
 		LastUsedAt: token.LastUsedAt,
 		LastUsedIP: token.LastUsedIP,
+		Realm:      token.DigestRealm,
+		ExpiresAt:  token.ExpiresAt,
+		IsActive:   token.IsActive,
+		CreatedAt:  token.CreatedAt,
+		TokenScope: newTokenScope(token),
+	})
+}
+
+// UpdateTokenScope replaces the restrictions of an IPP token
+// @Summary Update IPP token scope
+// @Description Replace the queues, source networks and operations an IPP token is restricted to (admin can update any)
+// @Tags tokens
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Token ID"
+// @Param request body TokenScope true "Token restrictions"
+// @Success 200 {object} IPPTokenResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /tokens/{id}/scope [put]
+func (h *TokenHandler) UpdateTokenScope(c *gin.Context) {
+	var req TokenScope
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var token models.IPPToken
+	query := h.db.Where("id = ?", c.Param("id"))
+	if !middleware.IsAdmin(c) {
+		query = query.Where("user_id = ?", middleware.GetUserID(c))
+	}
+	if err := query.First(&token).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+
+	if err := token.SetScope(req.Queues, req.SourceCIDRs, req.SubmitOnly); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if err := h.db.Model(&token).Select("queues", "source_cidrs", "submit_only").Updates(&token).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update token"})
+		return
+	}
+
+	c.JSON(http.StatusOK, IPPTokenResponse{
+		ID:         token.ID,
+		UserID:     token.UserID,
+		Name:       token.Name,
+		LastUsedAt: token.LastUsedAt,
+		LastUsedIP: token.LastUsedIP,
+		Realm:      token.DigestRealm,
 		ExpiresAt:  token.ExpiresAt,
 		IsActive:   token.IsActive,
 		CreatedAt:  token.CreatedAt,
+		TokenScope: newTokenScope(token),
 	})
 }
 
@@ -323,3 +424,56 @@ func (h *TokenHandler) DeleteToken(c *gin.Context) {
 
 	c.JSON(http.StatusOK, gin.H{"message": "token deleted"})
 }
+
+// ListTokenUsage returns the usage history of an IPP token
+// @Summary List IPP token usage
+// @Description Get the paginated history of successful authentications with an IPP token, newest first (admin can access any)
+// @Tags tokens
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Token ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(50)
+// @Success 200 {object} ListTokenUsageResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /tokens/{id}/usage [get]
+func (h *TokenHandler) ListTokenUsage(c *gin.Context) {
+	var token models.IPPToken
+	query := h.db.Where("id = ?", c.Param("id"))
+	if !middleware.IsAdmin(c) {
+		query = query.Where("user_id = ?", middleware.GetUserID(c))
+	}
+	if err := query.First(&token).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "token not found"})
+		return
+	}
+
+	var params ListTokenUsageQuery
+	if err := c.ShouldBindQuery(&params); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if params.Limit <= 0 || params.Limit > 500 {
+		params.Limit = 50
+	}
+	if params.Page <= 0 {
+		params.Page = 1
+	}
+
+	var total int64
+	usage := []models.IPPTokenUsage{}
+	base := h.db.Model(&models.IPPTokenUsage{}).Where("token_id = ?", token.ID)
+	base.Count(&total)
+	if err := base.Order("id DESC").Offset((params.Page - 1) * params.Limit).Limit(params.Limit).Find(&usage).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch token usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ListTokenUsageResponse{
+		Usage: usage,
+		Total: total,
+		Page:  params.Page,
+		Limit: params.Limit,
+	})
+}
@@ -0,0 +1,185 @@
+package handlers
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/forward"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/printer"
+	"github.com/alex4386/zikzi/internal/quota"
+	"github.com/alex4386/zikzi/internal/storage"
+	"github.com/alex4386/zikzi/internal/web/middleware"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// UploadAppName is recorded as the application of jobs uploaded through the web API
+const UploadAppName = "Web Upload"
+
+type UploadHandler struct {
+	db        *gorm.DB
+	store     *storage.JobStore
+	processor *printer.Processor
+	quotas    *quota.Checker
+	events    *events.Bus
+	forwarder *forward.Forwarder
+	maxSize   int64
+}
+
+func NewUploadHandler(db *gorm.DB, store *storage.JobStore, processor *printer.Processor, quotas *quota.Checker, bus *events.Bus, forwarder *forward.Forwarder, maxSize int64) *UploadHandler {
+	return &UploadHandler{db: db, store: store, processor: processor, quotas: quotas, events: bus, forwarder: forwarder, maxSize: maxSize}
+}
+
+// detectUploadType returns the file extension for a supported upload, judged by its content
+func detectUploadType(head []byte) (string, bool) {
+	switch {
+	case bytes.HasPrefix(head, []byte("%PDF-")):
+		return ".pdf", true
+	case bytes.HasPrefix(head, []byte("%!")):
+		return ".ps", true
+	}
+
+	switch contentType := http.DetectContentType(head); {
+	case contentType == "image/png":
+		return ".png", true
+	case contentType == "image/jpeg":
+		return ".jpg", true
+	case contentType == "image/gif":
+		return ".gif", true
+	case strings.HasPrefix(contentType, "text/plain"):
+		return ".txt", true
+	}
+	return "", false
+}
+
+// UploadJob creates a print job from an uploaded document
+// @Summary Upload document
+// @Description Upload a PDF, PostScript, PNG, JPEG, GIF or plain text file. It becomes a print job owned by the caller and goes through the same conversion as printed jobs.
+// @Tags jobs
+// @Accept multipart/form-data
+// @Produce json
+// @Security BearerAuth
+// @Param file formData file true "Document to upload"
+// @Param document_name formData string false "Document name (defaults to the file name)"
+// @Param forward_to formData string false "Comma-separated downstream printers to also print on"
+// @Success 202 {object} models.PrintJob
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 413 {object} ErrorResponse
+// @Failure 415 {object} ErrorResponse
+// @Router /jobs [post]
+func (h *UploadHandler) UploadJob(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+	ctx := c.Request.Context()
+
+	// Leave room for the multipart framing around the file
+	c.Request.Body = http.MaxBytesReader(c.Writer, c.Request.Body, h.maxSize+1<<20)
+
+	fileHeader, err := c.FormFile("file")
+	if err != nil {
+		var tooLarge *http.MaxBytesError
+		if errors.As(err, &tooLarge) {
+			c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds the maximum size of %d bytes", h.maxSize)})
+			return
+		}
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is required"})
+		return
+	}
+	if fileHeader.Size > h.maxSize {
+		c.JSON(http.StatusRequestEntityTooLarge, gin.H{"error": fmt.Sprintf("file exceeds the maximum size of %d bytes", h.maxSize)})
+		return
+	}
+	if fileHeader.Size == 0 {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "file is empty"})
+		return
+	}
+
+	file, err := fileHeader.Open()
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "failed to read file"})
+		return
+	}
+	defer file.Close()
+
+	head := make([]byte, 512)
+	n, _ := io.ReadFull(file, head)
+	ext, ok := detectUploadType(head[:n])
+	if !ok {
+		c.JSON(http.StatusUnsupportedMediaType, gin.H{"error": "unsupported file type, expected PDF, PostScript, PNG, JPEG, GIF or plain text"})
+		return
+	}
+	if _, err := file.Seek(0, io.SeekStart); err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to read file"})
+		return
+	}
+
+	forwardTo, err := h.forwarder.ParsePrinters(c.PostForm("forward_to"))
+	if err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	if err := h.quotas.Check(ctx, userID, fileHeader.Size); err != nil {
+		var exceeded *quota.ExceededError
+		if errors.As(err, &exceeded) {
+			c.JSON(http.StatusForbidden, gin.H{"error": exceeded.Message})
+			return
+		}
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to check quota"})
+		return
+	}
+
+	documentName := strings.TrimSpace(c.PostForm("document_name"))
+	if documentName == "" {
+		documentName = fileHeader.Filename
+	}
+
+	job := &models.PrintJob{
+		UserID:       userID,
+		SourceIP:     c.ClientIP(),
+		DocumentName: documentName,
+		AppName:      UploadAppName,
+		Queue:        models.JobQueueWeb,
+		Status:       models.JobStatusReceived,
+		ForwardTo:    strings.Join(forwardTo, ","),
+	}
+	if err := h.db.Create(job).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create job"})
+		return
+	}
+	h.events.Publish(events.JobCreated, job)
+
+	hash := sha256.New()
+	key := storage.JobKey(fmt.Sprintf("%s_%s%s", job.ID, time.Now().Format("20060102_150405"), ext))
+	if err := h.store.Put(ctx, job, key, io.TeeReader(file, hash), fileHeader.Size); err != nil {
+		h.db.Unscoped().Delete(job)
+		h.events.Publish(events.JobDeleted, job)
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to store file"})
+		return
+	}
+
+	job.OriginalFile = key
+	job.OriginalSHA256 = hex.EncodeToString(hash.Sum(nil))
+	job.FileSize = fileHeader.Size
+	job.Status = models.JobStatusProcessing
+	h.db.Save(job)
+	h.events.Publish(events.JobProcessing, job)
+
+	// Respond with a copy, the processor updates the job concurrently
+	resp := *job
+
+	// Queue for PDF conversion (async)
+	go h.processor.Process(job)
+
+	c.JSON(http.StatusAccepted, resp)
+}
@@ -4,6 +4,7 @@ import (
 	"net/http"
 
 	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/quota"
 	"github.com/alex4386/zikzi/internal/utils"
 	"github.com/alex4386/zikzi/internal/web/middleware"
 	"github.com/gin-gonic/gin"
@@ -11,11 +12,19 @@ import (
 )
 
 type UserHandler struct {
-	db *gorm.DB
+	db          *gorm.DB
+	quotas      *quota.Checker
+	mailEnabled bool
 }
 
-func NewUserHandler(db *gorm.DB) *UserHandler {
-	return &UserHandler{db: db}
+func NewUserHandler(db *gorm.DB, quotas *quota.Checker, mailEnabled bool) *UserHandler {
+	return &UserHandler{db: db, quotas: quotas, mailEnabled: mailEnabled}
+}
+
+// UsageResponse represents the user's quota usage. Limits of 0 are unlimited.
+type UsageResponse struct {
+	Usage  quota.Usage  `json:"usage"`
+	Limits quota.Limits `json:"limits"`
 }
 
 // UpdateUserRequest represents user update data
@@ -46,6 +55,35 @@ func (h *UserHandler) GetCurrentUser(c *gin.Context) {
 	c.JSON(http.StatusOK, user)
 }
 
+// GetUsage returns the authenticated user's quota usage
+// @Summary Get quota usage
+// @Description Get the authenticated user's stored bytes, jobs today and pages this month along with their quotas
+// @Tags users
+// @Produce json
+// @Security BearerAuth
+// @Success 200 {object} UsageResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Failure 500 {object} ErrorResponse
+// @Router /users/me/usage [get]
+func (h *UserHandler) GetUsage(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	usage, err := h.quotas.UsageFor(c.Request.Context(), user.ID)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to compute usage"})
+		return
+	}
+
+	c.JSON(http.StatusOK, UsageResponse{Usage: *usage, Limits: h.quotas.LimitsFor(&user)})
+}
+
 // UpdateCurrentUser updates the authenticated user's profile
 // @Summary Update current user
 // @Description Update the authenticated user's profile information
@@ -187,3 +225,57 @@ func (h *UserHandler) UpdateIPPSettings(c *gin.Context) {
 
 	c.JSON(http.StatusOK, user)
 }
+
+// UpdateEmailSettingsRequest represents the request to update email settings
+type UpdateEmailSettingsRequest struct {
+	EmailPrints bool `json:"email_prints" example:"true"` // Email the PDF of every completed job
+}
+
+// UpdateEmailSettings updates whether the authenticated user gets their prints by email
+// @Summary Update email settings
+// @Description Update whether the PDF of every completed job is emailed to the user's address. PDFs over the attachment limit are sent as a share link.
+// @Tags users
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body UpdateEmailSettingsRequest true "Email settings data"
+// @Success 200 {object} models.User
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /users/me/email-settings [put]
+func (h *UserHandler) UpdateEmailSettings(c *gin.Context) {
+	userID := middleware.GetUserID(c)
+
+	var req UpdateEmailSettingsRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	var user models.User
+	if err := h.db.First(&user, "id = ?", userID).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "user not found"})
+		return
+	}
+
+	if req.EmailPrints {
+		if !h.mailEnabled {
+			c.JSON(http.StatusForbidden, gin.H{"error": "email delivery is not enabled on this server"})
+			return
+		}
+		if user.Email == "" {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "set an email address first"})
+			return
+		}
+	}
+
+	user.EmailPrints = req.EmailPrints
+	if err := h.db.Save(&user).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update settings"})
+		return
+	}
+
+	c.JSON(http.StatusOK, user)
+}
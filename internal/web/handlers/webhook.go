@@ -0,0 +1,398 @@
+package handlers
+
+import (
+	"net/http"
+	"net/url"
+	"strings"
+
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/web/middleware"
+	"github.com/alex4386/zikzi/internal/webhook"
+	"github.com/gin-gonic/gin"
+	"gorm.io/gorm"
+)
+
+// defaultWebhookEvents are subscribed to when a webhook is created without events
+var defaultWebhookEvents = []string{events.JobCompleted, events.JobFailed}
+
+type WebhookHandler struct {
+	db         *gorm.DB
+	dispatcher *webhook.Dispatcher
+}
+
+func NewWebhookHandler(db *gorm.DB, dispatcher *webhook.Dispatcher) *WebhookHandler {
+	return &WebhookHandler{db: db, dispatcher: dispatcher}
+}
+
+// WebhookRequest represents webhook data
+type WebhookRequest struct {
+	Name      string   `json:"name" binding:"required" example:"Archive"`
+	URL       string   `json:"url" binding:"required" example:"https://example.com/hooks/zikzi"`
+	Events    []string `json:"events" example:"job.completed,job.failed"` // Defaults to job.completed and job.failed
+	AttachPDF bool     `json:"attach_pdf" example:"false"`                // Send the PDF as multipart/form-data
+	Enabled   *bool    `json:"enabled" example:"true"`                    // Defaults to true
+}
+
+// CreateWebhookRequest represents webhook creation data
+type CreateWebhookRequest struct {
+	WebhookRequest
+	Global bool `json:"global" example:"false"` // Admin only: receive events for all users' jobs
+}
+
+// UpdateWebhookRequest represents webhook update data
+type UpdateWebhookRequest struct {
+	WebhookRequest
+	RotateSecret bool `json:"rotate_secret" example:"false"` // Generate a new signing secret
+}
+
+// WebhookSecretResponse includes the signing secret (only shown on creation and rotation)
+type WebhookSecretResponse struct {
+	models.Webhook
+	Secret string `json:"secret"`
+}
+
+// ListWebhooksQuery represents query parameters for listing webhooks
+type ListWebhooksQuery struct {
+	Full bool `form:"full" example:"false"` // Admin only: include all users' webhooks
+}
+
+// ListDeliveriesQuery represents query parameters for listing webhook deliveries
+type ListDeliveriesQuery struct {
+	Page   int    `form:"page,default=1" example:"1"`
+	Limit  int    `form:"limit,default=50" example:"50"`
+	Status string `form:"status" example:"failed"` // pending, succeeded or failed
+}
+
+// ListDeliveriesResponse represents the paginated delivery log of a webhook
+type ListDeliveriesResponse struct {
+	Deliveries []models.WebhookDelivery `json:"deliveries"`
+	Total      int64                    `json:"total" example:"100"`
+	Page       int                      `json:"page" example:"1"`
+	Limit      int                      `json:"limit" example:"50"`
+}
+
+// bindWebhook validates the request and copies it onto the webhook
+func bindWebhook(c *gin.Context, req *WebhookRequest, hook *models.Webhook) bool {
+	target, err := url.Parse(strings.TrimSpace(req.URL))
+	if err != nil || (target.Scheme != "http" && target.Scheme != "https") || target.Host == "" {
+		c.JSON(http.StatusBadRequest, gin.H{"error": "url must be an absolute http or https URL"})
+		return false
+	}
+
+	list := req.Events
+	if len(list) == 0 {
+		list = defaultWebhookEvents
+	}
+	for _, event := range list {
+		known := false
+		for _, e := range webhook.Events {
+			if event == e {
+				known = true
+				break
+			}
+		}
+		if !known {
+			c.JSON(http.StatusBadRequest, gin.H{"error": "unknown event type: " + event})
+			return false
+		}
+	}
+
+	hook.Name = strings.TrimSpace(req.Name)
+	hook.URL = target.String()
+	hook.Events = strings.Join(list, ",")
+	hook.AttachPDF = req.AttachPDF
+	if req.Enabled != nil {
+		hook.Enabled = *req.Enabled
+	}
+	return true
+}
+
+// ownedWebhook loads a webhook the authenticated user may manage. Admins may manage any webhook.
+func (h *WebhookHandler) ownedWebhook(c *gin.Context) (*models.Webhook, bool) {
+	query := h.db.Where("id = ?", c.Param("id"))
+	if !middleware.IsAdmin(c) {
+		query = query.Where("user_id = ?", middleware.GetUserID(c))
+	}
+
+	var hook models.Webhook
+	if err := query.First(&hook).Error; err != nil {
+		c.JSON(http.StatusNotFound, gin.H{"error": "webhook not found"})
+		return nil, false
+	}
+	return &hook, true
+}
+
+// ListWebhooks returns the webhooks of the authenticated user
+// @Summary List webhooks
+// @Description List the authenticated user's webhooks. Admins also see global webhooks, or every webhook with full=true.
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param full query bool false "Include all users' webhooks (admin only)"
+// @Success 200 {array} models.Webhook
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /webhooks [get]
+func (h *WebhookHandler) ListWebhooks(c *gin.Context) {
+	var q ListWebhooksQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	isAdmin := middleware.IsAdmin(c)
+	if q.Full && !isAdmin {
+		c.JSON(http.StatusForbidden, gin.H{"error": "admin access required"})
+		return
+	}
+
+	query := h.db.Order("created_at DESC")
+	switch {
+	case q.Full:
+	case isAdmin:
+		query = query.Where("user_id IS NULL OR user_id = ?", middleware.GetUserID(c))
+	default:
+		query = query.Where("user_id = ?", middleware.GetUserID(c))
+	}
+
+	var hooks []models.Webhook
+	if err := query.Find(&hooks).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch webhooks"})
+		return
+	}
+
+	c.JSON(http.StatusOK, hooks)
+}
+
+// CreateWebhook creates a webhook
+// @Summary Create webhook
+// @Description Create a webhook that receives signed job events. User webhooks only receive events for the user's own jobs; admins may create global webhooks. The signing secret is only returned once.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param request body CreateWebhookRequest true "Webhook data"
+// @Success 201 {object} WebhookSecretResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 403 {object} ErrorResponse
+// @Router /webhooks [post]
+func (h *WebhookHandler) CreateWebhook(c *gin.Context) {
+	var req CreateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hook := models.Webhook{Enabled: true}
+	if req.Global {
+		if !middleware.IsAdmin(c) {
+			c.JSON(http.StatusForbidden, gin.H{"error": "only admins can create global webhooks"})
+			return
+		}
+	} else {
+		userID := middleware.GetUserID(c)
+		hook.UserID = &userID
+	}
+	if !bindWebhook(c, &req.WebhookRequest, &hook) {
+		return
+	}
+
+	secret, err := models.GenerateWebhookSecret()
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+		return
+	}
+	hook.Secret = secret
+
+	// Select all fields so a disabled webhook isn't overridden by the column default
+	if err := h.db.Select("*").Create(&hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to create webhook"})
+		return
+	}
+
+	c.JSON(http.StatusCreated, WebhookSecretResponse{Webhook: hook, Secret: secret})
+}
+
+// GetWebhook returns a webhook
+// @Summary Get webhook
+// @Description Get a webhook of the authenticated user
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} models.Webhook
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /webhooks/{id} [get]
+func (h *WebhookHandler) GetWebhook(c *gin.Context) {
+	hook, ok := h.ownedWebhook(c)
+	if !ok {
+		return
+	}
+
+	c.JSON(http.StatusOK, hook)
+}
+
+// UpdateWebhook updates a webhook
+// @Summary Update webhook
+// @Description Update a webhook of the authenticated user. With rotate_secret the new signing secret is returned once.
+// @Tags webhooks
+// @Accept json
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID"
+// @Param request body UpdateWebhookRequest true "Webhook data"
+// @Success 200 {object} models.Webhook
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /webhooks/{id} [put]
+func (h *WebhookHandler) UpdateWebhook(c *gin.Context) {
+	var req UpdateWebhookRequest
+	if err := c.ShouldBindJSON(&req); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+
+	hook, ok := h.ownedWebhook(c)
+	if !ok {
+		return
+	}
+	if !bindWebhook(c, &req.WebhookRequest, hook) {
+		return
+	}
+
+	if req.RotateSecret {
+		secret, err := models.GenerateWebhookSecret()
+		if err != nil {
+			c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to generate secret"})
+			return
+		}
+		hook.Secret = secret
+	}
+
+	if err := h.db.Save(hook).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to update webhook"})
+		return
+	}
+
+	if req.RotateSecret {
+		c.JSON(http.StatusOK, WebhookSecretResponse{Webhook: *hook, Secret: hook.Secret})
+		return
+	}
+	c.JSON(http.StatusOK, hook)
+}
+
+// DeleteWebhook deletes a webhook and its delivery log
+// @Summary Delete webhook
+// @Description Delete a webhook of the authenticated user. Pending deliveries are discarded.
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} MessageResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /webhooks/{id} [delete]
+func (h *WebhookHandler) DeleteWebhook(c *gin.Context) {
+	hook, ok := h.ownedWebhook(c)
+	if !ok {
+		return
+	}
+
+	err := h.db.Transaction(func(tx *gorm.DB) error {
+		if err := tx.Where("webhook_id = ?", hook.ID).Delete(&models.WebhookDelivery{}).Error; err != nil {
+			return err
+		}
+		return tx.Delete(hook).Error
+	})
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to delete webhook"})
+		return
+	}
+
+	c.JSON(http.StatusOK, gin.H{"message": "webhook deleted"})
+}
+
+// ListDeliveries returns the delivery log of a webhook
+// @Summary List webhook deliveries
+// @Description List deliveries of a webhook, most recent first
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID"
+// @Param page query int false "Page number" default(1)
+// @Param limit query int false "Items per page" default(50)
+// @Param status query string false "Filter by status (pending, succeeded, failed)"
+// @Success 200 {object} ListDeliveriesResponse
+// @Failure 400 {object} ErrorResponse
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /webhooks/{id}/deliveries [get]
+func (h *WebhookHandler) ListDeliveries(c *gin.Context) {
+	var q ListDeliveriesQuery
+	if err := c.ShouldBindQuery(&q); err != nil {
+		c.JSON(http.StatusBadRequest, gin.H{"error": err.Error()})
+		return
+	}
+	if q.Page < 1 {
+		q.Page = 1
+	}
+	if q.Limit < 1 || q.Limit > 200 {
+		q.Limit = 50
+	}
+
+	hook, ok := h.ownedWebhook(c)
+	if !ok {
+		return
+	}
+
+	query := h.db.Model(&models.WebhookDelivery{}).Where("webhook_id = ?", hook.ID)
+	if q.Status != "" {
+		query = query.Where("status = ?", q.Status)
+	}
+
+	var total int64
+	query.Count(&total)
+
+	var deliveries []models.WebhookDelivery
+	if err := query.Order("created_at DESC").Offset((q.Page - 1) * q.Limit).Limit(q.Limit).Find(&deliveries).Error; err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to fetch deliveries"})
+		return
+	}
+
+	c.JSON(http.StatusOK, ListDeliveriesResponse{
+		Deliveries: deliveries,
+		Total:      total,
+		Page:       q.Page,
+		Limit:      q.Limit,
+	})
+}
+
+// TestWebhook sends a test delivery
+// @Summary Test webhook
+// @Description Send a signed "ping" event to the webhook right away, without retries, and return the logged delivery
+// @Tags webhooks
+// @Produce json
+// @Security BearerAuth
+// @Param id path string true "Webhook ID"
+// @Success 200 {object} models.WebhookDelivery
+// @Failure 401 {object} ErrorResponse
+// @Failure 404 {object} ErrorResponse
+// @Router /webhooks/{id}/test [post]
+func (h *WebhookHandler) TestWebhook(c *gin.Context) {
+	hook, ok := h.ownedWebhook(c)
+	if !ok {
+		return
+	}
+
+	delivery, err := h.dispatcher.Test(c.Request.Context(), hook)
+	if err != nil {
+		c.JSON(http.StatusInternalServerError, gin.H{"error": "failed to send test delivery"})
+		return
+	}
+
+	c.JSON(http.StatusOK, delivery)
+}
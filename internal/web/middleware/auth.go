@@ -13,9 +13,13 @@ type AuthMiddleware struct {
 	config config.AuthConfig
 }
 
+// ScopeStream marks short-lived tokens that may only open event streams
+const ScopeStream = "stream"
+
 type Claims struct {
 	UserID  string `json:"user_id"`
 	IsAdmin bool   `json:"is_admin"`
+	Scope   string `json:"scope,omitempty"` // Empty for full access
 	jwt.RegisteredClaims
 }
 
@@ -24,8 +28,24 @@ func NewAuthMiddleware(cfg config.AuthConfig) *AuthMiddleware {
 }
 
 func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
+	return m.requireAuth(false)
+}
+
+// RequireStreamAuth is RequireAuth that also accepts a stream token in the
+// access_token query parameter, for clients like EventSource that cannot set
+// headers. Full tokens aren't accepted there, since URLs end up in access logs.
+func (m *AuthMiddleware) RequireStreamAuth() gin.HandlerFunc {
+	return m.requireAuth(true)
+}
+
+func (m *AuthMiddleware) requireAuth(allowQuery bool) gin.HandlerFunc {
 	return func(c *gin.Context) {
 		authHeader := c.GetHeader("Authorization")
+		fromQuery := false
+		if authHeader == "" && allowQuery && c.Query("access_token") != "" {
+			authHeader = "Bearer " + c.Query("access_token")
+			fromQuery = true
+		}
 		if authHeader == "" {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "missing authorization header"})
 			c.Abort()
@@ -46,7 +66,7 @@ func (m *AuthMiddleware) RequireAuth() gin.HandlerFunc {
 			return []byte(m.config.JWTSecret), nil
 		})
 
-		if err != nil || !token.Valid {
+		if err != nil || !token.Valid || (claims.Scope == ScopeStream) != fromQuery {
 			c.JSON(http.StatusUnauthorized, gin.H{"error": "invalid token"})
 			c.Abort()
 			return
@@ -8,10 +8,20 @@ import (
 	"net/http"
 	"strings"
 
+	"github.com/alex4386/zikzi/internal/auth"
 	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/forward"
+	"github.com/alex4386/zikzi/internal/hotfolder"
 	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/maintenance"
+	"github.com/alex4386/zikzi/internal/printer"
+	"github.com/alex4386/zikzi/internal/quota"
+	"github.com/alex4386/zikzi/internal/signing"
+	"github.com/alex4386/zikzi/internal/storage"
 	"github.com/alex4386/zikzi/internal/web/handlers"
 	"github.com/alex4386/zikzi/internal/web/middleware"
+	"github.com/alex4386/zikzi/internal/webhook"
 	"github.com/gin-gonic/gin"
 	swaggerFiles "github.com/swaggo/files"
 	ginSwagger "github.com/swaggo/gin-swagger"
@@ -24,12 +34,21 @@ import (
 var staticFS embed.FS
 
 type Server struct {
-	config *config.Config
-	db     *gorm.DB
-	router *gin.Engine
+	config    *config.Config
+	db        *gorm.DB
+	store     *storage.JobStore
+	signer    *signing.Signer
+	processor *printer.Processor
+	quotas    *quota.Checker
+	events    *events.Bus
+	webhooks  *webhook.Dispatcher
+	forwarder *forward.Forwarder
+	exporter  *hotfolder.Exporter
+	limiter   *auth.Limiter
+	router    *gin.Engine
 }
 
-func NewServer(cfg *config.Config, db *gorm.DB) *Server {
+func NewServer(cfg *config.Config, db *gorm.DB, store *storage.JobStore, signer *signing.Signer, processor *printer.Processor, quotas *quota.Checker, bus *events.Bus, webhooks *webhook.Dispatcher, forwarder *forward.Forwarder, exporter *hotfolder.Exporter, limiter *auth.Limiter) *Server {
 	router := gin.Default()
 
 	// Disable automatic redirects to prevent redirect loops
@@ -50,9 +69,18 @@ func NewServer(cfg *config.Config, db *gorm.DB) *Server {
 	}
 
 	s := &Server{
-		config: cfg,
-		db:     db,
-		router: router,
+		config:    cfg,
+		db:        db,
+		store:     store,
+		signer:    signer,
+		processor: processor,
+		quotas:    quotas,
+		events:    bus,
+		webhooks:  webhooks,
+		forwarder: forwarder,
+		exporter:  exporter,
+		limiter:   limiter,
+		router:    router,
 	}
 
 	s.setupRoutes()
@@ -78,7 +106,7 @@ func (s *Server) setupRoutes() {
 		// Public routes
 		auth := api.Group("/auth")
 		{
-			authHandler := handlers.NewAuthHandler(s.config.Auth, s.db)
+			authHandler := handlers.NewAuthHandler(s.config.Auth, s.db, s.limiter)
 			auth.POST("/login", authHandler.Login)
 			auth.POST("/register", authHandler.Register)
 			auth.GET("/oidc/login", authHandler.OIDCLogin)
@@ -86,6 +114,19 @@ func (s *Server) setupRoutes() {
 			auth.POST("/refresh", authHandler.RefreshToken)
 		}
 
+		// Live job events
+		eventHandler := handlers.NewEventHandler(s.events, s.config.Auth)
+		api.GET("/events", authMiddleware.RequireStreamAuth(), eventHandler.StreamEvents)
+		api.POST("/events/token", authMiddleware.RequireAuth(), eventHandler.CreateStreamToken)
+
+		// Public share links
+		shareHandler := handlers.NewShareHandler(s.db, s.config.Shares, s.store, s.limiter)
+		shares := api.Group("/shares")
+		{
+			shares.GET("/:token", shareHandler.GetSharedJob)
+			shares.GET("/:token/pdf", shareHandler.DownloadSharedPDF)
+		}
+
 		// Protected routes
 		protected := api.Group("/")
 		protected.Use(authMiddleware.RequireAuth())
@@ -93,45 +134,112 @@ func (s *Server) setupRoutes() {
 			// User routes
 			users := protected.Group("/users")
 			{
-				userHandler := handlers.NewUserHandler(s.db)
+				userHandler := handlers.NewUserHandler(s.db, s.quotas, s.config.Mail.Enabled)
 				users.GET("/me", userHandler.GetCurrentUser)
+				users.GET("/me/usage", userHandler.GetUsage)
 				users.PUT("/me", userHandler.UpdateCurrentUser)
 				users.PUT("/me/password", userHandler.ChangePassword)
 				users.PUT("/me/ipp-settings", userHandler.UpdateIPPSettings)
+				users.PUT("/me/email-settings", userHandler.UpdateEmailSettings)
 			}
 
 			// Print jobs routes
+			forwardHandler := handlers.NewForwardHandler(s.db, s.forwarder)
 			jobs := protected.Group("/jobs")
 			{
-				jobHandler := handlers.NewJobHandler(s.db, s.config.Storage)
+				jobHandler := handlers.NewJobHandler(s.db, s.config.Storage, s.store, s.signer,
+					maintenance.NewTrash(s.config.Trash, s.db, s.store), s.quotas, s.events)
+				uploadHandler := handlers.NewUploadHandler(s.db, s.store, s.processor, s.quotas, s.events, s.forwarder, s.config.Web.MaxUploadSize)
 				jobs.GET("", jobHandler.ListJobs)
+				jobs.POST("", uploadHandler.UploadJob)
 				jobs.GET("/orphaned", jobHandler.ListOrphanedJobs) // Admin only
+				jobs.GET("/trash", jobHandler.ListTrash)
+				jobs.DELETE("/trash", jobHandler.EmptyTrash)
 				jobs.GET("/:id", jobHandler.GetJob)
 				jobs.GET("/:id/download", jobHandler.DownloadJob)
 				jobs.GET("/:id/pdf", jobHandler.DownloadPDF)
 				jobs.GET("/:id/thumbnail", jobHandler.GetThumbnail)
+				jobs.GET("/:id/verify", jobHandler.VerifyJob)
 				jobs.POST("/:id/assign", jobHandler.AssignJob) // Admin only
+				jobs.POST("/bulk", jobHandler.BulkJobs)
+				jobs.POST("/export", jobHandler.ExportJobs)
+				jobs.PUT("/:id", jobHandler.UpdateJob)
+				jobs.POST("/:id/restore", jobHandler.RestoreJob)
 				jobs.DELETE("/:id", jobHandler.DeleteJob)
+				jobs.GET("/:id/shares", shareHandler.ListShares)
+				jobs.POST("/:id/shares", shareHandler.CreateShare)
+				jobs.DELETE("/:id/shares/:shareId", shareHandler.RevokeShare)
+				jobs.GET("/:id/shares/:shareId/accesses", shareHandler.ListShareAccesses)
+				jobs.POST("/:id/forward", forwardHandler.ForwardJob)
+				jobs.GET("/:id/forwards", forwardHandler.ListForwards)
+			}
+
+			// Downstream printer routes
+			protected.GET("/forwarding/printers", forwardHandler.ListPrinters)
+
+			// Folder routes
+			folders := protected.Group("/folders")
+			{
+				folderHandler := handlers.NewFolderHandler(s.db)
+				folders.GET("", folderHandler.ListFolders)
+				folders.POST("", folderHandler.CreateFolder)
+				folders.PUT("/:id", folderHandler.UpdateFolder)
+				folders.DELETE("/:id", folderHandler.DeleteFolder)
+			}
+
+			// Tag routes
+			tagHandler := handlers.NewTagHandler(s.db)
+			tags := protected.Group("/tags")
+			{
+				tags.GET("", tagHandler.ListTags)
+				tags.POST("", tagHandler.CreateTag)
+				tags.PUT("/:id", tagHandler.UpdateTag)
+				tags.DELETE("/:id", tagHandler.DeleteTag)
+			}
+			tagRules := protected.Group("/tag-rules")
+			{
+				tagRules.GET("", tagHandler.ListTagRules)
+				tagRules.POST("", tagHandler.CreateTagRule)
+				tagRules.PUT("/:id", tagHandler.UpdateTagRule)
+				tagRules.DELETE("/:id", tagHandler.DeleteTagRule)
+			}
+
+			// Webhook routes
+			webhooks := protected.Group("/webhooks")
+			{
+				webhookHandler := handlers.NewWebhookHandler(s.db, s.webhooks)
+				webhooks.GET("", webhookHandler.ListWebhooks)
+				webhooks.POST("", webhookHandler.CreateWebhook)
+				webhooks.GET("/:id", webhookHandler.GetWebhook)
+				webhooks.PUT("/:id", webhookHandler.UpdateWebhook)
+				webhooks.DELETE("/:id", webhookHandler.DeleteWebhook)
+				webhooks.GET("/:id/deliveries", webhookHandler.ListDeliveries)
+				webhooks.POST("/:id/test", webhookHandler.TestWebhook)
 			}
 
 			// IP registration routes
 			ips := protected.Group("/ips")
 			{
-				ipHandler := handlers.NewIPHandler(s.db)
+				ipHandler := handlers.NewIPHandler(s.db, s.config.IPRegistrations)
 				ips.GET("", ipHandler.ListIPs)
 				ips.POST("", ipHandler.RegisterIP)
 				ips.PUT("/:id", ipHandler.UpdateIP)
 				ips.DELETE("/:id", ipHandler.DeleteIP)
+				ips.POST("/:id/renew", ipHandler.RenewIP)
+				ips.POST("/claims", ipHandler.CreateClaimCode)
+				ips.GET("/claims/:id", ipHandler.GetClaimCode)
 				ips.GET("/detect", ipHandler.DetectIP)
 			}
 
 			// IPP token routes
 			tokens := protected.Group("/tokens")
 			{
-				tokenHandler := handlers.NewTokenHandler(s.db)
+				tokenHandler := handlers.NewTokenHandler(s.db, s.config.IPP.Auth.Realm)
 				tokens.GET("", tokenHandler.ListTokens)
 				tokens.POST("", tokenHandler.CreateToken)
 				tokens.GET("/:id", tokenHandler.GetToken)
+				tokens.GET("/:id/usage", tokenHandler.ListTokenUsage)
+				tokens.PUT("/:id/scope", tokenHandler.UpdateTokenScope)
 				tokens.POST("/:id/revoke", tokenHandler.RevokeToken)
 				tokens.DELETE("/:id", tokenHandler.DeleteToken)
 			}
@@ -153,6 +261,18 @@ func (s *Server) setupRoutes() {
 			admin.GET("/jobs/:id", adminHandler.GetJobAdmin)
 			admin.POST("/jobs/:id/assign", adminHandler.AssignJob)
 			admin.GET("/stats", adminHandler.GetStats)
+
+			retentionHandler := handlers.NewRetentionHandler(s.config.Retention.Enabled,
+				maintenance.NewRetention(s.config.Retention, s.db, s.store))
+			admin.GET("/retention/preview", retentionHandler.PreviewRetention)
+
+			hotFolderHandler := handlers.NewHotFolderHandler(s.db, s.exporter)
+			admin.GET("/exports", hotFolderHandler.ListExports)
+			admin.POST("/exports/:id/retry", hotFolderHandler.RetryExport)
+
+			lockoutHandler := handlers.NewLockoutHandler(s.limiter)
+			admin.GET("/lockouts", lockoutHandler.ListLockouts)
+			admin.DELETE("/lockouts", lockoutHandler.ClearLockout)
 		}
 	}
 
@@ -246,6 +366,8 @@ func (s *Server) Start(ctx context.Context) error {
 
 	go func() {
 		<-ctx.Done()
+		// End event streams first, Shutdown waits for open requests
+		s.events.Close()
 		server.Shutdown(context.Background())
 	}()
 
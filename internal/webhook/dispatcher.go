@@ -0,0 +1,418 @@
+// Package webhook delivers job events to external HTTP endpoints
+package webhook
+
+import (
+	"bytes"
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"mime/multipart"
+	"net"
+	"net/http"
+	"strconv"
+	"syscall"
+	"time"
+
+	"github.com/alex4386/zikzi/internal/config"
+	"github.com/alex4386/zikzi/internal/events"
+	"github.com/alex4386/zikzi/internal/logger"
+	"github.com/alex4386/zikzi/internal/models"
+	"github.com/alex4386/zikzi/internal/storage"
+	"gorm.io/gorm"
+)
+
+// EventPing is sent by test deliveries
+const EventPing = "ping"
+
+// IP registration events, sent by the expiry check
+const (
+	EventIPExpiring = "ip.expiring"
+	EventIPExpired  = "ip.expired"
+)
+
+// Events lists the event types webhooks can subscribe to
+var Events = []string{
+	events.JobCreated,
+	events.JobProcessing,
+	events.JobCompleted,
+	events.JobFailed,
+	events.JobDeleted,
+	EventIPExpiring,
+	EventIPExpired,
+}
+
+const (
+	retryPoll     = 15 * time.Second
+	pruneInterval = time.Hour
+)
+
+// Request headers sent with every delivery
+const (
+	HeaderEvent     = "X-Zikzi-Event"
+	HeaderDelivery  = "X-Zikzi-Delivery"
+	HeaderTimestamp = "X-Zikzi-Timestamp"
+	HeaderSignature = "X-Zikzi-Signature"
+)
+
+// Payload is the JSON body of a delivery
+type Payload struct {
+	ID        string           `json:"id"` // Delivery ID, stable across retries
+	Event     string           `json:"event"`
+	Time      time.Time        `json:"time"`
+	WebhookID string           `json:"webhook_id"`
+	Job       *models.PrintJob `json:"job,omitempty"`
+
+	IPRegistration *models.IPRegistration `json:"ip_registration,omitempty"`
+}
+
+// Dispatcher turns bus events into webhook deliveries and retries failed ones
+type Dispatcher struct {
+	config     config.WebhooksConfig
+	db         *gorm.DB
+	store      *storage.JobStore
+	client     *http.Client // Global webhooks, configured by admins
+	userClient *http.Client // User webhooks, optionally kept off private networks
+}
+
+func NewDispatcher(cfg config.WebhooksConfig, db *gorm.DB, store *storage.JobStore) *Dispatcher {
+	return &Dispatcher{
+		config:     cfg,
+		db:         db,
+		store:      store,
+		client:     newClient(cfg.Timeout, false),
+		userClient: newClient(cfg.Timeout, !cfg.AllowPrivateTarget),
+	}
+}
+
+// newClient creates an HTTP client that does not follow redirects. A restricted
+// client refuses to connect to loopback, private and link-local addresses.
+func newClient(timeout time.Duration, restricted bool) *http.Client {
+	dialer := &net.Dialer{Timeout: timeout}
+	transport := http.DefaultTransport.(*http.Transport).Clone()
+	if restricted {
+		dialer.Control = func(network, address string, _ syscall.RawConn) error {
+			host, _, err := net.SplitHostPort(address)
+			if err != nil {
+				return err
+			}
+			if ip := net.ParseIP(host); ip == nil || !isPublicIP(ip) {
+				return fmt.Errorf("webhook target %s is not a public address", host)
+			}
+			return nil
+		}
+		// A proxy would connect on our behalf and bypass the check
+		transport.Proxy = nil
+	}
+	transport.DialContext = dialer.DialContext
+
+	return &http.Client{
+		Timeout:   timeout,
+		Transport: transport,
+		CheckRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+}
+
+func isPublicIP(ip net.IP) bool {
+	return !ip.IsLoopback() && !ip.IsPrivate() && !ip.IsUnspecified() &&
+		!ip.IsLinkLocalUnicast() && !ip.IsLinkLocalMulticast() &&
+		!ip.IsInterfaceLocalMulticast() && !ip.IsMulticast()
+}
+
+// Run delivers events published on the bus until ctx is cancelled
+func (d *Dispatcher) Run(ctx context.Context, bus *events.Bus) {
+	sub := bus.Subscribe(0)
+	defer sub.Close()
+
+	ticker := time.NewTicker(retryPoll)
+	defer ticker.Stop()
+	lastPrune := time.Time{}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case event, ok := <-sub.C:
+			if !ok {
+				return
+			}
+			d.enqueue(ctx, event)
+		case <-ticker.C:
+			d.retryDue(ctx)
+			if time.Since(lastPrune) > pruneInterval {
+				d.prune(ctx)
+				lastPrune = time.Now()
+			}
+		}
+	}
+}
+
+// enqueue records a delivery for every webhook subscribed to the event and sends it right away
+func (d *Dispatcher) enqueue(ctx context.Context, event events.Event) {
+	d.fanOut(ctx, event.UserID, Payload{Event: event.Type, Time: event.Time, Job: event.Job}, event.JobID)
+}
+
+// NotifyIPRegistration sends an IP registration event to the webhooks of its
+// owner and to global webhooks
+func (d *Dispatcher) NotifyIPRegistration(ctx context.Context, eventType string, registration *models.IPRegistration) {
+	snapshot := *registration
+	snapshot.User = nil
+	d.fanOut(ctx, registration.UserID, Payload{Event: eventType, Time: time.Now(), IPRegistration: &snapshot}, "")
+}
+
+// fanOut records a delivery for every webhook subscribed to the event and sends it right away
+func (d *Dispatcher) fanOut(ctx context.Context, userID string, payload Payload, jobID string) {
+	var hooks []models.Webhook
+	query := d.db.WithContext(ctx).Where("enabled = ?", true)
+	if userID != "" {
+		query = query.Where("user_id IS NULL OR user_id = ?", userID)
+	} else {
+		query = query.Where("user_id IS NULL")
+	}
+	if err := query.Find(&hooks).Error; err != nil {
+		logger.Error("Webhooks: failed to load subscriptions: %v", err)
+		return
+	}
+
+	for i := range hooks {
+		hook := &hooks[i]
+		if !hook.Subscribes(payload.Event) {
+			continue
+		}
+
+		delivery, err := d.createDelivery(ctx, hook, payload, jobID)
+		if err != nil {
+			logger.Error("Webhooks: failed to queue %s for webhook %s: %v", payload.Event, hook.ID, err)
+			continue
+		}
+		go d.attempt(ctx, hook, delivery, true)
+	}
+}
+
+// createDelivery stores a pending delivery, leased for its first attempt
+func (d *Dispatcher) createDelivery(ctx context.Context, hook *models.Webhook, payload Payload, jobID string) (*models.WebhookDelivery, error) {
+	lease := time.Now().Add(d.lease())
+	delivery := &models.WebhookDelivery{
+		WebhookID:     hook.ID,
+		JobID:         jobID,
+		Event:         payload.Event,
+		Status:        models.DeliveryPending,
+		NextAttemptAt: &lease,
+	}
+	if err := d.db.WithContext(ctx).Create(delivery).Error; err != nil {
+		return nil, err
+	}
+
+	payload.ID = delivery.ID
+	payload.WebhookID = hook.ID
+	body, err := json.Marshal(payload)
+	if err != nil {
+		return nil, err
+	}
+	delivery.Payload = string(body)
+	if err := d.db.WithContext(ctx).Model(delivery).Update("payload", delivery.Payload).Error; err != nil {
+		return nil, err
+	}
+	return delivery, nil
+}
+
+// lease is how long an attempt in progress keeps other workers from retrying it
+func (d *Dispatcher) lease() time.Duration {
+	return 2*d.config.Timeout + time.Minute
+}
+
+// retryDue claims and retries pending deliveries whose next attempt is due
+func (d *Dispatcher) retryDue(ctx context.Context) {
+	now := time.Now()
+	var due []models.WebhookDelivery
+	if err := d.db.WithContext(ctx).
+		Where("status = ? AND next_attempt_at <= ?", models.DeliveryPending, now).
+		Order("next_attempt_at").Limit(100).Find(&due).Error; err != nil {
+		logger.Error("Webhooks: failed to load pending deliveries: %v", err)
+		return
+	}
+
+	for i := range due {
+		delivery := &due[i]
+
+		// Claim the delivery so it isn't sent twice
+		lease := now.Add(d.lease())
+		claimed := d.db.WithContext(ctx).Model(&models.WebhookDelivery{}).
+			Where("id = ? AND status = ? AND next_attempt_at = ?", delivery.ID, models.DeliveryPending, delivery.NextAttemptAt).
+			Update("next_attempt_at", lease)
+		if claimed.Error != nil || claimed.RowsAffected == 0 {
+			continue
+		}
+		delivery.NextAttemptAt = &lease
+
+		var hook models.Webhook
+		if err := d.db.WithContext(ctx).First(&hook, "id = ?", delivery.WebhookID).Error; err != nil || !hook.Enabled {
+			d.db.WithContext(ctx).Model(delivery).Updates(map[string]interface{}{
+				"status":          models.DeliveryFailed,
+				"error":           "webhook deleted or disabled",
+				"next_attempt_at": nil,
+			})
+			continue
+		}
+		go d.attempt(ctx, &hook, delivery, true)
+	}
+}
+
+// prune deletes finished deliveries older than the log retention
+func (d *Dispatcher) prune(ctx context.Context) {
+	if d.config.LogRetention <= 0 {
+		return
+	}
+	result := d.db.WithContext(ctx).
+		Where("status <> ? AND created_at < ?", models.DeliveryPending, time.Now().Add(-d.config.LogRetention)).
+		Delete(&models.WebhookDelivery{})
+	if result.Error != nil {
+		logger.Warn("Webhooks: failed to prune delivery log: %v", result.Error)
+	} else if result.RowsAffected > 0 {
+		logger.Debug("Webhooks: pruned %d old deliveries", result.RowsAffected)
+	}
+}
+
+// Test sends a ping to the webhook once, without retries, and returns the logged delivery
+func (d *Dispatcher) Test(ctx context.Context, hook *models.Webhook) (*models.WebhookDelivery, error) {
+	delivery, err := d.createDelivery(ctx, hook, Payload{Event: EventPing, Time: time.Now()}, "")
+	if err != nil {
+		return nil, err
+	}
+	d.attempt(ctx, hook, delivery, false)
+	return delivery, nil
+}
+
+// attempt sends a delivery once and records the outcome, scheduling a retry if allowed
+func (d *Dispatcher) attempt(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery, retry bool) {
+	started := time.Now()
+	statusCode, err := d.send(ctx, hook, delivery)
+
+	delivery.Attempts++
+	delivery.StatusCode = statusCode
+	delivery.DurationMs = time.Since(started).Milliseconds()
+	delivery.Error = ""
+	delivery.NextAttemptAt = nil
+
+	switch {
+	case err == nil:
+		now := time.Now()
+		delivery.Status = models.DeliverySucceeded
+		delivery.DeliveredAt = &now
+	case retry && delivery.Attempts < d.config.MaxAttempts:
+		next := time.Now().Add(d.config.RetryBackoff << (delivery.Attempts - 1))
+		delivery.Status = models.DeliveryPending
+		delivery.Error = err.Error()
+		delivery.NextAttemptAt = &next
+	default:
+		delivery.Status = models.DeliveryFailed
+		delivery.Error = err.Error()
+	}
+
+	if err != nil {
+		logger.Warn("Webhooks: delivery %s of %s to webhook %s failed (attempt %d): %v",
+			delivery.ID, delivery.Event, hook.ID, delivery.Attempts, err)
+	}
+
+	// The request context may already be gone, the outcome should still be recorded
+	if err := d.db.Select("status", "attempts", "status_code", "error", "duration_ms", "next_attempt_at", "delivered_at").
+		Save(delivery).Error; err != nil {
+		logger.Error("Webhooks: failed to record delivery %s: %v", delivery.ID, err)
+	}
+}
+
+// send performs a single signed HTTP request for a delivery
+func (d *Dispatcher) send(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) (int, error) {
+	body, contentType, err := d.body(ctx, hook, delivery)
+	if err != nil {
+		return 0, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, hook.URL, bytes.NewReader(body))
+	if err != nil {
+		return 0, err
+	}
+	timestamp := strconv.FormatInt(time.Now().Unix(), 10)
+	req.Header.Set("Content-Type", contentType)
+	req.Header.Set("User-Agent", "Zikzi-Webhook/1.0")
+	req.Header.Set(HeaderEvent, delivery.Event)
+	req.Header.Set(HeaderDelivery, delivery.ID)
+	req.Header.Set(HeaderTimestamp, timestamp)
+	req.Header.Set(HeaderSignature, "sha256="+Sign(hook.Secret, timestamp, body))
+
+	client := d.client
+	if !hook.IsGlobal() {
+		client = d.userClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return 0, err
+	}
+	defer resp.Body.Close()
+	io.Copy(io.Discard, io.LimitReader(resp.Body, 64<<10))
+
+	if resp.StatusCode < 200 || resp.StatusCode > 299 {
+		return resp.StatusCode, fmt.Errorf("unexpected response status %s", resp.Status)
+	}
+	return resp.StatusCode, nil
+}
+
+// body builds the request body: the JSON payload, or a multipart form with
+// the payload and the job's PDF when the webhook asks for attachments
+func (d *Dispatcher) body(ctx context.Context, hook *models.Webhook, delivery *models.WebhookDelivery) ([]byte, string, error) {
+	if !hook.AttachPDF || delivery.JobID == "" {
+		return []byte(delivery.Payload), "application/json", nil
+	}
+
+	var job models.PrintJob
+	if err := d.db.WithContext(ctx).Unscoped().First(&job, "id = ?", delivery.JobID).Error; err != nil || job.PDFFile == "" {
+		return []byte(delivery.Payload), "application/json", nil
+	}
+
+	var buf bytes.Buffer
+	mw := multipart.NewWriter(&buf)
+	if err := mw.WriteField("payload", delivery.Payload); err != nil {
+		return nil, "", err
+	}
+
+	reader, err := d.store.Open(ctx, &job, job.PDFFile)
+	if errors.Is(err, storage.ErrNotFound) {
+		// Deliver the event even if the PDF is gone
+		if err := mw.Close(); err != nil {
+			return nil, "", err
+		}
+		return buf.Bytes(), mw.FormDataContentType(), nil
+	}
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to read PDF: %w", err)
+	}
+	defer reader.Close()
+
+	part, err := mw.CreateFormFile("file", job.ID+".pdf")
+	if err != nil {
+		return nil, "", err
+	}
+	if _, err := io.Copy(part, reader); err != nil {
+		return nil, "", fmt.Errorf("failed to read PDF: %w", err)
+	}
+	if err := mw.Close(); err != nil {
+		return nil, "", err
+	}
+	return buf.Bytes(), mw.FormDataContentType(), nil
+}
+
+// Sign returns the hex HMAC-SHA256 of "<timestamp>.<body>" that receivers
+// compare against the X-Zikzi-Signature header
+func Sign(secret, timestamp string, body []byte) string {
+	mac := hmac.New(sha256.New, []byte(secret))
+	mac.Write([]byte(timestamp))
+	mac.Write([]byte("."))
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
@@ -181,6 +181,17 @@ class Api {
     return this.request<{ ip_address: string }>('/ips/detect')
   }
 
+  createClaimCode(description: string) {
+    return this.request<IPClaimCode>('/ips/claims', {
+      method: 'POST',
+      body: JSON.stringify({ description }),
+    })
+  }
+
+  getClaimCode(id: string) {
+    return this.request<IPClaimCode>(`/ips/claims/${id}`)
+  }
+
   // IPP Tokens
   getTokens(page = 1, limit = 20, options?: { full?: boolean; userId?: string }) {
     const params = new URLSearchParams({ page: String(page), limit: String(limit) })
@@ -326,6 +337,16 @@ export interface IPRegistration {
   is_active: boolean
 }
 
+export interface IPClaimCode {
+  id: string
+  code: string
+  description: string
+  expires_at: string
+  claimed_at?: string
+  claimed_ip?: string
+  registration_id?: string
+}
+
 export interface IPPToken {
   id: string
   created_at: string
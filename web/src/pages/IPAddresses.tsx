@@ -1,8 +1,8 @@
-import { useState } from 'react'
+import { useState, useEffect } from 'react'
 import { useQuery, useMutation, useQueryClient } from '@tanstack/react-query'
 import { useTranslation } from 'react-i18next'
-import { Plus, Trash2, Network, Loader2, Wifi, Pencil } from 'lucide-react'
-import { api, IPRegistration } from '@/lib/api'
+import { Plus, Trash2, Network, Loader2, Wifi, Pencil, Link2 } from 'lucide-react'
+import { api, IPRegistration, IPClaimCode } from '@/lib/api'
 import { PageContainer } from '@/components/PageContainer'
 import { Note } from '@/components/Note'
 import { Button } from '@/components/ui/button'
@@ -28,7 +28,7 @@ import {
 } from '@/components/ui/dialog'
 import { ActionCollections } from '@/components/ActionCollections'
 
-type DialogMode = 'create' | 'edit' | null
+type DialogMode = 'create' | 'edit' | 'pair' | null
 
 export default function IPAddresses() {
   const { t } = useTranslation()
@@ -36,6 +36,7 @@ export default function IPAddresses() {
   const [ipAddress, setIpAddress] = useState('')
   const [description, setDescription] = useState('')
   const [editingIp, setEditingIp] = useState<IPRegistration | null>(null)
+  const [claim, setClaim] = useState<IPClaimCode | null>(null)
   const queryClient = useQueryClient()
 
   const { data, isLoading } = useQuery({
@@ -58,6 +59,29 @@ export default function IPAddresses() {
     },
   })
 
+  const claimMutation = useMutation({
+    mutationFn: () => api.createClaimCode(''),
+    onSuccess: (data) => {
+      setClaim(data)
+    },
+  })
+
+  // Poll the claim code until a machine has printed it
+  const { data: claimStatus } = useQuery({
+    queryKey: ['ip-claim', claim?.id],
+    queryFn: () => api.getClaimCode(claim!.id),
+    enabled: dialogMode === 'pair' && !!claim,
+    refetchInterval: (query) => (query.state.data?.claimed_at ? false : 3000),
+  })
+  const claimedIp = claimStatus?.claimed_at ? claimStatus.claimed_ip : undefined
+  const claimExpired = !!claim && !claimedIp && new Date(claim.expires_at) < new Date()
+
+  useEffect(() => {
+    if (claimedIp) {
+      queryClient.invalidateQueries({ queryKey: ['ips'] })
+    }
+  }, [claimedIp, queryClient])
+
   const deleteMutation = useMutation({
     mutationFn: (id: string) => api.deleteIP(id),
     onSuccess: () => {
@@ -81,6 +105,12 @@ export default function IPAddresses() {
     setDialogMode('create')
   }
 
+  const openPair = () => {
+    setClaim(null)
+    setDialogMode('pair')
+    claimMutation.mutate()
+  }
+
   const openEdit = (ip: IPRegistration) => {
     setEditingIp(ip)
     setDescription(ip.description || '')
@@ -90,6 +120,7 @@ export default function IPAddresses() {
   const closeDialog = () => {
     setDialogMode(null)
     setEditingIp(null)
+    setClaim(null)
     setIpAddress('')
     setDescription('')
   }
@@ -117,10 +148,16 @@ export default function IPAddresses() {
             {t('ipAddresses.description')}
           </p>
         </div>
-        <Button onClick={openCreate}>
-          <Plus className="h-4 w-4 mr-2" />
-          {t('ipAddresses.addIp')}
-        </Button>
+        <div className="flex gap-2">
+          <Button variant="outline" onClick={openPair}>
+            <Link2 className="h-4 w-4 mr-2" />
+            {t('ipAddresses.pairMachine')}
+          </Button>
+          <Button onClick={openCreate}>
+            <Plus className="h-4 w-4 mr-2" />
+            {t('ipAddresses.addIp')}
+          </Button>
+        </div>
       </div>
 
       {isLoading ? (
@@ -250,6 +287,62 @@ export default function IPAddresses() {
         </DialogContent>
       </Dialog>
 
+      {/* Pair Machine Dialog */}
+      <Dialog open={dialogMode === 'pair'} onOpenChange={(open) => !open && closeDialog()}>
+        <DialogContent>
+          <DialogHeader>
+            <DialogTitle>{t('ipAddresses.pair.title')}</DialogTitle>
+            <DialogDescription>{t('ipAddresses.pair.description')}</DialogDescription>
+          </DialogHeader>
+          <div className="space-y-4">
+            {claimMutation.isPending ? (
+              <div className="flex items-center justify-center py-6">
+                <Loader2 className="h-6 w-6 animate-spin text-primary" />
+              </div>
+            ) : claimMutation.error ? (
+              <Note variant="error">
+                {claimMutation.error instanceof Error ? claimMutation.error.message : t('ipAddresses.pair.failed')}
+              </Note>
+            ) : claim ? (
+              <>
+                <div className="rounded-md border bg-muted py-6 text-center font-mono text-3xl font-bold tracking-widest select-all">
+                  {claim.code}
+                </div>
+                <p className="text-sm text-muted-foreground">
+                  {t('ipAddresses.pair.expiresAt', { time: new Date(claim.expires_at).toLocaleTimeString() })}{' '}
+                  {t('ipAddresses.pair.hint', { code: claim.code })}
+                </p>
+                {claimedIp ? (
+                  <Note variant="success">{t('ipAddresses.pair.paired', { ip: claimedIp })}</Note>
+                ) : claimExpired ? (
+                  <Note variant="warning">{t('ipAddresses.pair.expired')}</Note>
+                ) : (
+                  <div className="flex items-center gap-2 text-sm text-muted-foreground">
+                    <Loader2 className="h-4 w-4 animate-spin" />
+                    {t('ipAddresses.pair.waiting')}
+                  </div>
+                )}
+              </>
+            ) : null}
+          </div>
+          <DialogFooter>
+            {!claimedIp && (
+              <Button
+                type="button"
+                variant="outline"
+                onClick={() => claimMutation.mutate()}
+                disabled={claimMutation.isPending}
+              >
+                {t('ipAddresses.pair.newCode')}
+              </Button>
+            )}
+            <Button type="button" onClick={closeDialog}>
+              {claimedIp ? t('ipAddresses.pair.done') : t('common.cancel')}
+            </Button>
+          </DialogFooter>
+        </DialogContent>
+      </Dialog>
+
       {/* Edit IP Dialog */}
       <Dialog open={dialogMode === 'edit'} onOpenChange={(open) => !open && closeDialog()}>
         <DialogContent>